@@ -1,15 +1,23 @@
 package main
 
 import (
+	"context"
+	"encoding/hex"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/iden3/go-circom-prover-verifier/parsers"
+	"github.com/iden3/go-circom-prover-verifier/phase2"
 	"github.com/iden3/go-circom-prover-verifier/prover"
+	"github.com/iden3/go-circom-prover-verifier/ptau"
+	"github.com/iden3/go-circom-prover-verifier/r1cs"
+	"github.com/iden3/go-circom-prover-verifier/types"
 	"github.com/iden3/go-circom-prover-verifier/verifier"
 )
 
@@ -23,17 +31,41 @@ func main() {
 	verify := flag.Bool("verify", false, "verifier mode")
 	convert := flag.Bool("convert", false, "convert mode, to convert between proving_key.json to proving_key.go.bin")
 
-	provingKeyPath := flag.String("pk", "proving_key.json", "provingKey path")
-	witnessPath := flag.String("witness", "witness.json", "witness path")
+	provingKeyPath := flag.String("pk", "proving_key.json", "provingKey path (proving_key.json or .zkey)")
+	witnessPath := flag.String("witness", "witness.json", "witness path (witness.json or .wtns)")
 	proofPath := flag.String("proof", "proof.json", "proof path")
 	verificationKeyPath := flag.String("vk", "verification_key.json", "verificationKey path")
 	publicPath := flag.String("public", "public.json", "public signals path")
 	provingKeyBinPath := flag.String("pkbin", "proving_key.go.bin", "provingKey Bin path")
+	r1csPath := flag.String("r1cs", "circuit.r1cs", "r1cs path")
+	check := flag.Bool("check", false, "check the witness against the r1cs before generating the proof")
+	stats := flag.Bool("stats", false, "print the time and allocations of each phase of the proof generation")
+	stream := flag.Bool("stream", false, "prover mode reading the points of the proving key (.zkey or .go.bin) from the file in chunks, for the proving keys that do not fit in memory")
+	tuningPath := flag.String("tuning", "", "tuning path, to choose the MSM window sizes with the costs measured on this machine (it is calibrated and stored when it does not exist)")
+
+	ptauNew := flag.Bool("ptaunew", false, "powers of tau mode, to start a new ceremony")
+	ptauContribute := flag.Bool("ptaucontribute", false, "powers of tau mode, to contribute to the ceremony")
+	ptauBeacon := flag.Bool("ptaubeacon", false, "powers of tau mode, to contribute with a random beacon")
+	ptauVerify := flag.Bool("ptauverify", false, "powers of tau mode, to verify the ceremony")
+	ptauPath := flag.String("ptau", "pot.ptau", "powers of tau path")
+	ptauOutPath := flag.String("ptauout", "pot_new.ptau", "powers of tau output path")
+	power := flag.Int("power", 10, "power of the new ceremony, for 2^power constraints")
+	name := flag.String("name", "", "contribution name")
+	beaconHash := flag.String("beacon", "", "beacon hash in hex")
+	beaconIterExp := flag.Int("beaconiterexp", 10, "beacon hash is iterated 2^beaconiterexp times")
+
+	phase2Contribute := flag.Bool("phase2contribute", false, "phase 2 mode, to contribute to the proving key (pkbin) and verification key (vk)")
+	phase2Verify := flag.Bool("phase2verify", false, "phase 2 mode, to verify the contributions to the proving key (pkbin) and verification key (vk)")
+	contributionsPath := flag.String("contributions", "contributions.json", "phase 2 contributions path")
+	provingKeyBinOutPath := flag.String("pkbinout", "proving_key_new.go.bin", "phase 2 output provingKey Bin path")
+	verificationKeyOutPath := flag.String("vkout", "verification_key_new.json", "phase 2 output verificationKey path")
+	provingKeyBinInitialPath := flag.String("pkbininitial", "proving_key_initial.go.bin", "phase 2 initial provingKey Bin path")
+	verificationKeyInitialPath := flag.String("vkinitial", "verification_key_initial.json", "phase 2 initial verificationKey path")
 
 	flag.Parse()
 
 	if *prove {
-		err := cmdProve(*provingKeyPath, *witnessPath, *proofPath, *publicPath)
+		err := cmdProve(*provingKeyPath, *witnessPath, *proofPath, *publicPath, *check, *r1csPath, *stats, *tuningPath, *stream)
 		if err != nil {
 			fmt.Println("Error:", err)
 		}
@@ -50,40 +82,113 @@ func main() {
 			fmt.Println("Error:", err)
 		}
 		os.Exit(0)
+	} else if *ptauNew {
+		err := cmdPtauNew(*power, *ptauOutPath)
+		if err != nil {
+			fmt.Println("Error:", err)
+		}
+		os.Exit(0)
+	} else if *ptauContribute {
+		err := cmdPtauContribute(*ptauPath, *ptauOutPath, *name)
+		if err != nil {
+			fmt.Println("Error:", err)
+		}
+		os.Exit(0)
+	} else if *ptauBeacon {
+		err := cmdPtauBeacon(*ptauPath, *ptauOutPath, *name, *beaconHash, *beaconIterExp)
+		if err != nil {
+			fmt.Println("Error:", err)
+		}
+		os.Exit(0)
+	} else if *ptauVerify {
+		err := cmdPtauVerify(*ptauPath)
+		if err != nil {
+			fmt.Println("Error:", err)
+		}
+		os.Exit(0)
+	} else if *phase2Contribute {
+		err := cmdPhase2Contribute(*provingKeyBinPath, *verificationKeyPath, *contributionsPath, *provingKeyBinOutPath, *verificationKeyOutPath, *name)
+		if err != nil {
+			fmt.Println("Error:", err)
+		}
+		os.Exit(0)
+	} else if *phase2Verify {
+		err := cmdPhase2Verify(*provingKeyBinInitialPath, *verificationKeyInitialPath, *provingKeyBinPath, *verificationKeyPath, *contributionsPath)
+		if err != nil {
+			fmt.Println("Error:", err)
+		}
+		os.Exit(0)
 	}
 	flag.PrintDefaults()
 }
 
-func cmdProve(provingKeyPath, witnessPath, proofPath, publicPath string) error {
+func cmdProve(provingKeyPath, witnessPath, proofPath, publicPath string, check bool, r1csPath string, printStats bool, tuningPath string, stream bool) error {
 	fmt.Println("zkSNARK Groth16 prover")
 
-	fmt.Println("Reading proving key file:", provingKeyPath)
-	provingKeyJson, err := ioutil.ReadFile(provingKeyPath)
-	if err != nil {
-		return err
-	}
-	pk, err := parsers.ParsePk(provingKeyJson)
-	if err != nil {
-		return err
+	var pk *types.Pk
+	var sp *prover.StreamProver
+	var err error
+	if stream {
+		fmt.Println("Reading proving key header:", provingKeyPath)
+		var provingKeyFile *os.File
+		if provingKeyFile, err = os.Open(provingKeyPath); err != nil {
+			return err
+		}
+		defer provingKeyFile.Close()
+		if sp, err = newStreamProver(provingKeyPath, provingKeyFile); err != nil {
+			return err
+		}
+	} else {
+		fmt.Println("Reading proving key file:", provingKeyPath)
+		if pk, err = readProvingKey(provingKeyPath); err != nil {
+			return err
+		}
 	}
 
 	fmt.Println("Reading witness file:", witnessPath)
-	witnessJson, err := ioutil.ReadFile(witnessPath)
+	w, err := readWitness(witnessPath)
 	if err != nil {
 		return err
 	}
-	w, err := parsers.ParseWitness(witnessJson)
-	if err != nil {
-		return err
+
+	if check {
+		fmt.Println("Checking witness with r1cs file:", r1csPath)
+		if err = checkWitness(r1csPath, w); err != nil {
+			return err
+		}
+		fmt.Println("Witness satisfies all the constraints")
+	}
+
+	var opts prover.Options
+	if tuningPath != "" {
+		fmt.Println("Reading tuning file:", tuningPath)
+		if opts.Tuning, err = prover.LoadOrCalibrate(tuningPath); err != nil {
+			return err
+		}
 	}
 
 	fmt.Println("Generating the proof")
 	beforeT := time.Now()
-	proof, pubSignals, err := prover.GenerateProof(pk, w)
+	var proof *types.Proof
+	var pubSignals []*big.Int
+	var stats *prover.Stats
+	switch {
+	case sp != nil && printStats:
+		proof, pubSignals, stats, err = sp.GenerateProofWithStats(context.Background(), w, opts)
+	case sp != nil:
+		proof, pubSignals, err = sp.GenerateProofWithOptions(context.Background(), w, opts)
+	case printStats:
+		proof, pubSignals, stats, err = prover.GenerateProofWithStats(context.Background(), pk, w, opts)
+	default:
+		proof, pubSignals, err = prover.GenerateProofWithOptions(context.Background(), pk, w, opts)
+	}
 	if err != nil {
 		return err
 	}
 	fmt.Println("proof generation time elapsed:", time.Since(beforeT))
+	if printStats {
+		fmt.Print(stats)
+	}
 
 	proofStr, err := parsers.ProofToJson(proof)
 	if err != nil {
@@ -108,6 +213,76 @@ func cmdProve(provingKeyPath, witnessPath, proofPath, publicPath string) error {
 	return nil
 }
 
+// newStreamProver reads the header of the proving key file (.zkey or
+// .go.bin), and returns the StreamProver reading its points from the file
+func newStreamProver(provingKeyPath string, f *os.File) (*prover.StreamProver, error) {
+	if strings.HasSuffix(provingKeyPath, ".zkey") {
+		pk, _, points, err := parsers.ParseZkeyHeader(f)
+		if err != nil {
+			return nil, err
+		}
+		return prover.NewStreamProver(pk, f, points), nil
+	}
+	if strings.HasSuffix(provingKeyPath, ".go.bin") {
+		pk, points, err := parsers.ParsePkGoBinHeader(f)
+		if err != nil {
+			return nil, err
+		}
+		return prover.NewStreamProver(pk, f, points), nil
+	}
+	return nil, fmt.Errorf("The streaming prover reads .zkey or .go.bin proving keys")
+}
+
+// readProvingKey reads the proving key from a snarkjs .zkey file or from a
+// proving_key.json file, depending on the file extension
+func readProvingKey(provingKeyPath string) (*types.Pk, error) {
+	if strings.HasSuffix(provingKeyPath, ".zkey") {
+		zkeyFile, err := os.Open(provingKeyPath)
+		if err != nil {
+			return nil, err
+		}
+		defer zkeyFile.Close()
+		pk, _, err := parsers.ParseZkey(zkeyFile)
+		return pk, err
+	}
+	provingKeyJson, err := ioutil.ReadFile(provingKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	return parsers.ParsePk(provingKeyJson)
+}
+
+// readWitness reads the witness from a .wtns file or from a witness.json
+// file, depending on the file extension
+func readWitness(witnessPath string) (types.Witness, error) {
+	if strings.HasSuffix(witnessPath, ".wtns") {
+		wtnsFile, err := os.Open(witnessPath)
+		if err != nil {
+			return nil, err
+		}
+		defer wtnsFile.Close()
+		return parsers.ParseWtns(wtnsFile)
+	}
+	witnessJson, err := ioutil.ReadFile(witnessPath)
+	if err != nil {
+		return nil, err
+	}
+	return parsers.ParseWitness(witnessJson)
+}
+
+func checkWitness(r1csPath string, w types.Witness) error {
+	r1csFile, err := os.Open(r1csPath)
+	if err != nil {
+		return err
+	}
+	defer r1csFile.Close()
+	cs, err := r1cs.ParseR1cs(r1csFile)
+	if err != nil {
+		return err
+	}
+	return r1cs.CheckWitness(cs, w)
+}
+
 func cmdVerify(proofPath, verificationKeyPath, publicPath string) error {
 	fmt.Println("zkSNARK Groth16 verifier")
 
@@ -165,3 +340,187 @@ func cmdConvert(provingKeyPath, provingKeyBinPath string) error {
 
 	return nil
 }
+
+func readPtau(ptauPath string) (*ptau.Ptau, error) {
+	ptauFile, err := os.Open(ptauPath)
+	if err != nil {
+		return nil, err
+	}
+	defer ptauFile.Close()
+	return ptau.ParsePtau(ptauFile)
+}
+
+func writePtau(ptauPath string, p *ptau.Ptau) error {
+	b, err := ptau.PtauToBytes(p)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(ptauPath, b, 0644); err != nil {
+		return err
+	}
+	fmt.Println("Powers of tau stored at:", ptauPath)
+	return nil
+}
+
+func cmdPtauNew(power int, ptauOutPath string) error {
+	fmt.Println("Powers of tau, new ceremony for 2^power constraints, power:", power)
+	p, err := ptau.NewPtau(power)
+	if err != nil {
+		return err
+	}
+	return writePtau(ptauOutPath, p)
+}
+
+func cmdPtauContribute(ptauPath, ptauOutPath, name string) error {
+	fmt.Println("Powers of tau contribution")
+	fmt.Println("Reading powers of tau file:", ptauPath)
+	p, err := readPtau(ptauPath)
+	if err != nil {
+		return err
+	}
+	beforeT := time.Now()
+	if err = ptau.Contribute(p, name, nil); err != nil {
+		return err
+	}
+	fmt.Println("contribution time elapsed:", time.Since(beforeT))
+	fmt.Println("contribution hash:", hex.EncodeToString(p.Contributions[len(p.Contributions)-1].NextChallenge))
+	return writePtau(ptauOutPath, p)
+}
+
+func cmdPtauBeacon(ptauPath, ptauOutPath, name, beaconHash string, beaconIterExp int) error {
+	fmt.Println("Powers of tau beacon contribution")
+	beacon, err := hex.DecodeString(beaconHash)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Reading powers of tau file:", ptauPath)
+	p, err := readPtau(ptauPath)
+	if err != nil {
+		return err
+	}
+	if err = ptau.Beacon(p, name, beacon, beaconIterExp); err != nil {
+		return err
+	}
+	fmt.Println("contribution hash:", hex.EncodeToString(p.Contributions[len(p.Contributions)-1].NextChallenge))
+	return writePtau(ptauOutPath, p)
+}
+
+func cmdPtauVerify(ptauPath string) error {
+	fmt.Println("Powers of tau verifier")
+	fmt.Println("Reading powers of tau file:", ptauPath)
+	p, err := readPtau(ptauPath)
+	if err != nil {
+		return err
+	}
+	for i, c := range p.Contributions {
+		fmt.Printf("contribution #%d %s: %s\n", i+1, c.Name, hex.EncodeToString(c.NextChallenge))
+	}
+	err = ptau.Verify(p)
+	fmt.Println("verification:", err == nil)
+	return err
+}
+
+func readPhase2Key(provingKeyBinPath, verificationKeyPath string) (*types.Pk, *types.Vk, error) {
+	pkFile, err := os.Open(provingKeyBinPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer pkFile.Close()
+	pk, err := parsers.ParsePkGoBin(pkFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	vkJson, err := ioutil.ReadFile(verificationKeyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	vk, err := parsers.ParseVk(vkJson)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pk, vk, nil
+}
+
+// readContributions reads the phase 2 contributions, a missing file means
+// that there are no contributions yet
+func readContributions(contributionsPath string) ([]*phase2.Contribution, error) {
+	var contributions []*phase2.Contribution
+	contributionsJson, err := ioutil.ReadFile(contributionsPath)
+	if os.IsNotExist(err) {
+		return contributions, nil
+	} else if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(contributionsJson, &contributions)
+	return contributions, err
+}
+
+func cmdPhase2Contribute(provingKeyBinPath, verificationKeyPath, contributionsPath, provingKeyBinOutPath, verificationKeyOutPath, name string) error {
+	fmt.Println("Phase 2 contribution")
+	fmt.Println("Reading keys:", provingKeyBinPath, verificationKeyPath)
+	pk, vk, err := readPhase2Key(provingKeyBinPath, verificationKeyPath)
+	if err != nil {
+		return err
+	}
+	contributions, err := readContributions(contributionsPath)
+	if err != nil {
+		return err
+	}
+
+	beforeT := time.Now()
+	c, err := phase2.Contribute(pk, vk, contributions, name, nil)
+	if err != nil {
+		return err
+	}
+	contributions = append(contributions, c)
+	fmt.Println("contribution time elapsed:", time.Since(beforeT))
+
+	pkGBin, err := parsers.PkToGoBin(pk)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(provingKeyBinOutPath, pkGBin, 0644); err != nil {
+		return err
+	}
+	vkJson, err := parsers.VkToJson(vk)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(verificationKeyOutPath, vkJson, 0644); err != nil {
+		return err
+	}
+	contributionsJson, err := json.Marshal(contributions)
+	if err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(contributionsPath, contributionsJson, 0644); err != nil {
+		return err
+	}
+	fmt.Println("Keys stored at:", provingKeyBinOutPath, verificationKeyOutPath)
+	fmt.Println("Contributions stored at:", contributionsPath)
+	return nil
+}
+
+func cmdPhase2Verify(provingKeyBinInitialPath, verificationKeyInitialPath, provingKeyBinPath, verificationKeyPath, contributionsPath string) error {
+	fmt.Println("Phase 2 verifier")
+	fmt.Println("Reading initial keys:", provingKeyBinInitialPath, verificationKeyInitialPath)
+	initialPk, initialVk, err := readPhase2Key(provingKeyBinInitialPath, verificationKeyInitialPath)
+	if err != nil {
+		return err
+	}
+	fmt.Println("Reading keys:", provingKeyBinPath, verificationKeyPath)
+	pk, vk, err := readPhase2Key(provingKeyBinPath, verificationKeyPath)
+	if err != nil {
+		return err
+	}
+	contributions, err := readContributions(contributionsPath)
+	if err != nil {
+		return err
+	}
+	for i, c := range contributions {
+		fmt.Printf("contribution #%d %s: %s\n", i+1, c.Name, hex.EncodeToString(c.Transcript))
+	}
+	err = phase2.Verify(initialPk, initialVk, pk, vk, contributions)
+	fmt.Println("verification:", err == nil)
+	return err
+}
@@ -6,4 +6,5 @@ require (
 	github.com/ethereum/go-ethereum v1.9.13
 	github.com/iden3/go-iden3-crypto v0.0.5
 	github.com/stretchr/testify v1.4.0
+	golang.org/x/sys v0.0.0-20200302150141-5c8b2ff67527
 )
@@ -0,0 +1,235 @@
+// Package binfile reads the iden3 binary file format, shared by the .zkey,
+// .wtns, .r1cs and .ptau files, and converts the points of the files, whose
+// coordinates are stored in little endian Montgomery form
+package binfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-circom-prover-verifier/types"
+)
+
+// rInv is the inverse of the Montgomery factor 2^256 mod Q
+var rInv = new(big.Int).ModInverse(new(big.Int).Lsh(big.NewInt(1), 256), types.Q)
+
+// ReadNBytes reads exactly n bytes from r
+func ReadNBytes(r io.Reader, n int) ([]byte, error) {
+	b := make([]byte, n)
+	_, err := io.ReadFull(r, b)
+	if err != nil {
+		return b, err
+	}
+	return b, nil
+}
+
+// SwapEndianness swaps the order of the bytes in the slice.
+func SwapEndianness(b []byte) []byte {
+	o := make([]byte, len(b))
+	for i := range b {
+		o[len(b)-1-i] = b[i]
+	}
+	return o
+}
+
+// Section contains the position and the size of a section of a file
+type Section struct {
+	Pos  int64
+	Size int64
+}
+
+// ReadHeader reads the header of a file in the iden3 binary file format,
+// checking the file type and that the version is not above maxVersion, and
+// returns the version and the sections of the file indexed by section type
+func ReadHeader(f *os.File, fileType string, maxVersion int) (int, map[int]Section, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return 0, nil, err
+	}
+	b, err := ReadNBytes(f, 12)
+	if err != nil {
+		return 0, nil, err
+	}
+	if string(b[:4]) != fileType {
+		return 0, nil, fmt.Errorf("Invalid file type, expected: %s, actual: %s", fileType, b[:4])
+	}
+	version := int(binary.LittleEndian.Uint32(b[4:8]))
+	if version > maxVersion {
+		return 0, nil, fmt.Errorf("Unsupported %s version: %v", fileType, version)
+	}
+	nSections := int(binary.LittleEndian.Uint32(b[8:12]))
+
+	o := int64(12)
+	sections := make(map[int]Section)
+	for i := 0; i < nSections; i++ {
+		b, err = ReadNBytes(f, 12)
+		if err != nil {
+			return 0, nil, err
+		}
+		sType := int(binary.LittleEndian.Uint32(b[:4]))
+		sSize := int64(binary.LittleEndian.Uint64(b[4:12]))
+		o += 12
+		if _, ok := sections[sType]; ok {
+			return 0, nil, fmt.Errorf("Duplicated section %v in %s file", sType, fileType)
+		}
+		sections[sType] = Section{Pos: o, Size: sSize}
+		o += sSize
+		if _, err = f.Seek(o, io.SeekStart); err != nil {
+			return 0, nil, err
+		}
+	}
+	return version, sections, nil
+}
+
+// StartReadSection positions the file at the beginning of the given section,
+// and returns a reader of the section content and its size
+func StartReadSection(f *os.File, sections map[int]Section, sType int) (*bufio.Reader, int64, error) {
+	s, ok := sections[sType]
+	if !ok {
+		return nil, 0, fmt.Errorf("Missing section %v", sType)
+	}
+	if _, err := f.Seek(s.Pos, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+	return bufio.NewReader(io.LimitReader(f, s.Size)), s.Size, nil
+}
+
+// ReadFieldHeader reads the size in bytes and the prime of a field, checking
+// that it matches the expected one
+func ReadFieldHeader(r io.Reader, q *big.Int) error {
+	b, err := ReadNBytes(r, 4)
+	if err != nil {
+		return err
+	}
+	n8 := int(binary.LittleEndian.Uint32(b[:4]))
+	if n8 != 32 {
+		return fmt.Errorf("Unexpected field size, expected: 32, actual: %v", n8)
+	}
+	b, err = ReadNBytes(r, n8)
+	if err != nil {
+		return err
+	}
+	p := new(big.Int).SetBytes(SwapEndianness(b))
+	if p.Cmp(q) != 0 {
+		return fmt.Errorf("Unexpected field prime, expected: %s, actual: %s", q, p)
+	}
+	return nil
+}
+
+// coordFromMont converts a little endian coordinate in Montgomery form into
+// a big endian 32 bytes coordinate
+func coordFromMont(m []byte) []byte {
+	v := new(big.Int).SetBytes(SwapEndianness(m))
+	v.Mul(v, rInv)
+	v.Mod(v, types.Q)
+	b := make([]byte, 32)
+	vb := v.Bytes()
+	copy(b[32-len(vb):], vb)
+	return b
+}
+
+// coordToMont converts a big endian 32 bytes coordinate into a little endian
+// coordinate in Montgomery form
+func coordToMont(c []byte) []byte {
+	v := new(big.Int).SetBytes(c)
+	v.Lsh(v, 256)
+	v.Mod(v, types.Q)
+	b := make([]byte, 32)
+	vb := v.Bytes()
+	copy(b[32-len(vb):], vb)
+	return SwapEndianness(b)
+}
+
+// isOne returns whether the big endian coordinate is 1
+func isOne(c []byte) bool {
+	for i := 0; i < len(c)-1; i++ {
+		if c[i] != 0 {
+			return false
+		}
+	}
+	return c[len(c)-1] == 1
+}
+
+// isZero returns whether the big endian coordinate is 0
+func isZero(c []byte) bool {
+	for i := range c {
+		if c[i] != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// G1FromMont converts the 64 bytes of the point in little endian Montgomery
+// form into a G1 point.  The point at infinity is (0, 0), or (0, 1) as the
+// older snarkjs versions write it.
+func G1FromMont(m []byte) (*bn256.G1, error) {
+	x, y := coordFromMont(m[:32]), coordFromMont(m[32:64])
+	if isZero(x) && isOne(y) {
+		y = make([]byte, 32)
+	}
+	p := new(bn256.G1)
+	_, err := p.Unmarshal(append(x, y...))
+	return p, err
+}
+
+// G1ToMont converts the G1 point into 64 bytes in little endian Montgomery
+// form
+func G1ToMont(p *bn256.G1) []byte {
+	b := p.Marshal()
+	var m []byte
+	m = append(m, coordToMont(b[:32])...)
+	m = append(m, coordToMont(b[32:64])...)
+	return m
+}
+
+// G2FromMont converts the point from the layout of the files (x.c0, x.c1,
+// y.c0, y.c1) into a G2 point.  The point at infinity is (0, 0), or (0, 1)
+// as the older snarkjs versions write it.
+func G2FromMont(m []byte) (*bn256.G2, error) {
+	var b []byte
+	b = append(b, coordFromMont(m[32:64])...)
+	b = append(b, coordFromMont(m[:32])...)
+	b = append(b, coordFromMont(m[96:128])...)
+	b = append(b, coordFromMont(m[64:96])...)
+	if isZero(b[:96]) && isOne(b[96:]) {
+		b[127] = 0
+	}
+	p := new(bn256.G2)
+	_, err := p.Unmarshal(b)
+	return p, err
+}
+
+// G2ToMont converts the G2 point into the 128 bytes of the layout of the
+// files
+func G2ToMont(p *bn256.G2) []byte {
+	b := p.Marshal()
+	var m []byte
+	m = append(m, coordToMont(b[32:64])...)
+	m = append(m, coordToMont(b[:32])...)
+	m = append(m, coordToMont(b[96:128])...)
+	m = append(m, coordToMont(b[64:96])...)
+	return m
+}
+
+// ReadG1 reads a G1 point in little endian Montgomery form
+func ReadG1(r io.Reader) (*bn256.G1, error) {
+	b, err := ReadNBytes(r, 64)
+	if err != nil {
+		return nil, err
+	}
+	return G1FromMont(b)
+}
+
+// ReadG2 reads a G2 point in the layout of the files
+func ReadG2(r io.Reader) (*bn256.G2, error) {
+	b, err := ReadNBytes(r, 128)
+	if err != nil {
+		return nil, err
+	}
+	return G2FromMont(b)
+}
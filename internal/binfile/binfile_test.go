@@ -0,0 +1,45 @@
+package binfile
+
+import (
+	"math/big"
+	"testing"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPointsMont(t *testing.T) {
+	for _, k := range []int64{1, 2, 12345} {
+		p1 := new(bn256.G1).ScalarBaseMult(big.NewInt(k))
+		q1, err := G1FromMont(G1ToMont(p1))
+		require.Nil(t, err)
+		assert.Equal(t, p1.Marshal(), q1.Marshal())
+
+		p2 := new(bn256.G2).ScalarBaseMult(big.NewInt(k))
+		q2, err := G2FromMont(G2ToMont(p2))
+		require.Nil(t, err)
+		assert.Equal(t, p2.Marshal(), q2.Marshal())
+	}
+
+	// the point at infinity as (0, 0) and as (0, 1)
+	zero1 := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	zero2 := new(bn256.G2).ScalarBaseMult(big.NewInt(0))
+	one := coordToMont(append(make([]byte, 31), 1))
+	for _, m := range [][]byte{make([]byte, 64), append(make([]byte, 32), one...)} {
+		p, err := G1FromMont(m)
+		require.Nil(t, err)
+		assert.Equal(t, zero1.Marshal(), p.Marshal())
+	}
+	for _, m := range [][]byte{make([]byte, 128), append(make([]byte, 64), append(one, make([]byte, 32)...)...)} {
+		p, err := G2FromMont(m)
+		require.Nil(t, err)
+		assert.Equal(t, zero2.Marshal(), p.Marshal())
+	}
+
+	// a point outside the curve
+	m := G1ToMont(new(bn256.G1).ScalarBaseMult(big.NewInt(3)))
+	m[0] ^= 1
+	_, err := G1FromMont(m)
+	assert.NotNil(t, err)
+}
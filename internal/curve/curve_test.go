@@ -0,0 +1,511 @@
+package curve
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"math/big"
+	"testing"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func randFp(t *testing.T) (*big.Int, Fp) {
+	v, err := rand.Int(rand.Reader, Q)
+	require.Nil(t, err)
+	var e Fp
+	e.SetBigInt(v)
+	return v, e
+}
+
+func TestFp(t *testing.T) {
+	var one Fp
+	one.SetOne()
+	assert.Equal(t, big.NewInt(1), one.BigInt())
+
+	for i := 0; i < 100; i++ {
+		a, ea := randFp(t)
+		b, eb := randFp(t)
+		var r Fp
+		assert.Equal(t, a, ea.BigInt())
+		assert.Equal(t, new(big.Int).Mod(new(big.Int).Add(a, b), Q), r.Add(&ea, &eb).BigInt())
+		assert.Equal(t, new(big.Int).Mod(new(big.Int).Sub(a, b), Q), r.Sub(&ea, &eb).BigInt())
+		assert.Equal(t, new(big.Int).Mod(new(big.Int).Neg(a), Q), r.Neg(&ea).BigInt())
+		assert.Equal(t, new(big.Int).Mod(new(big.Int).Mul(a, b), Q), r.Mul(&ea, &eb).BigInt())
+		assert.Equal(t, new(big.Int).ModInverse(a, Q), r.Inverse(&ea).BigInt())
+	}
+
+	// the largest element
+	var e Fp
+	qMinus1 := new(big.Int).Sub(Q, big.NewInt(1))
+	e.SetBigInt(qMinus1)
+	assert.Equal(t, big.NewInt(1), new(Fp).Mul(&e, &e).BigInt())
+
+	b := Q.Bytes()
+	assert.NotNil(t, e.SetBytes(b))
+	assert.NotNil(t, e.SetBytes(b[1:]))
+}
+
+func TestFp2(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		_, a0 := randFp(t)
+		_, a1 := randFp(t)
+		_, b0 := randFp(t)
+		_, b1 := randFp(t)
+		a, b := Fp2{a0, a1}, Fp2{b0, b1}
+
+		// (a0 + a1·i)(b0 + b1·i) = a0·b0 - a1·b1 + (a0·b1 + a1·b0)·i
+		var expected Fp2
+		var t0, t1 Fp
+		expected.A0.Sub(t0.Mul(&a0, &b0), t1.Mul(&a1, &b1))
+		expected.A1.Add(t0.Mul(&a0, &b1), t1.Mul(&a1, &b0))
+		var r Fp2
+		assert.Equal(t, expected, *r.Mul(&a, &b))
+		assert.Equal(t, *r.Mul(&a, &a), *new(Fp2).Square(&a))
+
+		var one Fp2
+		one.SetOne()
+		r.Inverse(&a)
+		assert.Equal(t, one, *r.Mul(&r, &a))
+
+		buf := make([]byte, 64)
+		a.PutBytes(buf)
+		require.Nil(t, r.SetBytes(buf))
+		assert.Equal(t, a, r)
+	}
+}
+
+func randG1(t *testing.T) (*big.Int, *bn256.G1) {
+	k, p, err := bn256.RandomG1(rand.Reader)
+	require.Nil(t, err)
+	return k, p
+}
+
+func TestG1(t *testing.T) {
+	_, p := randG1(t)
+	_, q := randG1(t)
+	pa, qa := G1FromBn256(p), G1FromBn256(q)
+	assert.True(t, pa.IsOnCurve())
+	assert.Equal(t, p.Marshal(), pa.Marshal())
+	assert.Equal(t, p.Marshal(), pa.Bn256().Marshal())
+
+	var pj, qj G1Jac
+	pj.FromAffine(&pa)
+	qj.FromAffine(&qa)
+
+	// addition, mixed addition and doubling
+	pq := new(bn256.G1).Add(p, q)
+	r := pj
+	assert.Equal(t, pq.Marshal(), r.AddMixed(&qa).Bn256().Marshal())
+	r.Double(&r)
+	r2 := pj
+	r2.Add(&qj)
+	r2.Add(&r2)
+	assert.True(t, r.Equal(&r2))
+	assert.Equal(t, new(bn256.G1).Add(pq, pq).Marshal(), r2.Bn256().Marshal())
+
+	// with a point not in affine coordinates
+	r.Double(&pj)
+	r.Add(&qj)
+	r.AddMixed(&pa)
+	expected := new(bn256.G1).Add(new(bn256.G1).ScalarMult(p, big.NewInt(3)), q)
+	assert.Equal(t, expected.Marshal(), r.Bn256().Marshal())
+	r2.Double(&pj)
+	r2.Add(&pj)
+	r2.Add(&qj)
+	assert.True(t, r.Equal(&r2))
+
+	// equal points and opposite points
+	r.Double(&pj)
+	r2 = pj
+	assert.True(t, r.Equal(r2.AddMixed(&pa)))
+	r2 = pj
+	assert.True(t, r.Equal(r2.Add(&pj)))
+	var na G1Affine
+	na.Neg(&pa)
+	r = pj
+	assert.True(t, r.AddMixed(&na).IsInfinity())
+	var nj G1Jac
+	nj.Neg(&pj)
+	r = pj
+	assert.True(t, r.Add(&nj).IsInfinity())
+
+	// the point at infinity
+	var inf G1Jac
+	var infa G1Affine
+	r = pj
+	assert.True(t, r.Add(&inf).Equal(&pj))
+	assert.True(t, r.AddMixed(&infa).Equal(&pj))
+	r = inf
+	assert.True(t, r.Add(&pj).Equal(&pj))
+	r = inf
+	assert.True(t, r.AddMixed(&pa).Equal(&pj))
+	assert.True(t, r.Double(&inf).IsInfinity())
+	assert.True(t, infa.IsOnCurve())
+	zero := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	za := G1FromBn256(zero)
+	assert.True(t, za.IsInfinity())
+	assert.Equal(t, zero.Marshal(), inf.Bn256().Marshal())
+	assert.False(t, pj.Equal(&inf))
+
+	// marshaling
+	var u G1Affine
+	require.Nil(t, u.Unmarshal(pa.Marshal()))
+	assert.Equal(t, pa, u)
+	b := pa.Marshal()
+	b[63] ^= 1
+	assert.NotNil(t, u.Unmarshal(b))
+	assert.NotNil(t, u.Unmarshal(b[1:]))
+}
+
+func TestBatchNormalizeG1(t *testing.T) {
+	var jac []G1Jac
+	var expected [][]byte
+	var r G1Jac
+	for i := 0; i < 10; i++ {
+		_, p := randG1(t)
+		a := G1FromBn256(p)
+		r.Double(&r)
+		r.AddMixed(&a)
+		if i == 4 {
+			jac = append(jac, G1Jac{})
+		}
+		jac = append(jac, r)
+		expected = append(expected, r.Bn256().Marshal())
+	}
+	aff := make([]G1Affine, len(jac))
+	BatchNormalizeG1(aff, jac)
+	assert.True(t, aff[4].IsInfinity())
+	aff = append(aff[:4], aff[5:]...)
+	for i := range aff {
+		assert.Equal(t, expected[i], aff[i].Marshal())
+	}
+	BatchNormalizeG1(nil, nil)
+}
+
+func TestBatchAddG1(t *testing.T) {
+	var p, q []G1Affine
+	for i := 0; i < 5; i++ {
+		_, a := randG1(t)
+		_, b := randG1(t)
+		p = append(p, G1FromBn256(a))
+		q = append(q, G1FromBn256(b))
+	}
+	// infinity + a, a + infinity, a + a, a + -a, infinity + infinity
+	var n G1Affine
+	n.Neg(&q[0])
+	p = append(p, G1Affine{}, q[1], q[2], q[0], G1Affine{})
+	q = append(q, q[0], G1Affine{}, q[2], n, G1Affine{})
+
+	var expected [][]byte
+	pp := make([]*G1Affine, len(p))
+	qp := make([]*G1Affine, len(q))
+	for i := range p {
+		var r G1Jac
+		r.FromAffine(&p[i])
+		r.AddMixed(&q[i])
+		expected = append(expected, r.Bn256().Marshal())
+		pp[i], qp[i] = &p[i], &q[i]
+	}
+	BatchAddG1(pp, qp, make([]Fp, len(p)))
+	for i := range p {
+		assert.True(t, p[i].IsOnCurve())
+		assert.Equal(t, expected[i], p[i].Marshal())
+	}
+	assert.True(t, p[len(p)-2].IsInfinity())
+	BatchAddG1(nil, nil, nil)
+}
+
+func randG2(t *testing.T) (*big.Int, *bn256.G2) {
+	k, p, err := bn256.RandomG2(rand.Reader)
+	require.Nil(t, err)
+	return k, p
+}
+
+func TestG2(t *testing.T) {
+	_, p := randG2(t)
+	_, q := randG2(t)
+	pa, qa := G2FromBn256(p), G2FromBn256(q)
+	assert.True(t, pa.IsOnCurve())
+	assert.Equal(t, p.Marshal(), pa.Marshal())
+	assert.Equal(t, p.Marshal(), pa.Bn256().Marshal())
+
+	var pj, qj G2Jac
+	pj.FromAffine(&pa)
+	qj.FromAffine(&qa)
+
+	pq := new(bn256.G2).Add(p, q)
+	r := pj
+	assert.Equal(t, pq.Marshal(), r.AddMixed(&qa).Bn256().Marshal())
+	r.Double(&r)
+	r2 := pj
+	r2.Add(&qj)
+	r2.Add(&r2)
+	assert.True(t, r.Equal(&r2))
+	assert.Equal(t, new(bn256.G2).Add(pq, pq).Marshal(), r2.Bn256().Marshal())
+
+	r.Double(&pj)
+	r.Add(&qj)
+	r.AddMixed(&pa)
+	expected := new(bn256.G2).Add(new(bn256.G2).ScalarMult(p, big.NewInt(3)), q)
+	assert.Equal(t, expected.Marshal(), r.Bn256().Marshal())
+
+	r.Double(&pj)
+	r2 = pj
+	assert.True(t, r.Equal(r2.AddMixed(&pa)))
+	var na G2Affine
+	na.Neg(&pa)
+	r = pj
+	assert.True(t, r.AddMixed(&na).IsInfinity())
+
+	var inf G2Jac
+	zero := new(bn256.G2).ScalarBaseMult(big.NewInt(0))
+	za := G2FromBn256(zero)
+	assert.True(t, za.IsInfinity())
+	assert.Equal(t, zero.Marshal(), inf.Bn256().Marshal())
+
+	var u G2Affine
+	require.Nil(t, u.Unmarshal(pa.Marshal()))
+	assert.Equal(t, pa, u)
+	b := pa.Marshal()
+	b[127] ^= 1
+	assert.NotNil(t, u.Unmarshal(b))
+
+	var jac []G2Jac
+	var exp [][]byte
+	for i := 0; i < 5; i++ {
+		r.Double(&r)
+		r.AddMixed(&qa)
+		jac = append(jac, r)
+		exp = append(exp, r.Bn256().Marshal())
+	}
+	aff := make([]G2Affine, len(jac))
+	BatchNormalizeG2(aff, jac)
+	for i := range aff {
+		assert.Equal(t, exp[i], aff[i].Marshal())
+	}
+}
+
+func TestBatchAddG2(t *testing.T) {
+	var p, q []G2Affine
+	for i := 0; i < 5; i++ {
+		_, a := randG2(t)
+		_, b := randG2(t)
+		p = append(p, G2FromBn256(a))
+		q = append(q, G2FromBn256(b))
+	}
+	// infinity + a, a + infinity, a + a, a + -a, infinity + infinity
+	var n G2Affine
+	n.Neg(&q[0])
+	p = append(p, G2Affine{}, q[1], q[2], q[0], G2Affine{})
+	q = append(q, q[0], G2Affine{}, q[2], n, G2Affine{})
+
+	var expected [][]byte
+	pp := make([]*G2Affine, len(p))
+	qp := make([]*G2Affine, len(q))
+	for i := range p {
+		var r G2Jac
+		r.FromAffine(&p[i])
+		r.AddMixed(&q[i])
+		expected = append(expected, r.Bn256().Marshal())
+		pp[i], qp[i] = &p[i], &q[i]
+	}
+	BatchAddG2(pp, qp, make([]Fp2, len(p)))
+	for i := range p {
+		assert.True(t, p[i].IsOnCurve())
+		assert.Equal(t, expected[i], p[i].Marshal())
+	}
+	assert.True(t, p[len(p)-2].IsInfinity())
+	BatchAddG2(nil, nil, nil)
+}
+
+func TestSplitScalars(t *testing.T) {
+	r := bn256.Order
+	lambda := bigFromBase10("4407920970296243842393367215006156084916469457145843978461")
+	mu := new(big.Int).Mod(Q, r)
+	toBig := func(x [4]uint64) *big.Int {
+		b := make([]byte, 32)
+		for i := 0; i < 4; i++ {
+			for j := 0; j < 8; j++ {
+				b[31-8*i-j] = byte(x[i] >> (8 * uint(j)))
+			}
+		}
+		return new(big.Int).SetBytes(b)
+	}
+	toLimbs := func(v *big.Int) [4]uint64 {
+		var x [4]uint64
+		for i := 0; i < 256; i++ {
+			x[i/64] |= uint64(v.Bit(i)) << uint(i%64)
+		}
+		return x
+	}
+	var ks []*big.Int
+	for _, v := range []int64{0, 1, 2} {
+		ks = append(ks, big.NewInt(v))
+	}
+	ks = append(ks, new(big.Int).Sub(r, big.NewInt(1)), new(big.Int).Rsh(r, 1),
+		new(big.Int).Lsh(big.NewInt(1), 128), new(big.Int).Lsh(big.NewInt(1), 64))
+	for i := 0; i < 200; i++ {
+		k, err := rand.Int(rand.Reader, r)
+		require.Nil(t, err)
+		ks = append(ks, k)
+	}
+	for _, k := range ks {
+		kl := toLimbs(k)
+		k1, k2 := SplitG1(&kl)
+		assert.True(t, toBig(k1).BitLen() <= 129)
+		assert.True(t, toBig(k2).BitLen() <= 129)
+		v := new(big.Int).Mul(toBig(k2), lambda)
+		v.Add(v, toBig(k1)).Mod(v, r)
+		assert.Equal(t, 0, k.Cmp(v))
+
+		parts := SplitG2(&kl)
+		v.SetInt64(0)
+		m := big.NewInt(1)
+		for _, p := range parts {
+			assert.True(t, toBig(p).BitLen() <= 66)
+			v.Add(v, new(big.Int).Mul(toBig(p), m))
+			m.Mul(m, mu)
+		}
+		assert.Equal(t, 0, k.Cmp(v.Mod(v, r)))
+	}
+}
+
+func TestEndomorphisms(t *testing.T) {
+	lambda := bigFromBase10("4407920970296243842393367215006156084916469457145843978461")
+	_, p := randG1(t)
+	pa := G1FromBn256(p)
+	var e G1Affine
+	e.Endo(&pa)
+	assert.Equal(t, new(bn256.G1).ScalarMult(p, lambda).Marshal(), e.Marshal())
+
+	mu := new(big.Int).Mod(Q, bn256.Order)
+	_, q := randG2(t)
+	qa := G2FromBn256(q)
+	var e2 G2Affine
+	e2.Psi(&qa)
+	assert.Equal(t, new(bn256.G2).ScalarMult(q, mu).Marshal(), e2.Marshal())
+
+	var inf G2Affine
+	assert.True(t, e2.Psi(&inf).IsInfinity())
+}
+
+func TestScalarMulGLV(t *testing.T) {
+	_, p := randG1(t)
+	pa := G1FromBn256(p)
+	for _, k := range []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(7), new(big.Int).Sub(bn256.Order, big.NewInt(1))} {
+		l := mod256(k)
+		assert.Equal(t, new(bn256.G1).ScalarMult(p, k).Marshal(), new(G1Jac).ScalarMulGLV(&pa, &l).Bn256().Marshal())
+	}
+	for i := 0; i < 8; i++ {
+		k, err := rand.Int(rand.Reader, bn256.Order)
+		require.Nil(t, err)
+		l := mod256(k)
+		assert.Equal(t, new(bn256.G1).ScalarMult(p, k).Marshal(), new(G1Jac).ScalarMulGLV(&pa, &l).Bn256().Marshal())
+	}
+	var inf G1Affine
+	l := mod256(big.NewInt(5))
+	assert.True(t, new(G1Jac).ScalarMulGLV(&inf, &l).IsInfinity())
+}
+
+// putMontLE writes the limbs of the elements in little endian
+func putMontLE(e ...Fp) []byte {
+	var b []byte
+	for i := range e {
+		for _, l := range e[i] {
+			var w [8]byte
+			binary.LittleEndian.PutUint64(w[:], l)
+			b = append(b, w[:]...)
+		}
+	}
+	return b
+}
+
+func TestUnmarshalMontLE(t *testing.T) {
+	_, p := randG1(t)
+	pa := G1FromBn256(p)
+	var a G1Affine
+	require.Nil(t, a.UnmarshalMontLE(putMontLE(pa.X, pa.Y)))
+	assert.Equal(t, pa, a)
+	var one Fp
+	one.SetOne()
+	for _, b := range [][]byte{putMontLE(Fp{}, Fp{}), putMontLE(Fp{}, one)} {
+		require.Nil(t, a.UnmarshalMontLE(b))
+		assert.True(t, a.IsInfinity())
+	}
+	assert.NotNil(t, a.UnmarshalMontLE(putMontLE(pa.X, pa.X)))
+	assert.NotNil(t, a.UnmarshalMontLE(putMontLE(q, pa.Y)))
+	assert.NotNil(t, a.UnmarshalMontLE(putMontLE(pa.X)))
+
+	_, p2 := randG2(t)
+	pa2 := G2FromBn256(p2)
+	var a2 G2Affine
+	require.Nil(t, a2.UnmarshalMontLE(putMontLE(pa2.X.A0, pa2.X.A1, pa2.Y.A0, pa2.Y.A1)))
+	assert.Equal(t, pa2, a2)
+	for _, b := range [][]byte{putMontLE(Fp{}, Fp{}, Fp{}, Fp{}), putMontLE(Fp{}, Fp{}, one, Fp{})} {
+		require.Nil(t, a2.UnmarshalMontLE(b))
+		assert.True(t, a2.IsInfinity())
+	}
+	assert.NotNil(t, a2.UnmarshalMontLE(putMontLE(pa2.X.A0, pa2.X.A1, pa2.Y.A1, pa2.Y.A0)))
+}
+
+func TestSqrt(t *testing.T) {
+	for i := 0; i < 20; i++ {
+		_, a := randFp(t)
+		var a2, r, r2 Fp
+		a2.Square(&a)
+		require.True(t, r.Sqrt(&a2))
+		assert.Equal(t, a2, *r2.Square(&r))
+		// -1 is not a square, as q = 3 mod 4
+		var na2 Fp
+		na2.Neg(&a2)
+		assert.False(t, r.Sqrt(&na2))
+
+		_, b0 := randFp(t)
+		_, b1 := randFp(t)
+		b := Fp2{b0, b1}
+		var b2, s, s2 Fp2
+		b2.Square(&b)
+		require.True(t, s.Sqrt(&b2))
+		assert.Equal(t, b2, *s2.Square(&s))
+		// a non square of Fp is a square of Fp2
+		na2x := Fp2{A0: na2}
+		require.True(t, s.Sqrt(&na2x))
+		assert.Equal(t, na2x, *s2.Square(&s))
+	}
+
+	// sign of the elements
+	var e Fp
+	assert.False(t, e.SetBigInt(qMinus1Half).IsNegative())
+	assert.True(t, e.SetBigInt(new(big.Int).Add(qMinus1Half, big.NewInt(1))).IsNegative())
+	var e2 Fp2
+	e2.SetBigInts(big.NewInt(-1), big.NewInt(0))
+	assert.True(t, e2.IsNegative())
+	e2.SetBigInts(big.NewInt(-1), big.NewInt(1))
+	assert.False(t, e2.IsNegative())
+}
+
+func TestSetXScalarMul(t *testing.T) {
+	k, p := randG1(t)
+	pa := G1FromBn256(p)
+	var a G1Affine
+	require.True(t, a.SetX(&pa.X, pa.Y.IsNegative()))
+	assert.Equal(t, pa, a)
+	require.True(t, a.SetX(&pa.X, !pa.Y.IsNegative()))
+	assert.Equal(t, *new(G1Affine).Neg(&pa), a)
+	var g G1Jac
+	g.FromAffine(&G1Affine{X: *new(Fp).SetOne(), Y: *new(Fp).SetBigInt(big.NewInt(2))})
+	assert.Equal(t, p.Marshal(), new(G1Jac).ScalarMul(&g, k).Bn256().Marshal())
+
+	k, q := randG2(t)
+	qa := G2FromBn256(q)
+	var a2 G2Affine
+	require.True(t, a2.SetX(&qa.X, qa.Y.IsNegative()))
+	assert.Equal(t, qa, a2)
+	var g2 G2Jac
+	g2.FromAffine(&qa)
+	k2 := new(big.Int).Add(k, big.NewInt(5))
+	expected := new(bn256.G2).ScalarMult(q, k2)
+	assert.Equal(t, expected.Marshal(), new(G2Jac).ScalarMul(&g2, k2).Bn256().Marshal())
+}
@@ -0,0 +1,204 @@
+// Package curve implements the arithmetic of the G1 and G2 groups of the
+// BN254 curve used by the prover, with affine points, mixed addition,
+// dedicated doubling and batch normalization, which the bn256 package does
+// not expose.
+package curve
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"math/bits"
+)
+
+// Fp is an element of the base field of the curve, in Montgomery form, as
+// little endian 64 bit limbs
+type Fp [4]uint64
+
+// limbs of the modulus of the base field, and -q^-1 mod 2^64
+const (
+	q0      = 0x3c208c16d87cfd47
+	q1      = 0x97816a916871ca8d
+	q2      = 0xb85045b68181585d
+	q3      = 0x30644e72e131a029
+	qInvNeg = 0x87d20782e4866389
+)
+
+var (
+	// q is the modulus of the base field
+	q = Fp{q0, q1, q2, q3}
+	// rSquare is 2^512 mod q
+	rSquare = Fp{0xf32cfc5b538afa89, 0xb5e71911d44501fb, 0x47ab1eff0a417ff6, 0x06d89f71cab8351f}
+	// qMinus2 is the exponent of the inverse
+	qMinus2 = [4]uint64{0x3c208c16d87cfd45, 0x97816a916871ca8d, 0xb85045b68181585d, 0x30644e72e131a029}
+	// fpOne is 1 in Montgomery form
+	fpOne Fp
+)
+
+// Q is the modulus of the base field
+var Q, _ = new(big.Int).SetString("21888242871839275222246405745257275088696311157297823662689037894645226208583", 10)
+
+var bigThree = big.NewInt(3)
+
+func init() {
+	fpOne.Mul(&Fp{1}, &rSquare)
+}
+
+// SetZero sets z = 0
+func (z *Fp) SetZero() *Fp {
+	*z = Fp{}
+	return z
+}
+
+// SetOne sets z = 1
+func (z *Fp) SetOne() *Fp {
+	*z = fpOne
+	return z
+}
+
+// IsZero returns true when z == 0
+func (z *Fp) IsZero() bool {
+	return z[0]|z[1]|z[2]|z[3] == 0
+}
+
+// Equal returns true when z == x
+func (z *Fp) Equal(x *Fp) bool {
+	return *z == *x
+}
+
+// geq returns true when the limbs of z are greater or equal than the ones of x
+func (z *Fp) geq(x *Fp) bool {
+	for i := 3; i >= 0; i-- {
+		if z[i] != x[i] {
+			return z[i] > x[i]
+		}
+	}
+	return true
+}
+
+// subQ sets z = z - q
+func (z *Fp) subQ() {
+	var b uint64
+	z[0], b = bits.Sub64(z[0], q[0], 0)
+	z[1], b = bits.Sub64(z[1], q[1], b)
+	z[2], b = bits.Sub64(z[2], q[2], b)
+	z[3], _ = bits.Sub64(z[3], q[3], b)
+}
+
+// Add sets z = x + y
+func (z *Fp) Add(x, y *Fp) *Fp {
+	fpAdd(z, x, y)
+	return z
+}
+
+// Sub sets z = x - y
+func (z *Fp) Sub(x, y *Fp) *Fp {
+	fpSub(z, x, y)
+	return z
+}
+
+// Neg sets z = -x
+func (z *Fp) Neg(x *Fp) *Fp {
+	fpNeg(z, x)
+	return z
+}
+
+// Mul sets z = x * y
+func (z *Fp) Mul(x, y *Fp) *Fp {
+	fpMul(z, x, y)
+	return z
+}
+
+// Double sets z = 2x
+func (z *Fp) Double(x *Fp) *Fp {
+	return z.Add(x, x)
+}
+
+// Square sets z = x * x
+func (z *Fp) Square(x *Fp) *Fp {
+	return z.Mul(x, x)
+}
+
+// Inverse sets z = x^-1 (x^(q-2)), and z = 0 when x == 0
+func (z *Fp) Inverse(x *Fp) *Fp {
+	r := fpOne
+	b := *x
+	for i := 0; i < 4; i++ {
+		e := qMinus2[i]
+		for j := 0; j < 64; j++ {
+			if e&1 == 1 {
+				r.Mul(&r, &b)
+			}
+			b.Square(&b)
+			e >>= 1
+		}
+	}
+	*z = r
+	return z
+}
+
+// fromMont returns the limbs of z in regular form
+func (z *Fp) fromMont() Fp {
+	var r Fp
+	r.Mul(z, &Fp{1})
+	return r
+}
+
+// SetBytes sets z to the 32 bytes big endian value b, in regular form
+func (z *Fp) SetBytes(b []byte) error {
+	if len(b) != 32 {
+		return fmt.Errorf("Unexpected field element length, expected: 32, actual: %v", len(b))
+	}
+	var r Fp
+	for i := 0; i < 4; i++ {
+		r[3-i] = binary.BigEndian.Uint64(b[i*8 : (i+1)*8])
+	}
+	if r.geq(&q) {
+		return fmt.Errorf("Field element outside the field")
+	}
+	z.Mul(&r, &rSquare)
+	return nil
+}
+
+// SetMontLE sets z to the 32 bytes little endian value b, in Montgomery form
+// (the encoding of the .zkey files)
+func (z *Fp) SetMontLE(b []byte) error {
+	if len(b) != 32 {
+		return fmt.Errorf("Unexpected field element length, expected: 32, actual: %v", len(b))
+	}
+	var r Fp
+	for i := range r {
+		r[i] = binary.LittleEndian.Uint64(b[i*8 : (i+1)*8])
+	}
+	if r.geq(&q) {
+		return fmt.Errorf("Field element outside the field")
+	}
+	*z = r
+	return nil
+}
+
+// PutBytes writes z in regular form as 32 bytes big endian in b
+func (z *Fp) PutBytes(b []byte) {
+	r := z.fromMont()
+	for i := 0; i < 4; i++ {
+		binary.BigEndian.PutUint64(b[i*8:(i+1)*8], r[3-i])
+	}
+}
+
+// SetBigInt sets z = v mod q
+func (z *Fp) SetBigInt(v *big.Int) *Fp {
+	var b [32]byte
+	m := new(big.Int).Mod(v, Q).Bytes()
+	copy(b[32-len(m):], m)
+	if err := z.SetBytes(b[:]); err != nil {
+		panic(err)
+	}
+	return z
+}
+
+// BigInt returns z in regular form
+func (z *Fp) BigInt() *big.Int {
+	var b [32]byte
+	z.PutBytes(b[:])
+	return new(big.Int).SetBytes(b[:])
+}
@@ -0,0 +1,146 @@
+package curve
+
+import "math/big"
+
+// Fp2 is an element A0 + A1·i of the quadratic extension of the base field,
+// with i^2 = -1, over which G2 is defined
+type Fp2 struct {
+	A0, A1 Fp
+}
+
+// SetZero sets z = 0
+func (z *Fp2) SetZero() *Fp2 {
+	*z = Fp2{}
+	return z
+}
+
+// SetOne sets z = 1
+func (z *Fp2) SetOne() *Fp2 {
+	z.A0.SetOne()
+	z.A1.SetZero()
+	return z
+}
+
+// IsZero returns true when z == 0
+func (z *Fp2) IsZero() bool {
+	return z.A0.IsZero() && z.A1.IsZero()
+}
+
+// Equal returns true when z == x
+func (z *Fp2) Equal(x *Fp2) bool {
+	return *z == *x
+}
+
+// Add sets z = x + y
+func (z *Fp2) Add(x, y *Fp2) *Fp2 {
+	z.A0.Add(&x.A0, &y.A0)
+	z.A1.Add(&x.A1, &y.A1)
+	return z
+}
+
+// Double sets z = 2x
+func (z *Fp2) Double(x *Fp2) *Fp2 {
+	z.A0.Double(&x.A0)
+	z.A1.Double(&x.A1)
+	return z
+}
+
+// Sub sets z = x - y
+func (z *Fp2) Sub(x, y *Fp2) *Fp2 {
+	z.A0.Sub(&x.A0, &y.A0)
+	z.A1.Sub(&x.A1, &y.A1)
+	return z
+}
+
+// Neg sets z = -x
+func (z *Fp2) Neg(x *Fp2) *Fp2 {
+	z.A0.Neg(&x.A0)
+	z.A1.Neg(&x.A1)
+	return z
+}
+
+// Conjugate sets z = a0 - a1·i
+func (z *Fp2) Conjugate(x *Fp2) *Fp2 {
+	z.A0 = x.A0
+	z.A1.Neg(&x.A1)
+	return z
+}
+
+// Mul sets z = x * y, with the Karatsuba multiplication
+func (z *Fp2) Mul(x, y *Fp2) *Fp2 {
+	var v0, v1, s, t Fp
+	v0.Mul(&x.A0, &y.A0)
+	v1.Mul(&x.A1, &y.A1)
+	s.Add(&x.A0, &x.A1)
+	t.Add(&y.A0, &y.A1)
+	s.Mul(&s, &t)
+	s.Sub(&s, &v0)
+	z.A1.Sub(&s, &v1)
+	z.A0.Sub(&v0, &v1)
+	return z
+}
+
+// Square sets z = x * x: (a0 + a1)(a0 - a1) + 2·a0·a1·i
+func (z *Fp2) Square(x *Fp2) *Fp2 {
+	var s, d, m Fp
+	s.Add(&x.A0, &x.A1)
+	d.Sub(&x.A0, &x.A1)
+	m.Mul(&x.A0, &x.A1)
+	z.A0.Mul(&s, &d)
+	z.A1.Double(&m)
+	return z
+}
+
+// Inverse sets z = x^-1 = (a0 - a1·i) / (a0^2 + a1^2), and z = 0 when x == 0
+func (z *Fp2) Inverse(x *Fp2) *Fp2 {
+	var t, t1 Fp
+	t.Square(&x.A0)
+	t1.Square(&x.A1)
+	t.Add(&t, &t1)
+	t.Inverse(&t)
+	z.A0.Mul(&x.A0, &t)
+	t.Neg(&t)
+	z.A1.Mul(&x.A1, &t)
+	return z
+}
+
+// SetBytes sets z to the 64 bytes b, with the big endian values of A1 and A0
+// in this order, like in the bn256 encoding of the G2 points
+func (z *Fp2) SetBytes(b []byte) error {
+	var r Fp2
+	if err := r.A1.SetBytes(b[:32]); err != nil {
+		return err
+	}
+	if err := r.A0.SetBytes(b[32:64]); err != nil {
+		return err
+	}
+	*z = r
+	return nil
+}
+
+// SetMontLE sets z to the 64 bytes b, with the little endian values of A0
+// and A1 in this order in Montgomery form, like in the .zkey files
+func (z *Fp2) SetMontLE(b []byte) error {
+	var r Fp2
+	if err := r.A0.SetMontLE(b[:32]); err != nil {
+		return err
+	}
+	if err := r.A1.SetMontLE(b[32:64]); err != nil {
+		return err
+	}
+	*z = r
+	return nil
+}
+
+// PutBytes writes z as 64 bytes in b, with the encoding of SetBytes
+func (z *Fp2) PutBytes(b []byte) {
+	z.A1.PutBytes(b[:32])
+	z.A0.PutBytes(b[32:64])
+}
+
+// SetBigInts sets z = a0 + a1·i
+func (z *Fp2) SetBigInts(a0, a1 *big.Int) *Fp2 {
+	z.A0.SetBigInt(a0)
+	z.A1.SetBigInt(a1)
+	return z
+}
@@ -0,0 +1,33 @@
+//go:build (amd64 && !generic) || (arm64 && !generic)
+// +build amd64,!generic arm64,!generic
+
+package curve
+
+// The assembly implementations of the field operations are the ones of the
+// bn256/cloudflare package of go-ethereum (see LICENSE), which uses the same
+// Montgomery form
+
+import (
+	"golang.org/x/sys/cpu"
+)
+
+//nolint:varcheck
+var hasBMI2 = cpu.X86.HasBMI2
+
+// p2 is q, and np is -q^-1 mod 2^256, as little endian 64 bit words
+var (
+	p2 = [4]uint64{q0, q1, q2, q3}
+	np = [4]uint64{qInvNeg, 0x9ede7d651eca6ac9, 0xd8afcbd01833da80, 0xf57a22b791888c6b}
+)
+
+//go:noescape
+func fpNeg(c, a *Fp)
+
+//go:noescape
+func fpAdd(c, a, b *Fp)
+
+//go:noescape
+func fpSub(c, a, b *Fp)
+
+//go:noescape
+func fpMul(c, a, b *Fp)
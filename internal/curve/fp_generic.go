@@ -0,0 +1,136 @@
+//go:build (!amd64 && !arm64) || generic
+// +build !amd64,!arm64 generic
+
+package curve
+
+import "math/bits"
+
+// fpAdd sets z = x + y
+func fpAdd(z, x, y *Fp) {
+	var c uint64
+	z[0], c = bits.Add64(x[0], y[0], 0)
+	z[1], c = bits.Add64(x[1], y[1], c)
+	z[2], c = bits.Add64(x[2], y[2], c)
+	z[3], _ = bits.Add64(x[3], y[3], c)
+	// q < 2^254, so the sum does not overflow
+	if z.geq(&q) {
+		z.subQ()
+	}
+}
+
+// fpSub sets z = x - y
+func fpSub(z, x, y *Fp) {
+	var b uint64
+	z[0], b = bits.Sub64(x[0], y[0], 0)
+	z[1], b = bits.Sub64(x[1], y[1], b)
+	z[2], b = bits.Sub64(x[2], y[2], b)
+	z[3], b = bits.Sub64(x[3], y[3], b)
+	if b != 0 {
+		var c uint64
+		z[0], c = bits.Add64(z[0], q[0], 0)
+		z[1], c = bits.Add64(z[1], q[1], c)
+		z[2], c = bits.Add64(z[2], q[2], c)
+		z[3], _ = bits.Add64(z[3], q[3], c)
+	}
+}
+
+// fpNeg sets z = -x
+func fpNeg(z, x *Fp) {
+	if x.IsZero() {
+		z.SetZero()
+		return
+	}
+	fpSub(z, &q, x)
+}
+
+// fpMul sets z = x * y, with the Montgomery multiplication (CIOS).  As the
+// most significant bit of q is zero, the carries of each round fit in the
+// limbs of t.
+func fpMul(z, x, y *Fp) {
+	var t [4]uint64
+	var c [3]uint64
+	{
+		v := x[0]
+		c[1], c[0] = bits.Mul64(v, y[0])
+		m := c[0] * qInvNeg
+		c[2] = madd0(m, q0, c[0])
+		c[1], c[0] = madd1(v, y[1], c[1])
+		c[2], t[0] = madd2(m, q1, c[2], c[0])
+		c[1], c[0] = madd1(v, y[2], c[1])
+		c[2], t[1] = madd2(m, q2, c[2], c[0])
+		c[1], c[0] = madd1(v, y[3], c[1])
+		t[3], t[2] = madd3(m, q3, c[0], c[2], c[1])
+	}
+	{
+		v := x[1]
+		c[1], c[0] = madd1(v, y[0], t[0])
+		m := c[0] * qInvNeg
+		c[2] = madd0(m, q0, c[0])
+		c[1], c[0] = madd2(v, y[1], c[1], t[1])
+		c[2], t[0] = madd2(m, q1, c[2], c[0])
+		c[1], c[0] = madd2(v, y[2], c[1], t[2])
+		c[2], t[1] = madd2(m, q2, c[2], c[0])
+		c[1], c[0] = madd2(v, y[3], c[1], t[3])
+		t[3], t[2] = madd3(m, q3, c[0], c[2], c[1])
+	}
+	{
+		v := x[2]
+		c[1], c[0] = madd1(v, y[0], t[0])
+		m := c[0] * qInvNeg
+		c[2] = madd0(m, q0, c[0])
+		c[1], c[0] = madd2(v, y[1], c[1], t[1])
+		c[2], t[0] = madd2(m, q1, c[2], c[0])
+		c[1], c[0] = madd2(v, y[2], c[1], t[2])
+		c[2], t[1] = madd2(m, q2, c[2], c[0])
+		c[1], c[0] = madd2(v, y[3], c[1], t[3])
+		t[3], t[2] = madd3(m, q3, c[0], c[2], c[1])
+	}
+	{
+		v := x[3]
+		c[1], c[0] = madd1(v, y[0], t[0])
+		m := c[0] * qInvNeg
+		c[2] = madd0(m, q0, c[0])
+		c[1], c[0] = madd2(v, y[1], c[1], t[1])
+		c[2], z[0] = madd2(m, q1, c[2], c[0])
+		c[1], c[0] = madd2(v, y[2], c[1], t[2])
+		c[2], z[1] = madd2(m, q2, c[2], c[0])
+		c[1], c[0] = madd2(v, y[3], c[1], t[3])
+		z[3], z[2] = madd3(m, q3, c[0], c[2], c[1])
+	}
+	// z < 2q
+	if z[3] > q3 || (z[3] == q3 && (z[2] > q2 || (z[2] == q2 && (z[1] > q1 || (z[1] == q1 && z[0] >= q0))))) {
+		z.subQ()
+	}
+}
+
+// madd0 returns the high bits of a*b + c
+func madd0(a, b, c uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	_, carry := bits.Add64(lo, c, 0)
+	return hi + carry
+}
+
+// madd1 returns a*b + c
+func madd1(a, b, c uint64) (uint64, uint64) {
+	hi, lo := bits.Mul64(a, b)
+	lo, carry := bits.Add64(lo, c, 0)
+	return hi + carry, lo
+}
+
+// madd3 returns a*b + c + d + e·2^64
+func madd3(a, b, c, d, e uint64) (uint64, uint64) {
+	hi, lo := bits.Mul64(a, b)
+	c, carry := bits.Add64(c, d, 0)
+	hi += carry
+	lo, carry = bits.Add64(lo, c, 0)
+	return hi + carry + e, lo
+}
+
+// madd2 returns a*b + c + d
+func madd2(a, b, c, d uint64) (uint64, uint64) {
+	hi, lo := bits.Mul64(a, b)
+	c, carry := bits.Add64(c, d, 0)
+	hi += carry
+	lo, carry = bits.Add64(lo, c, 0)
+	return hi + carry, lo
+}
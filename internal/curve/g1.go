@@ -0,0 +1,485 @@
+package curve
+
+import (
+	"fmt"
+	"math/big"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+)
+
+// G1Affine is a point of G1 in affine coordinates, with (0, 0) as the point
+// at infinity (it is not on the curve y^2 = x^3 + 3)
+type G1Affine struct {
+	X, Y Fp
+}
+
+// G1Jac is a point of G1 in Jacobian coordinates (x = X/Z^2, y = Y/Z^3),
+// with Z = 0 as the point at infinity.  The zero value is the point at
+// infinity.
+type G1Jac struct {
+	X, Y, Z Fp
+}
+
+var (
+	// g1B is the b coefficient of the curve y^2 = x^3 + b
+	g1B Fp
+	// beta is the cube root of unity of the endomorphism φ(x, y) = (β·x, y)
+	beta Fp
+)
+
+func init() {
+	g1B.SetBigInt(bigThree)
+	beta.SetBigInt(bigFromBase10("2203960485148121921418603742825762020974279258880205651966"))
+}
+
+// IsInfinity returns true when p is the point at infinity
+func (p *G1Affine) IsInfinity() bool {
+	return p.X.IsZero() && p.Y.IsZero()
+}
+
+// IsOnCurve returns true when p is on the curve or is the point at infinity
+func (p *G1Affine) IsOnCurve() bool {
+	if p.IsInfinity() {
+		return true
+	}
+	var l, r Fp
+	l.Square(&p.Y)
+	r.Square(&p.X)
+	r.Mul(&r, &p.X)
+	r.Add(&r, &g1B)
+	return l.Equal(&r)
+}
+
+// SetX sets p to the point with the coordinate x whose y is negative (see
+// Fp.IsNegative) when negative is true, or non negative otherwise, and
+// returns true, or returns false and leaves p unchanged when x is not the
+// coordinate of a point
+func (p *G1Affine) SetX(x *Fp, negative bool) bool {
+	var y Fp
+	y.Square(x)
+	y.Mul(&y, x)
+	y.Add(&y, &g1B)
+	if !y.Sqrt(&y) {
+		return false
+	}
+	if y.IsNegative() != negative {
+		y.Neg(&y)
+	}
+	p.X = *x
+	p.Y = y
+	return true
+}
+
+// Neg sets p = -a
+func (p *G1Affine) Neg(a *G1Affine) *G1Affine {
+	p.X = a.X
+	p.Y.Neg(&a.Y)
+	return p
+}
+
+// Endo sets p = φ(a) = λ·a, with λ the cube root of unity of the splitting
+// of the scalars with SplitG1
+func (p *G1Affine) Endo(a *G1Affine) *G1Affine {
+	p.X.Mul(&a.X, &beta)
+	p.Y = a.Y
+	return p
+}
+
+// SetInfinity sets p to the point at infinity
+func (p *G1Jac) SetInfinity() *G1Jac {
+	*p = G1Jac{}
+	return p
+}
+
+// IsInfinity returns true when p is the point at infinity
+func (p *G1Jac) IsInfinity() bool {
+	return p.Z.IsZero()
+}
+
+// FromAffine sets p = a
+func (p *G1Jac) FromAffine(a *G1Affine) *G1Jac {
+	if a.IsInfinity() {
+		return p.SetInfinity()
+	}
+	p.X, p.Y = a.X, a.Y
+	p.Z.SetOne()
+	return p
+}
+
+// Neg sets p = -a
+func (p *G1Jac) Neg(a *G1Jac) *G1Jac {
+	p.X, p.Z = a.X, a.Z
+	p.Y.Neg(&a.Y)
+	return p
+}
+
+// Equal returns true when p and a are the same point
+func (p *G1Jac) Equal(a *G1Jac) bool {
+	if p.IsInfinity() || a.IsInfinity() {
+		return p.IsInfinity() && a.IsInfinity()
+	}
+	// X1·Z2^2 == X2·Z1^2 and Y1·Z2^3 == Y2·Z1^3
+	var z1z1, z2z2, u1, u2, s1, s2 Fp
+	z1z1.Square(&p.Z)
+	z2z2.Square(&a.Z)
+	u1.Mul(&p.X, &z2z2)
+	u2.Mul(&a.X, &z1z1)
+	s1.Mul(&p.Y, &z2z2)
+	s1.Mul(&s1, &a.Z)
+	s2.Mul(&a.Y, &z1z1)
+	s2.Mul(&s2, &p.Z)
+	return u1.Equal(&u2) && s1.Equal(&s2)
+}
+
+// Double sets p = 2a (dbl-2009-l)
+func (p *G1Jac) Double(a *G1Jac) *G1Jac {
+	if a.IsInfinity() {
+		return p.SetInfinity()
+	}
+	var A, B, C, D, E, F, t Fp
+	A.Square(&a.X)
+	B.Square(&a.Y)
+	C.Square(&B)
+	// D = 2((X+B)^2 - A - C)
+	D.Add(&a.X, &B)
+	D.Square(&D)
+	D.Sub(&D, &A)
+	D.Sub(&D, &C)
+	D.Double(&D)
+	E.Double(&A)
+	E.Add(&E, &A)
+	F.Square(&E)
+
+	// Z3 = 2·Y·Z, computed first as p can be a
+	p.Z.Mul(&a.Y, &a.Z)
+	p.Z.Double(&p.Z)
+	// X3 = F - 2D
+	p.X.Sub(&F, &D)
+	p.X.Sub(&p.X, &D)
+	// Y3 = E(D - X3) - 8C
+	t.Sub(&D, &p.X)
+	p.Y.Mul(&E, &t)
+	C.Double(&C)
+	C.Double(&C)
+	C.Double(&C)
+	p.Y.Sub(&p.Y, &C)
+	return p
+}
+
+// AddMixed sets p = p + a, with a in affine coordinates (madd-2007-bl)
+func (p *G1Jac) AddMixed(a *G1Affine) *G1Jac {
+	if a.IsInfinity() {
+		return p
+	}
+	if p.IsInfinity() {
+		return p.FromAffine(a)
+	}
+	var z1z1, u2, s2, h, hh, i, j, r, v Fp
+	z1z1.Square(&p.Z)
+	u2.Mul(&a.X, &z1z1)
+	s2.Mul(&a.Y, &p.Z)
+	s2.Mul(&s2, &z1z1)
+	h.Sub(&u2, &p.X)
+	r.Sub(&s2, &p.Y)
+	if h.IsZero() {
+		if r.IsZero() {
+			return p.Double(p)
+		}
+		return p.SetInfinity()
+	}
+	r.Double(&r)
+	hh.Square(&h)
+	i.Double(&hh)
+	i.Double(&i)
+	j.Mul(&h, &i)
+	v.Mul(&p.X, &i)
+
+	// Z3 = (Z1 + H)^2 - Z1Z1 - HH
+	p.Z.Add(&p.Z, &h)
+	p.Z.Square(&p.Z)
+	p.Z.Sub(&p.Z, &z1z1)
+	p.Z.Sub(&p.Z, &hh)
+	// Y3 = r(V - X3) - 2·Y1·J, with J·Y1 computed before X3
+	j2 := j
+	j.Mul(&j, &p.Y)
+	j.Double(&j)
+	// X3 = r^2 - J - 2V
+	p.X.Square(&r)
+	p.X.Sub(&p.X, &j2)
+	p.X.Sub(&p.X, &v)
+	p.X.Sub(&p.X, &v)
+	v.Sub(&v, &p.X)
+	p.Y.Mul(&r, &v)
+	p.Y.Sub(&p.Y, &j)
+	return p
+}
+
+// Add sets p = p + a (add-2007-bl)
+func (p *G1Jac) Add(a *G1Jac) *G1Jac {
+	if a.IsInfinity() {
+		return p
+	}
+	if p.IsInfinity() {
+		*p = *a
+		return p
+	}
+	var z1z1, z2z2, u1, u2, s1, s2, h, i, j, r, v Fp
+	z1z1.Square(&p.Z)
+	z2z2.Square(&a.Z)
+	u1.Mul(&p.X, &z2z2)
+	u2.Mul(&a.X, &z1z1)
+	s1.Mul(&p.Y, &a.Z)
+	s1.Mul(&s1, &z2z2)
+	s2.Mul(&a.Y, &p.Z)
+	s2.Mul(&s2, &z1z1)
+	h.Sub(&u2, &u1)
+	r.Sub(&s2, &s1)
+	if h.IsZero() {
+		if r.IsZero() {
+			return p.Double(p)
+		}
+		return p.SetInfinity()
+	}
+	r.Double(&r)
+	i.Double(&h)
+	i.Square(&i)
+	j.Mul(&h, &i)
+	v.Mul(&u1, &i)
+
+	// Z3 = ((Z1 + Z2)^2 - Z1Z1 - Z2Z2)·H
+	p.Z.Add(&p.Z, &a.Z)
+	p.Z.Square(&p.Z)
+	p.Z.Sub(&p.Z, &z1z1)
+	p.Z.Sub(&p.Z, &z2z2)
+	p.Z.Mul(&p.Z, &h)
+	// X3 = r^2 - J - 2V
+	p.X.Square(&r)
+	p.X.Sub(&p.X, &j)
+	p.X.Sub(&p.X, &v)
+	p.X.Sub(&p.X, &v)
+	// Y3 = r(V - X3) - 2·S1·J
+	v.Sub(&v, &p.X)
+	p.Y.Mul(&r, &v)
+	s1.Mul(&s1, &j)
+	s1.Double(&s1)
+	p.Y.Sub(&p.Y, &s1)
+	return p
+}
+
+// ScalarMul sets p = k * a, with k >= 0
+func (p *G1Jac) ScalarMul(a *G1Jac, k *big.Int) *G1Jac {
+	var r G1Jac
+	b := *a
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		r.Double(&r)
+		if k.Bit(i) == 1 {
+			r.Add(&b)
+		}
+	}
+	*p = r
+	return p
+}
+
+// Affine returns p in affine coordinates
+func (p *G1Jac) Affine() G1Affine {
+	var a G1Affine
+	if p.IsInfinity() {
+		return a
+	}
+	var zInv, zInv2 Fp
+	zInv.Inverse(&p.Z)
+	zInv2.Square(&zInv)
+	a.X.Mul(&p.X, &zInv2)
+	zInv2.Mul(&zInv2, &zInv)
+	a.Y.Mul(&p.Y, &zInv2)
+	return a
+}
+
+// BatchNormalizeG1 converts the points p to affine coordinates in a, with a
+// single field inversion (Montgomery batch inversion).  a must have the
+// length of p.
+func BatchNormalizeG1(a []G1Affine, p []G1Jac) {
+	// prod[i] is the product of the Z of the points before i
+	prod := make([]Fp, len(p))
+	var acc Fp
+	acc.SetOne()
+	for i := range p {
+		prod[i] = acc
+		if !p[i].IsInfinity() {
+			acc.Mul(&acc, &p[i].Z)
+		}
+	}
+	acc.Inverse(&acc)
+	var zInv, zInv2 Fp
+	for i := len(p) - 1; i >= 0; i-- {
+		if p[i].IsInfinity() {
+			a[i] = G1Affine{}
+			continue
+		}
+		zInv.Mul(&acc, &prod[i])
+		acc.Mul(&acc, &p[i].Z)
+		zInv2.Square(&zInv)
+		a[i].X.Mul(&p[i].X, &zInv2)
+		zInv2.Mul(&zInv2, &zInv)
+		a[i].Y.Mul(&p[i].Y, &zInv2)
+	}
+}
+
+// batchAddDenG1 returns in d the denominator of the slope of p + q, and
+// false when the sum does not need it (one of the points is the point at
+// infinity, or q = -p)
+func batchAddDenG1(d *Fp, p, q *G1Affine) bool {
+	if p.IsInfinity() || q.IsInfinity() {
+		return false
+	}
+	if p.X.Equal(&q.X) {
+		if !p.Y.Equal(&q.Y) {
+			return false
+		}
+		// doubling: λ = 3x^2 / 2y
+		d.Double(&p.Y)
+		return true
+	}
+	d.Sub(&q.X, &p.X)
+	return true
+}
+
+// BatchAddG1 sets p[i] = p[i] + q[i] in affine coordinates, sharing the
+// inversion of the denominators of all the slopes (Montgomery's trick), so
+// that each addition costs a few multiplications.  The points of p must be
+// different, and prod is scratch space of len(p) elements.
+func BatchAddG1(p, q []*G1Affine, prod []Fp) {
+	// prod[i] is the product of the denominators before i
+	var acc, d Fp
+	acc.SetOne()
+	for i := range p {
+		prod[i] = acc
+		if batchAddDenG1(&d, p[i], q[i]) {
+			acc.Mul(&acc, &d)
+		}
+	}
+	acc.Inverse(&acc)
+	var l, t, x Fp
+	for i := len(p) - 1; i >= 0; i-- {
+		a, b := p[i], q[i]
+		if !batchAddDenG1(&d, a, b) {
+			if a.IsInfinity() {
+				*a = *b
+			} else if !b.IsInfinity() {
+				*a = G1Affine{}
+			}
+			continue
+		}
+		l.Mul(&acc, &prod[i])
+		acc.Mul(&acc, &d)
+		if a.X.Equal(&b.X) {
+			t.Square(&a.X)
+			x.Double(&t)
+			t.Add(&t, &x)
+		} else {
+			t.Sub(&b.Y, &a.Y)
+		}
+		l.Mul(&l, &t)
+		// x3 = λ^2 - x1 - x2, y3 = λ(x1 - x3) - y1
+		x.Square(&l)
+		x.Sub(&x, &a.X)
+		x.Sub(&x, &b.X)
+		t.Sub(&a.X, &x)
+		t.Mul(&t, &l)
+		a.Y.Sub(&t, &a.Y)
+		a.X = x
+	}
+}
+
+// G1FromBn256 returns the bn256 point p in affine coordinates.  p is not
+// modified, so it can be shared by concurrent calls.
+func G1FromBn256(p *bn256.G1) G1Affine {
+	var a G1Affine
+	// Marshal normalizes the point in place, so it is done over a copy
+	b := new(bn256.G1).Set(p).Marshal()
+	if err := a.X.SetBytes(b[:32]); err != nil {
+		panic(err)
+	}
+	if err := a.Y.SetBytes(b[32:]); err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// G1SliceFromBn256 converts the bn256 points to affine coordinates
+func G1SliceFromBn256(p []*bn256.G1) []G1Affine {
+	a := make([]G1Affine, len(p))
+	for i := range p {
+		a[i] = G1FromBn256(p[i])
+	}
+	return a
+}
+
+// Marshal returns p with the encoding of the bn256 points: the big endian
+// values of X and Y, and zeros for the point at infinity
+func (p *G1Affine) Marshal() []byte {
+	b := make([]byte, 64)
+	p.X.PutBytes(b[:32])
+	p.Y.PutBytes(b[32:])
+	return b
+}
+
+// Unmarshal sets p to the point encoded in b by Marshal, checking that it is
+// on the curve
+func (p *G1Affine) Unmarshal(b []byte) error {
+	if len(b) != 64 {
+		return fmt.Errorf("Unexpected G1 point length, expected: 64, actual: %v", len(b))
+	}
+	var a G1Affine
+	if err := a.X.SetBytes(b[:32]); err != nil {
+		return err
+	}
+	if err := a.Y.SetBytes(b[32:]); err != nil {
+		return err
+	}
+	if !a.IsOnCurve() {
+		return fmt.Errorf("G1 point not on the curve")
+	}
+	*p = a
+	return nil
+}
+
+// UnmarshalMontLE sets p to the point encoded in b with the coordinates of
+// SetMontLE, as in the .zkey files, where the point at infinity is (0, 1)
+// or (0, 0), checking that it is on the curve
+func (p *G1Affine) UnmarshalMontLE(b []byte) error {
+	if len(b) != 64 {
+		return fmt.Errorf("Unexpected G1 point length, expected: 64, actual: %v", len(b))
+	}
+	var a G1Affine
+	if err := a.X.SetMontLE(b[:32]); err != nil {
+		return err
+	}
+	if err := a.Y.SetMontLE(b[32:]); err != nil {
+		return err
+	}
+	if a.X.IsZero() && a.Y.Equal(&fpOne) {
+		a.Y.SetZero()
+	}
+	if !a.IsOnCurve() {
+		return fmt.Errorf("G1 point not on the curve")
+	}
+	*p = a
+	return nil
+}
+
+// Bn256 returns p as a bn256 point
+func (p *G1Affine) Bn256() *bn256.G1 {
+	q := new(bn256.G1)
+	if _, err := q.Unmarshal(p.Marshal()); err != nil {
+		// the points of G1Affine are always on the curve
+		panic(err)
+	}
+	return q
+}
+
+// Bn256 returns p as a bn256 point
+func (p *G1Jac) Bn256() *bn256.G1 {
+	a := p.Affine()
+	return a.Bn256()
+}
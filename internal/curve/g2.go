@@ -0,0 +1,499 @@
+package curve
+
+import (
+	"fmt"
+	"math/big"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+)
+
+// G2Affine is a point of G2 in affine coordinates, with (0, 0) as the point
+// at infinity (it is not on the twist)
+type G2Affine struct {
+	X, Y Fp2
+}
+
+// G2Jac is a point of G2 in Jacobian coordinates (x = X/Z^2, y = Y/Z^3),
+// with Z = 0 as the point at infinity.  The zero value is the point at
+// infinity.
+type G2Jac struct {
+	X, Y, Z Fp2
+}
+
+// g2B is the b coefficient of the twist y^2 = x^3 + b, 3/(i + 9)
+var g2B Fp2
+
+func init() {
+	var xi Fp2
+	xi.SetBigInts(big.NewInt(9), big.NewInt(1))
+	g2B.Inverse(&xi)
+	var three Fp2
+	three.SetBigInts(bigThree, big.NewInt(0))
+	g2B.Mul(&g2B, &three)
+}
+
+// IsInfinity returns true when p is the point at infinity
+func (p *G2Affine) IsInfinity() bool {
+	return p.X.IsZero() && p.Y.IsZero()
+}
+
+// IsOnCurve returns true when p is on the curve or is the point at infinity
+func (p *G2Affine) IsOnCurve() bool {
+	if p.IsInfinity() {
+		return true
+	}
+	var l, r Fp2
+	l.Square(&p.Y)
+	r.Square(&p.X)
+	r.Mul(&r, &p.X)
+	r.Add(&r, &g2B)
+	return l.Equal(&r)
+}
+
+// SetX sets p to the point with the coordinate x whose y is negative (see
+// Fp2.IsNegative) when negative is true, or non negative otherwise, and
+// returns true, or returns false and leaves p unchanged when x is not the
+// coordinate of a point of the twist.  The point may not be in the subgroup.
+func (p *G2Affine) SetX(x *Fp2, negative bool) bool {
+	var y Fp2
+	y.Square(x)
+	y.Mul(&y, x)
+	y.Add(&y, &g2B)
+	if !y.Sqrt(&y) {
+		return false
+	}
+	if y.IsNegative() != negative {
+		y.Neg(&y)
+	}
+	p.X = *x
+	p.Y = y
+	return true
+}
+
+// Neg sets p = -a
+func (p *G2Affine) Neg(a *G2Affine) *G2Affine {
+	p.X = a.X
+	p.Y.Neg(&a.Y)
+	return p
+}
+
+// psiX and psiY are ξ^((p-1)/3) and ξ^((p-1)/2), with ξ = i + 9, the
+// coefficients of ψ in Montgomery form
+var (
+	psiX = Fp2{
+		A0: Fp{0xb5773b104563ab30, 0x347f91c8a9aa6454, 0x7a007127242e0991, 0x1956bcd8118214ec},
+		A1: Fp{0x6e849f1ea0aa4757, 0xaa1c7b6d89f89141, 0xb6e713cdfae0ca3a, 0x26694fbb4e82ebc3},
+	}
+	psiY = Fp2{
+		A0: Fp{0xe4bbdd0c2936b629, 0xbb30f162e133bacb, 0x31a9d1b6f9645366, 0x253570bea500f8dd},
+		A1: Fp{0xa1d77ce45ffe77c7, 0x07affd117826d1db, 0x6d16bd27bb7edc6b, 0x2c87200285defecc},
+	}
+)
+
+// Psi sets p = ψ(a) = μ·a, the untwist-Frobenius-twist endomorphism, with μ
+// = p mod r of the splitting of the scalars with SplitG2
+func (p *G2Affine) Psi(a *G2Affine) *G2Affine {
+	var x, y Fp2
+	x.Conjugate(&a.X)
+	y.Conjugate(&a.Y)
+	p.X.Mul(&x, &psiX)
+	p.Y.Mul(&y, &psiY)
+	return p
+}
+
+// SetInfinity sets p to the point at infinity
+func (p *G2Jac) SetInfinity() *G2Jac {
+	*p = G2Jac{}
+	return p
+}
+
+// IsInfinity returns true when p is the point at infinity
+func (p *G2Jac) IsInfinity() bool {
+	return p.Z.IsZero()
+}
+
+// FromAffine sets p = a
+func (p *G2Jac) FromAffine(a *G2Affine) *G2Jac {
+	if a.IsInfinity() {
+		return p.SetInfinity()
+	}
+	p.X, p.Y = a.X, a.Y
+	p.Z.SetOne()
+	return p
+}
+
+// Neg sets p = -a
+func (p *G2Jac) Neg(a *G2Jac) *G2Jac {
+	p.X, p.Z = a.X, a.Z
+	p.Y.Neg(&a.Y)
+	return p
+}
+
+// Equal returns true when p and a are the same point
+func (p *G2Jac) Equal(a *G2Jac) bool {
+	if p.IsInfinity() || a.IsInfinity() {
+		return p.IsInfinity() && a.IsInfinity()
+	}
+	// X1·Z2^2 == X2·Z1^2 and Y1·Z2^3 == Y2·Z1^3
+	var z1z1, z2z2, u1, u2, s1, s2 Fp2
+	z1z1.Square(&p.Z)
+	z2z2.Square(&a.Z)
+	u1.Mul(&p.X, &z2z2)
+	u2.Mul(&a.X, &z1z1)
+	s1.Mul(&p.Y, &z2z2)
+	s1.Mul(&s1, &a.Z)
+	s2.Mul(&a.Y, &z1z1)
+	s2.Mul(&s2, &p.Z)
+	return u1.Equal(&u2) && s1.Equal(&s2)
+}
+
+// Double sets p = 2a (dbl-2009-l)
+func (p *G2Jac) Double(a *G2Jac) *G2Jac {
+	if a.IsInfinity() {
+		return p.SetInfinity()
+	}
+	var A, B, C, D, E, F, t Fp2
+	A.Square(&a.X)
+	B.Square(&a.Y)
+	C.Square(&B)
+	// D = 2((X+B)^2 - A - C)
+	D.Add(&a.X, &B)
+	D.Square(&D)
+	D.Sub(&D, &A)
+	D.Sub(&D, &C)
+	D.Double(&D)
+	E.Double(&A)
+	E.Add(&E, &A)
+	F.Square(&E)
+
+	// Z3 = 2·Y·Z, computed first as p can be a
+	p.Z.Mul(&a.Y, &a.Z)
+	p.Z.Double(&p.Z)
+	// X3 = F - 2D
+	p.X.Sub(&F, &D)
+	p.X.Sub(&p.X, &D)
+	// Y3 = E(D - X3) - 8C
+	t.Sub(&D, &p.X)
+	p.Y.Mul(&E, &t)
+	C.Double(&C)
+	C.Double(&C)
+	C.Double(&C)
+	p.Y.Sub(&p.Y, &C)
+	return p
+}
+
+// AddMixed sets p = p + a, with a in affine coordinates (madd-2007-bl)
+func (p *G2Jac) AddMixed(a *G2Affine) *G2Jac {
+	if a.IsInfinity() {
+		return p
+	}
+	if p.IsInfinity() {
+		return p.FromAffine(a)
+	}
+	var z1z1, u2, s2, h, hh, i, j, r, v Fp2
+	z1z1.Square(&p.Z)
+	u2.Mul(&a.X, &z1z1)
+	s2.Mul(&a.Y, &p.Z)
+	s2.Mul(&s2, &z1z1)
+	h.Sub(&u2, &p.X)
+	r.Sub(&s2, &p.Y)
+	if h.IsZero() {
+		if r.IsZero() {
+			return p.Double(p)
+		}
+		return p.SetInfinity()
+	}
+	r.Double(&r)
+	hh.Square(&h)
+	i.Double(&hh)
+	i.Double(&i)
+	j.Mul(&h, &i)
+	v.Mul(&p.X, &i)
+
+	// Z3 = (Z1 + H)^2 - Z1Z1 - HH
+	p.Z.Add(&p.Z, &h)
+	p.Z.Square(&p.Z)
+	p.Z.Sub(&p.Z, &z1z1)
+	p.Z.Sub(&p.Z, &hh)
+	// Y3 = r(V - X3) - 2·Y1·J, with J·Y1 computed before X3
+	j2 := j
+	j.Mul(&j, &p.Y)
+	j.Double(&j)
+	// X3 = r^2 - J - 2V
+	p.X.Square(&r)
+	p.X.Sub(&p.X, &j2)
+	p.X.Sub(&p.X, &v)
+	p.X.Sub(&p.X, &v)
+	v.Sub(&v, &p.X)
+	p.Y.Mul(&r, &v)
+	p.Y.Sub(&p.Y, &j)
+	return p
+}
+
+// Add sets p = p + a (add-2007-bl)
+func (p *G2Jac) Add(a *G2Jac) *G2Jac {
+	if a.IsInfinity() {
+		return p
+	}
+	if p.IsInfinity() {
+		*p = *a
+		return p
+	}
+	var z1z1, z2z2, u1, u2, s1, s2, h, i, j, r, v Fp2
+	z1z1.Square(&p.Z)
+	z2z2.Square(&a.Z)
+	u1.Mul(&p.X, &z2z2)
+	u2.Mul(&a.X, &z1z1)
+	s1.Mul(&p.Y, &a.Z)
+	s1.Mul(&s1, &z2z2)
+	s2.Mul(&a.Y, &p.Z)
+	s2.Mul(&s2, &z1z1)
+	h.Sub(&u2, &u1)
+	r.Sub(&s2, &s1)
+	if h.IsZero() {
+		if r.IsZero() {
+			return p.Double(p)
+		}
+		return p.SetInfinity()
+	}
+	r.Double(&r)
+	i.Double(&h)
+	i.Square(&i)
+	j.Mul(&h, &i)
+	v.Mul(&u1, &i)
+
+	// Z3 = ((Z1 + Z2)^2 - Z1Z1 - Z2Z2)·H
+	p.Z.Add(&p.Z, &a.Z)
+	p.Z.Square(&p.Z)
+	p.Z.Sub(&p.Z, &z1z1)
+	p.Z.Sub(&p.Z, &z2z2)
+	p.Z.Mul(&p.Z, &h)
+	// X3 = r^2 - J - 2V
+	p.X.Square(&r)
+	p.X.Sub(&p.X, &j)
+	p.X.Sub(&p.X, &v)
+	p.X.Sub(&p.X, &v)
+	// Y3 = r(V - X3) - 2·S1·J
+	v.Sub(&v, &p.X)
+	p.Y.Mul(&r, &v)
+	s1.Mul(&s1, &j)
+	s1.Double(&s1)
+	p.Y.Sub(&p.Y, &s1)
+	return p
+}
+
+// ScalarMul sets p = k * a, with k >= 0
+func (p *G2Jac) ScalarMul(a *G2Jac, k *big.Int) *G2Jac {
+	var r G2Jac
+	b := *a
+	for i := k.BitLen() - 1; i >= 0; i-- {
+		r.Double(&r)
+		if k.Bit(i) == 1 {
+			r.Add(&b)
+		}
+	}
+	*p = r
+	return p
+}
+
+// Affine returns p in affine coordinates
+func (p *G2Jac) Affine() G2Affine {
+	var a G2Affine
+	if p.IsInfinity() {
+		return a
+	}
+	var zInv, zInv2 Fp2
+	zInv.Inverse(&p.Z)
+	zInv2.Square(&zInv)
+	a.X.Mul(&p.X, &zInv2)
+	zInv2.Mul(&zInv2, &zInv)
+	a.Y.Mul(&p.Y, &zInv2)
+	return a
+}
+
+// BatchNormalizeG2 converts the points p to affine coordinates in a, with a
+// single field inversion (Montgomery batch inversion).  a must have the
+// length of p.
+func BatchNormalizeG2(a []G2Affine, p []G2Jac) {
+	// prod[i] is the product of the Z of the points before i
+	prod := make([]Fp2, len(p))
+	var acc Fp2
+	acc.SetOne()
+	for i := range p {
+		prod[i] = acc
+		if !p[i].IsInfinity() {
+			acc.Mul(&acc, &p[i].Z)
+		}
+	}
+	acc.Inverse(&acc)
+	var zInv, zInv2 Fp2
+	for i := len(p) - 1; i >= 0; i-- {
+		if p[i].IsInfinity() {
+			a[i] = G2Affine{}
+			continue
+		}
+		zInv.Mul(&acc, &prod[i])
+		acc.Mul(&acc, &p[i].Z)
+		zInv2.Square(&zInv)
+		a[i].X.Mul(&p[i].X, &zInv2)
+		zInv2.Mul(&zInv2, &zInv)
+		a[i].Y.Mul(&p[i].Y, &zInv2)
+	}
+}
+
+// G2 version of batchAddDenG1
+func batchAddDenG2(d *Fp2, p, q *G2Affine) bool {
+	if p.IsInfinity() || q.IsInfinity() {
+		return false
+	}
+	if p.X.Equal(&q.X) {
+		if !p.Y.Equal(&q.Y) {
+			return false
+		}
+		// doubling: λ = 3x^2 / 2y
+		d.Double(&p.Y)
+		return true
+	}
+	d.Sub(&q.X, &p.X)
+	return true
+}
+
+// G2 version of BatchAddG1
+func BatchAddG2(p, q []*G2Affine, prod []Fp2) {
+	// prod[i] is the product of the denominators before i
+	var acc, d Fp2
+	acc.SetOne()
+	for i := range p {
+		prod[i] = acc
+		if batchAddDenG2(&d, p[i], q[i]) {
+			acc.Mul(&acc, &d)
+		}
+	}
+	acc.Inverse(&acc)
+	var l, t, x Fp2
+	for i := len(p) - 1; i >= 0; i-- {
+		a, b := p[i], q[i]
+		if !batchAddDenG2(&d, a, b) {
+			if a.IsInfinity() {
+				*a = *b
+			} else if !b.IsInfinity() {
+				*a = G2Affine{}
+			}
+			continue
+		}
+		l.Mul(&acc, &prod[i])
+		acc.Mul(&acc, &d)
+		if a.X.Equal(&b.X) {
+			t.Square(&a.X)
+			x.Double(&t)
+			t.Add(&t, &x)
+		} else {
+			t.Sub(&b.Y, &a.Y)
+		}
+		l.Mul(&l, &t)
+		// x3 = λ^2 - x1 - x2, y3 = λ(x1 - x3) - y1
+		x.Square(&l)
+		x.Sub(&x, &a.X)
+		x.Sub(&x, &b.X)
+		t.Sub(&a.X, &x)
+		t.Mul(&t, &l)
+		a.Y.Sub(&t, &a.Y)
+		a.X = x
+	}
+}
+
+// G2FromBn256 returns the bn256 point p in affine coordinates.  p is not
+// modified, so it can be shared by concurrent calls.
+func G2FromBn256(p *bn256.G2) G2Affine {
+	var a G2Affine
+	// Marshal normalizes the point in place, so it is done over a copy
+	b := new(bn256.G2).Set(p).Marshal()
+	if err := a.X.SetBytes(b[:64]); err != nil {
+		panic(err)
+	}
+	if err := a.Y.SetBytes(b[64:]); err != nil {
+		panic(err)
+	}
+	return a
+}
+
+// G2SliceFromBn256 converts the bn256 points to affine coordinates
+func G2SliceFromBn256(p []*bn256.G2) []G2Affine {
+	a := make([]G2Affine, len(p))
+	for i := range p {
+		a[i] = G2FromBn256(p[i])
+	}
+	return a
+}
+
+// Marshal returns p with the encoding of the bn256 points: the big endian
+// values of the imaginary and real parts of X and Y, and zeros for the point
+// at infinity
+func (p *G2Affine) Marshal() []byte {
+	b := make([]byte, 128)
+	p.X.PutBytes(b[:64])
+	p.Y.PutBytes(b[64:])
+	return b
+}
+
+// Unmarshal sets p to the point encoded in b by Marshal, checking that it is
+// on the twist.  It does not check that it is in the subgroup.
+func (p *G2Affine) Unmarshal(b []byte) error {
+	if len(b) != 128 {
+		return fmt.Errorf("Unexpected G2 point length, expected: 128, actual: %v", len(b))
+	}
+	var a G2Affine
+	if err := a.X.SetBytes(b[:64]); err != nil {
+		return err
+	}
+	if err := a.Y.SetBytes(b[64:]); err != nil {
+		return err
+	}
+	if !a.IsOnCurve() {
+		return fmt.Errorf("G2 point not on the curve")
+	}
+	*p = a
+	return nil
+}
+
+// UnmarshalMontLE sets p to the point encoded in b with the coordinates of
+// SetMontLE, as in the .zkey files, where the point at infinity is (0, 1)
+// or (0, 0), checking that it is on the twist.  It does not check that it
+// is in the subgroup.
+func (p *G2Affine) UnmarshalMontLE(b []byte) error {
+	if len(b) != 128 {
+		return fmt.Errorf("Unexpected G2 point length, expected: 128, actual: %v", len(b))
+	}
+	var a G2Affine
+	if err := a.X.SetMontLE(b[:64]); err != nil {
+		return err
+	}
+	if err := a.Y.SetMontLE(b[64:]); err != nil {
+		return err
+	}
+	if a.X.IsZero() && a.Y.A0.Equal(&fpOne) && a.Y.A1.IsZero() {
+		a.Y.SetZero()
+	}
+	if !a.IsOnCurve() {
+		return fmt.Errorf("G2 point not on the curve")
+	}
+	*p = a
+	return nil
+}
+
+// Bn256 returns p as a bn256 point
+func (p *G2Affine) Bn256() *bn256.G2 {
+	q := new(bn256.G2)
+	if _, err := q.Unmarshal(p.Marshal()); err != nil {
+		// the points of G2Affine are always on the twist, and in the
+		// subgroup when they come from bn256 points
+		panic(err)
+	}
+	return q
+}
+
+// Bn256 returns p as a bn256 point
+func (p *G2Jac) Bn256() *bn256.G2 {
+	a := p.Affine()
+	return a.Bn256()
+}
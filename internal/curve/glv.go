@@ -0,0 +1,283 @@
+package curve
+
+import (
+	"math/big"
+	"math/bits"
+)
+
+// The scalars of the multiplications are split with the endomorphisms of
+// the groups (GLV method), into short positive scalars of a lattice basis:
+// k = k0 + k1·λ mod r in G1, with φ(P) = λ·P, and k = k0 + k1·μ + k2·μ^2 +
+// k3·μ^3 mod r in G2, with ψ(Q) = μ·Q and μ = p mod r
+
+// lattice is a basis of short vectors v of Z^n with sum(v[i]·λ^i) = 0 mod
+// r, used to split the scalars with the Babai rounding
+type lattice struct {
+	vectors [][]*big.Int
+	// inverse is the first row of the inverse of the basis, multiplied by
+	// det
+	inverse []*big.Int
+	det     *big.Int
+	// offset is the multiple of a vector with all the components positive
+	// that is added to the result so that they are all positive
+	offset       int64
+	offsetVector int
+
+	// the values for the splitting with 256 bit arithmetic: g[j] is
+	// round(|inverse[j]|·2^256 / det), with the sign in gNeg[j], and v, o
+	// are the vectors and the offset modulo 2^256
+	g    [][4]uint64
+	gNeg []bool
+	v    [][][4]uint64
+	o    [][4]uint64
+}
+
+func bigFromBase10(s string) *big.Int {
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		panic("invalid number: " + s)
+	}
+	return n
+}
+
+// g1Lattice is the basis of the splitting of the G1 scalars
+var g1Lattice = &lattice{
+	vectors: [][]*big.Int{
+		{bigFromBase10("147946756881789319000765030803803410728"), bigFromBase10("147946756881789319010696353538189108491")},
+		{bigFromBase10("147946756881789319020627676272574806254"), bigFromBase10("-147946756881789318990833708069417712965")},
+	},
+	inverse: []*big.Int{
+		bigFromBase10("147946756881789318990833708069417712965"),
+		bigFromBase10("147946756881789319010696353538189108491"),
+	},
+	det:          bigFromBase10("43776485743678550444492811490514550177096728800832068687396408373151616991234"),
+	offset:       2,
+	offsetVector: 0,
+}
+
+// g2Lattice is the basis of the splitting of the G2 scalars (Galbraith and
+// Scott, with the BN parameter u)
+var g2Lattice = func() *lattice {
+	u := bigFromBase10("4965661367192848881")
+	lin := func(a, b int64) *big.Int {
+		r := new(big.Int).Mul(u, big.NewInt(a))
+		return r.Add(r, big.NewInt(b))
+	}
+	return &lattice{
+		vectors: [][]*big.Int{
+			{lin(1, 1), lin(1, 0), lin(1, 0), lin(-2, 0)},
+			{lin(2, 1), lin(-1, 0), lin(-1, -1), lin(-1, 0)},
+			{lin(2, 0), lin(2, 1), lin(2, 1), lin(2, 1)},
+			{lin(1, -1), lin(4, 2), lin(-2, 1), lin(1, -1)},
+		},
+		inverse: []*big.Int{
+			bigFromBase10("147946756881789319035524660374153352898"),
+			bigFromBase10("4407920970296243842541313971887945403892406181113683144783"),
+			bigFromBase10("2203960485148121921270656985943972701953651582607630845713"),
+			bigFromBase10("-147946756881789319005730692170996259609"),
+		},
+		det:          bigFromBase10("65664728615517825666739217235771825265645093201248103031094612559727425486851"),
+		offset:       3,
+		offsetVector: 2,
+	}
+}()
+
+func init() {
+	g1Lattice.precompute()
+	g2Lattice.precompute()
+}
+
+// mod256 returns x modulo 2^256 as little endian 64 bit limbs
+func mod256(x *big.Int) [4]uint64 {
+	m := new(big.Int).Lsh(big.NewInt(1), 256)
+	y := new(big.Int).Mod(x, m)
+	var r [4]uint64
+	for i := 0; i < 256; i++ {
+		r[i/64] |= uint64(y.Bit(i)) << uint(i%64)
+	}
+	return r
+}
+
+func (l *lattice) precompute() {
+	n := len(l.vectors)
+	l.g = make([][4]uint64, n)
+	l.gNeg = make([]bool, n)
+	l.v = make([][][4]uint64, n)
+	l.o = make([][4]uint64, n)
+	for j := 0; j < n; j++ {
+		g := new(big.Int).Abs(l.inverse[j])
+		g.Lsh(g, 256)
+		g.Add(g, new(big.Int).Rsh(l.det, 1))
+		g.Div(g, l.det)
+		l.g[j] = mod256(g)
+		l.gNeg[j] = l.inverse[j].Sign() < 0
+		l.v[j] = make([][4]uint64, n)
+		for i := 0; i < n; i++ {
+			l.v[j][i] = mod256(l.vectors[j][i])
+		}
+		l.o[j] = mod256(new(big.Int).Mul(l.vectors[l.offsetVector][j], big.NewInt(l.offset)))
+	}
+}
+
+// split sets out to the components of the scalar k < r (as little endian
+// 64 bit limbs).  The coordinates c of the closest vector of the lattice to
+// (k, 0, ..., 0) are approximated as round(k·g / 2^256), which differs from
+// the exact k·inverse / det in less than 3/4.  The components of the
+// result, (k, 0, ..., 0) - sum(c[j]·v[j]) + offset, are positive and small,
+// so they are computed modulo 2^256.
+func (l *lattice) split(k *[4]uint64, out [][4]uint64) {
+	n := len(l.vectors)
+	var c [4][4]uint64
+	for j := 0; j < n; j++ {
+		c[j] = mulHiRound(k, &l.g[j])
+		if l.gNeg[j] {
+			c[j] = sub256(&[4]uint64{}, &c[j])
+		}
+	}
+	for i := 0; i < n; i++ {
+		o := l.o[i]
+		if i == 0 {
+			o = add256(&o, k)
+		}
+		for j := 0; j < n; j++ {
+			t := mulLo(&c[j], &l.v[j][i])
+			o = sub256(&o, &t)
+		}
+		out[i] = o
+	}
+}
+
+// SplitG1 returns k1 and k2 with k = k1 + k2·λ mod r, smaller than 2^129,
+// for k < r.  The scalars smaller than 2^128 are not split (k1 = k, k2 = 0).
+func SplitG1(k *[4]uint64) (k1, k2 [4]uint64) {
+	if k[2] == 0 && k[3] == 0 {
+		return *k, k2
+	}
+	var out [2][4]uint64
+	g1Lattice.split(k, out[:])
+	return out[0], out[1]
+}
+
+// SplitG2 returns k0, k1, k2 and k3 with k = sum(ki·μ^i) mod r, smaller
+// than 2^66, for k < r.  The scalars smaller than 2^64 are not split.
+func SplitG2(k *[4]uint64) [4][4]uint64 {
+	var out [4][4]uint64
+	if k[1] == 0 && k[2] == 0 && k[3] == 0 {
+		out[0] = *k
+		return out
+	}
+	g2Lattice.split(k, out[:])
+	return out
+}
+
+// mulHiRound returns round(a·b / 2^256)
+func mulHiRound(a, b *[4]uint64) [4]uint64 {
+	var t [8]uint64
+	for i := 0; i < 4; i++ {
+		var carry uint64
+		for j := 0; j < 4; j++ {
+			hi, lo := bits.Mul64(a[i], b[j])
+			var c uint64
+			lo, c = bits.Add64(lo, t[i+j], 0)
+			hi += c
+			lo, c = bits.Add64(lo, carry, 0)
+			hi += c
+			t[i+j], carry = lo, hi
+		}
+		t[i+4] = carry
+	}
+	// + 2^255
+	var c uint64
+	t[3], c = bits.Add64(t[3], 1<<63, 0)
+	t[4], c = bits.Add64(t[4], 0, c)
+	t[5], c = bits.Add64(t[5], 0, c)
+	t[6], c = bits.Add64(t[6], 0, c)
+	t[7], _ = bits.Add64(t[7], 0, c)
+	return [4]uint64{t[4], t[5], t[6], t[7]}
+}
+
+// mulLo returns a·b mod 2^256
+func mulLo(a, b *[4]uint64) [4]uint64 {
+	var t [4]uint64
+	for i := 0; i < 4; i++ {
+		var carry uint64
+		for j := 0; i+j < 4; j++ {
+			hi, lo := bits.Mul64(a[i], b[j])
+			var c uint64
+			lo, c = bits.Add64(lo, t[i+j], 0)
+			hi += c
+			lo, c = bits.Add64(lo, carry, 0)
+			hi += c
+			t[i+j], carry = lo, hi
+		}
+	}
+	return t
+}
+
+// add256 returns a + b mod 2^256
+func add256(a, b *[4]uint64) [4]uint64 {
+	var r [4]uint64
+	var c uint64
+	r[0], c = bits.Add64(a[0], b[0], 0)
+	r[1], c = bits.Add64(a[1], b[1], c)
+	r[2], c = bits.Add64(a[2], b[2], c)
+	r[3], _ = bits.Add64(a[3], b[3], c)
+	return r
+}
+
+// sub256 returns a - b mod 2^256
+func sub256(a, b *[4]uint64) [4]uint64 {
+	var r [4]uint64
+	var c uint64
+	r[0], c = bits.Sub64(a[0], b[0], 0)
+	r[1], c = bits.Sub64(a[1], b[1], c)
+	r[2], c = bits.Sub64(a[2], b[2], c)
+	r[3], _ = bits.Sub64(a[3], b[3], c)
+	return r
+}
+
+// bit256 returns the bit i of the little endian limbs k
+func bit256(k *[4]uint64, i int) int {
+	return int(k[i/64]>>uint(i%64)) & 1
+}
+
+// bitLen256 returns the bit length of the little endian limbs k
+func bitLen256(k *[4]uint64) int {
+	for i := 3; i >= 0; i-- {
+		if k[i] != 0 {
+			return 64*i + bits.Len64(k[i])
+		}
+	}
+	return 0
+}
+
+// ScalarMulGLV sets p = k·a, for k < r as little endian 64 bit limbs.  k is
+// split with SplitG1, and k1·a + k2·φ(a) is computed with a single chain of
+// doublings (Straus-Shamir), half as long as the one of ScalarMul.
+func (p *G1Jac) ScalarMulGLV(a *G1Affine, k *[4]uint64) *G1Jac {
+	k1, k2 := SplitG1(k)
+	var e G1Affine
+	e.Endo(a)
+	// a + φ(a), kept in Jacobian coordinates to avoid an inversion
+	var sum G1Jac
+	sum.FromAffine(a)
+	sum.AddMixed(&e)
+	n := bitLen256(&k1)
+	if l := bitLen256(&k2); l > n {
+		n = l
+	}
+	var r G1Jac
+	for i := n - 1; i >= 0; i-- {
+		r.Double(&r)
+		switch bit256(&k1, i) | bit256(&k2, i)<<1 {
+		case 1:
+			r.AddMixed(a)
+		case 2:
+			r.AddMixed(&e)
+		case 3:
+			r.Add(&sum)
+		}
+	}
+	*p = r
+	return p
+}
@@ -0,0 +1,110 @@
+package curve
+
+import "math/big"
+
+var (
+	// qMinus1Half is (q-1)/2, the largest non negative element
+	qMinus1Half = new(big.Int).Rsh(Q, 1)
+	// qPlus1Quarter is (q+1)/4, the exponent of the square root, as q = 3
+	// mod 4
+	qPlus1Quarter = new(big.Int).Rsh(new(big.Int).Add(Q, big.NewInt(1)), 2)
+	// qMinus3Quarter is (q-3)/4
+	qMinus3Quarter = new(big.Int).Rsh(new(big.Int).Sub(Q, bigThree), 2)
+)
+
+// Exp sets z = x^e, with e >= 0
+func (z *Fp) Exp(x *Fp, e *big.Int) *Fp {
+	r := fpOne
+	b := *x
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		r.Square(&r)
+		if e.Bit(i) == 1 {
+			r.Mul(&r, &b)
+		}
+	}
+	*z = r
+	return z
+}
+
+// Sqrt sets z to a square root of x and returns true, or returns false and
+// leaves z unchanged when x is not a square
+func (z *Fp) Sqrt(x *Fp) bool {
+	var r, r2 Fp
+	r.Exp(x, qPlus1Quarter)
+	r2.Square(&r)
+	if !r2.Equal(x) {
+		return false
+	}
+	*z = r
+	return true
+}
+
+// IsNegative returns true when z, in regular form, is larger than (q-1)/2
+func (z *Fp) IsNegative() bool {
+	return z.BigInt().Cmp(qMinus1Half) > 0
+}
+
+// Exp sets z = x^e, with e >= 0
+func (z *Fp2) Exp(x *Fp2, e *big.Int) *Fp2 {
+	var r Fp2
+	r.SetOne()
+	b := *x
+	for i := e.BitLen() - 1; i >= 0; i-- {
+		r.Square(&r)
+		if e.Bit(i) == 1 {
+			r.Mul(&r, &b)
+		}
+	}
+	*z = r
+	return z
+}
+
+// Sqrt sets z to a square root of x and returns true, or returns false and
+// leaves z unchanged when x is not a square (algorithm 9 of "Square root
+// computation over even extension fields", Adj and Rodríguez-Henríquez)
+func (z *Fp2) Sqrt(x *Fp2) bool {
+	if x.IsZero() {
+		z.SetZero()
+		return true
+	}
+	var a1, alpha, a0, minusOne, r Fp2
+	minusOne.SetOne()
+	minusOne.Neg(&minusOne)
+	a1.Exp(x, qMinus3Quarter)
+	alpha.Square(&a1)
+	alpha.Mul(&alpha, x)
+	// a0 = alpha^(q+1), the norm of alpha
+	a0.Conjugate(&alpha)
+	a0.Mul(&a0, &alpha)
+	if a0.Equal(&minusOne) {
+		return false
+	}
+	r.Mul(&a1, x)
+	if alpha.Equal(&minusOne) {
+		// i·r
+		r.A0, r.A1 = r.A1, r.A0
+		r.A0.Neg(&r.A0)
+	} else {
+		var b Fp2
+		b.SetOne()
+		b.Add(&b, &alpha)
+		b.Exp(&b, qMinus1Half)
+		r.Mul(&r, &b)
+	}
+	var r2 Fp2
+	r2.Square(&r)
+	if !r2.Equal(x) {
+		return false
+	}
+	*z = r
+	return true
+}
+
+// IsNegative returns true when the imaginary part of z is negative, or when
+// it is zero and the real part is negative
+func (z *Fp2) IsNegative() bool {
+	if z.A1.IsZero() {
+		return z.A0.IsNegative()
+	}
+	return z.A1.IsNegative()
+}
@@ -0,0 +1,59 @@
+// Package fr contains the helpers of the scalar field Fr of BN254 shared by
+// the setup, the phase 2 ceremony and the prover
+package fr
+
+import (
+	"crypto/rand"
+	"io"
+	"math/big"
+	"sync"
+
+	"github.com/iden3/go-circom-prover-verifier/types"
+	"github.com/iden3/go-iden3-crypto/ff"
+)
+
+// MaxDomainBits is the 2-adicity of the field, R-1 = t * 2^28: the FFT
+// domains have at most 2^MaxDomainBits elements
+const MaxDomainBits = 28
+
+var (
+	// rootsW[i] is the primitive 2^i root of unity, computed as 5^t (like
+	// snarkjs), with R-1 = t * 2^s
+	rootsW     []*ff.Element
+	rootsWOnce sync.Once
+)
+
+func initRootsW() {
+	rem := new(big.Int).Sub(types.R, big.NewInt(1))
+	s := 0
+	for rem.Bit(0) == 0 { // rem.Bit==0 when even
+		s++
+		rem = new(big.Int).Rsh(rem, 1)
+	}
+	rootsW = make([]*ff.Element, s+1)
+	rootsW[s] = ff.NewElement().SetBigInt(new(big.Int).Exp(big.NewInt(5), rem, types.R))
+	for n := s - 1; n >= 0; n-- {
+		rootsW[n] = ff.NewElement().Square(rootsW[n+1])
+	}
+}
+
+// RootOfUnity returns the primitive 2^bits root of unity of the FFT domains.
+// The element is shared, so it must not be modified.  bits must not be
+// greater than MaxDomainBits.
+func RootOfUnity(bits int) *ff.Element {
+	rootsWOnce.Do(initRootsW)
+	return rootsW[bits]
+}
+
+// RandNonZero returns a uniformly random non zero element of the field
+func RandNonZero(rnd io.Reader) (*ff.Element, error) {
+	for {
+		r, err := rand.Int(rnd, types.R)
+		if err != nil {
+			return nil, err
+		}
+		if r.Sign() != 0 {
+			return ff.NewElement().SetBigInt(r), nil
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package mpc
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// ChaCha is the random generator of snarkjs (ffjavascript), the ChaCha20
+// block function with a 256 bits seed as key and a zero nonce, used to
+// derive the points and the secrets from hashes
+type ChaCha struct {
+	state [16]uint32
+	buf   [16]uint32
+	idx   int
+}
+
+// NewChaCha returns the generator seeded with the first 32 bytes of seed,
+// read as 8 big endian words like snarkjs does with its hashes
+func NewChaCha(seed []byte) *ChaCha {
+	c := ChaCha{idx: 16}
+	c.state[0] = 0x61707865
+	c.state[1] = 0x3320646e
+	c.state[2] = 0x79622d32
+	c.state[3] = 0x6b206574
+	for i := 0; i < 8; i++ {
+		c.state[4+i] = binary.BigEndian.Uint32(seed[4*i:])
+	}
+	return &c
+}
+
+func quarterRound(st *[16]uint32, a, b, c, d int) {
+	st[a] += st[b]
+	st[d] = bits.RotateLeft32(st[d]^st[a], 16)
+	st[c] += st[d]
+	st[b] = bits.RotateLeft32(st[b]^st[c], 12)
+	st[a] += st[b]
+	st[d] = bits.RotateLeft32(st[d]^st[a], 8)
+	st[c] += st[d]
+	st[b] = bits.RotateLeft32(st[b]^st[c], 7)
+}
+
+// update computes the next block and increments the counter
+func (c *ChaCha) update() {
+	c.buf = c.state
+	for i := 0; i < 10; i++ {
+		quarterRound(&c.buf, 0, 4, 8, 12)
+		quarterRound(&c.buf, 1, 5, 9, 13)
+		quarterRound(&c.buf, 2, 6, 10, 14)
+		quarterRound(&c.buf, 3, 7, 11, 15)
+		quarterRound(&c.buf, 0, 5, 10, 15)
+		quarterRound(&c.buf, 1, 6, 11, 12)
+		quarterRound(&c.buf, 2, 7, 8, 13)
+		quarterRound(&c.buf, 3, 4, 9, 14)
+	}
+	for i := range c.buf {
+		c.buf[i] += c.state[i]
+	}
+	c.idx = 0
+	for i := 12; i < 16; i++ {
+		c.state[i]++
+		if c.state[i] != 0 {
+			break
+		}
+	}
+}
+
+// NextU32 returns the next 32 bits word
+func (c *ChaCha) NextU32() uint32 {
+	if c.idx == 16 {
+		c.update()
+	}
+	c.idx++
+	return c.buf[c.idx-1]
+}
+
+// NextU64 returns the next 64 bits word, made of the next two words with the
+// most significant first
+func (c *ChaCha) NextU64() uint64 {
+	hi := c.NextU32()
+	return uint64(hi)<<32 | uint64(c.NextU32())
+}
+
+// NextBool returns the lowest bit of the next word
+func (c *ChaCha) NextBool() bool {
+	return c.NextU32()&1 == 1
+}
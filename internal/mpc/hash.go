@@ -0,0 +1,95 @@
+package mpc
+
+import (
+	"math/big"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-circom-prover-verifier/internal/curve"
+	"github.com/iden3/go-circom-prover-verifier/types"
+	"github.com/iden3/go-iden3-crypto/ff"
+)
+
+// g2Cofactor is the cofactor of the G2 subgroup in the twist, 2q - r
+var g2Cofactor, _ = new(big.Int).SetString("30644e72e131a029b85045b68181585e06ceecda572a2489345f2299c0f9fa8d", 16)
+
+// nextLimbs returns the next 254 bits value of the generator, as little
+// endian 64 bits limbs
+func nextLimbs(rng *ChaCha) [4]uint64 {
+	var l [4]uint64
+	for i := range l {
+		l[i] = rng.NextU64()
+	}
+	l[3] &= 1<<62 - 1
+	return l
+}
+
+func limbsToBig(l [4]uint64) *big.Int {
+	v := new(big.Int)
+	for i := 3; i >= 0; i-- {
+		v.Lsh(v, 64)
+		v.Or(v, new(big.Int).SetUint64(l[i]))
+	}
+	return v
+}
+
+// FrFromRng returns an element of the scalar field sampled from rng like
+// snarkjs does: the first value below R is taken as the Montgomery form of
+// the element
+func FrFromRng(rng *ChaCha) *ff.Element {
+	for {
+		l := nextLimbs(rng)
+		if limbsToBig(l).Cmp(types.R) < 0 {
+			e := ff.Element(l)
+			return &e
+		}
+	}
+}
+
+// fpFromRng is the base field version of FrFromRng
+func fpFromRng(rng *ChaCha) curve.Fp {
+	for {
+		l := nextLimbs(rng)
+		if limbsToBig(l).Cmp(types.Q) < 0 {
+			return curve.Fp(l)
+		}
+	}
+}
+
+// G1FromRng returns a point of G1 sampled from rng like snarkjs does: X is
+// sampled until it is the coordinate of a point, and a bit of rng selects
+// the sign of Y
+func G1FromRng(rng *ChaCha) *bn256.G1 {
+	for {
+		x := fpFromRng(rng)
+		negative := rng.NextBool()
+		var p curve.G1Affine
+		if p.SetX(&x, negative) {
+			return p.Bn256()
+		}
+	}
+}
+
+// G2FromRng is the G2 version of G1FromRng, where the point of the twist is
+// multiplied by the cofactor to map it into the subgroup
+func G2FromRng(rng *ChaCha) *bn256.G2 {
+	for {
+		var x curve.Fp2
+		x.A0 = fpFromRng(rng)
+		x.A1 = fpFromRng(rng)
+		negative := rng.NextBool()
+		var p curve.G2Affine
+		if p.SetX(&x, negative) {
+			var j curve.G2Jac
+			j.FromAffine(&p)
+			j.ScalarMul(&j, g2Cofactor)
+			return j.Bn256()
+		}
+	}
+}
+
+// HashToG2 maps a hash of at least 32 bytes to a point of G2 whose discrete
+// logarithm is unknown, like the hashToG2 of snarkjs: the point sampled from
+// the generator seeded with the hash
+func HashToG2(h []byte) *bn256.G2 {
+	return G2FromRng(NewChaCha(h))
+}
@@ -0,0 +1,69 @@
+// Package mpc contains the helpers shared by the multi party computations of
+// the trusted setup, the powers of tau ceremony and its phase 2: the hash to
+// G2 and the uncompressed encoding of the points used by snarkjs to derive
+// the proofs of knowledge of the contributions, and the pairing checks.
+package mpc
+
+import (
+	"runtime"
+	"sync"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+)
+
+// infinityFlag is the flag of the first byte of the snarkjs uncompressed
+// encoding of the point at infinity
+const infinityFlag = 0x40
+
+// G1Uncompressed returns the snarkjs uncompressed encoding of p, hashed in
+// the challenges: the big endian values of X and Y, and zeros with the
+// infinity flag for the point at infinity
+func G1Uncompressed(p *bn256.G1) []byte {
+	// Marshal normalizes the point in place, so it is done over a copy
+	b := new(bn256.G1).Set(p).Marshal()
+	setInfinityFlag(b)
+	return b
+}
+
+// G2Uncompressed is the G2 version of G1Uncompressed, with the imaginary
+// part of the coordinates first
+func G2Uncompressed(p *bn256.G2) []byte {
+	b := new(bn256.G2).Set(p).Marshal()
+	setInfinityFlag(b)
+	return b
+}
+
+func setInfinityFlag(b []byte) {
+	for _, v := range b {
+		if v != 0 {
+			return
+		}
+	}
+	b[0] = infinityFlag
+}
+
+// Parallel calls f over n elements split in ranges, one per cpu
+func Parallel(n int, f func(start, end int)) {
+	numcpu := runtime.NumCPU()
+	var wg sync.WaitGroup
+	for cpu := 0; cpu < numcpu; cpu++ {
+		start, end := cpu*n/numcpu, (cpu+1)*n/numcpu
+		if start == end {
+			continue
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			f(start, end)
+			wg.Done()
+		}(start, end)
+	}
+	wg.Wait()
+}
+
+// SameRatio checks that e(a1, b2) == e(b1, a2), that is, the ratio between
+// a1 and b1 is the same as the ratio between a2 and b2
+func SameRatio(a1, b1 *bn256.G1, a2, b2 *bn256.G2) bool {
+	return bn256.PairingCheck(
+		[]*bn256.G1{a1, new(bn256.G1).Neg(b1)},
+		[]*bn256.G2{b2, a2})
+}
@@ -0,0 +1,76 @@
+package mpc
+
+import (
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-circom-prover-verifier/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChaCha(t *testing.T) {
+	// test vector of the block function of RFC 8439, section 2.3.2
+	key := []byte{
+		0x03, 0x02, 0x01, 0x00, 0x07, 0x06, 0x05, 0x04, 0x0b, 0x0a, 0x09, 0x08, 0x0f, 0x0e, 0x0d, 0x0c,
+		0x13, 0x12, 0x11, 0x10, 0x17, 0x16, 0x15, 0x14, 0x1b, 0x1a, 0x19, 0x18, 0x1f, 0x1e, 0x1d, 0x1c,
+	}
+	c := NewChaCha(key)
+	c.state[12] = 1
+	c.state[13] = 0x09000000
+	c.state[14] = 0x4a000000
+	expected := []uint32{
+		0xe4e7f110, 0x15593bd1, 0x1fdd0f50, 0xc47120a3,
+		0xc7f4d1c7, 0x0368c033, 0x9aaa2204, 0x4e6cd4c3,
+		0x466482d2, 0x09aa9f07, 0x05d7c214, 0xa2028bd9,
+		0xd19c12b5, 0xb94e16de, 0xe883d0cb, 0x4e3c50a2,
+	}
+	for i := range expected {
+		assert.Equal(t, expected[i], c.NextU32())
+	}
+	assert.Equal(t, uint32(2), c.state[12])
+
+	c = NewChaCha(key)
+	c2 := NewChaCha(key)
+	assert.Equal(t, uint64(c2.NextU32())<<32|uint64(c2.NextU32()), c.NextU64())
+}
+
+func TestHashToG2(t *testing.T) {
+	h1 := sha256.Sum256([]byte("a"))
+	h2 := sha256.Sum256([]byte("b"))
+	p1 := HashToG2(h1[:])
+	p2 := HashToG2(h2[:])
+	assert.Equal(t, p1.Marshal(), HashToG2(h1[:]).Marshal())
+	assert.NotEqual(t, p1.Marshal(), p2.Marshal())
+
+	// Unmarshal checks that the points are in the subgroup
+	for _, p := range []*bn256.G2{p1, p2} {
+		_, err := new(bn256.G2).Unmarshal(p.Marshal())
+		require.Nil(t, err)
+		assert.NotEqual(t, make([]byte, 128), p.Marshal())
+	}
+
+	rng := NewChaCha(h1[:])
+	for i := 0; i < 8; i++ {
+		e := FrFromRng(rng)
+		assert.True(t, limbsToBig(*e).Cmp(types.R) < 0)
+		_, err := new(bn256.G1).Unmarshal(G1FromRng(rng).Marshal())
+		require.Nil(t, err)
+	}
+}
+
+func TestUncompressed(t *testing.T) {
+	g1 := new(bn256.G1).ScalarBaseMult(big.NewInt(3))
+	assert.Equal(t, g1.Marshal(), G1Uncompressed(g1))
+	inf1 := G1Uncompressed(new(bn256.G1).ScalarBaseMult(big.NewInt(0)))
+	assert.Equal(t, byte(0x40), inf1[0])
+	assert.Equal(t, make([]byte, 63), inf1[1:])
+
+	g2 := new(bn256.G2).ScalarBaseMult(big.NewInt(3))
+	assert.Equal(t, g2.Marshal(), G2Uncompressed(g2))
+	inf2 := G2Uncompressed(new(bn256.G2).ScalarBaseMult(big.NewInt(0)))
+	assert.Equal(t, byte(0x40), inf2[0])
+	assert.Equal(t, make([]byte, 127), inf2[1:])
+}
@@ -0,0 +1,56 @@
+// Package testutil contains the circuit shared by the tests of the packages
+package testutil
+
+import (
+	"math/big"
+
+	"github.com/iden3/go-circom-prover-verifier/r1cs"
+	"github.com/iden3/go-circom-prover-verifier/types"
+)
+
+func term(wire int, coeff int64) r1cs.Term {
+	return r1cs.Term{Wire: wire, Coeff: new(big.Int).Mod(big.NewInt(coeff), types.R)}
+}
+
+// Circuit returns the ConstraintSystem of a circuit with n+1 constraints
+// computing out = f(in), with f(x) = x^2 + 1 applied n-1 times, and a witness
+// for the given input.  The wires are [1, out, in, intermediate[0..n-1]],
+// with out and in public.
+func Circuit(n int, in int64) (*r1cs.ConstraintSystem, types.Witness) {
+	cs := &r1cs.ConstraintSystem{
+		NWires:     n + 3,
+		NOutputs:   1,
+		NPubInputs: 1,
+	}
+	w := make(types.Witness, n+3)
+	w[0] = big.NewInt(1)
+	w[2] = big.NewInt(in)
+
+	// in * 1 = intermediate[0]
+	cs.Constraints = append(cs.Constraints, r1cs.Constraint{
+		A: r1cs.LinearCombination{term(2, 1)},
+		B: r1cs.LinearCombination{term(0, 1)},
+		C: r1cs.LinearCombination{term(3, 1)},
+	})
+	w[3] = big.NewInt(in)
+	// intermediate[i-1] * intermediate[i-1] = intermediate[i] - 1
+	for i := 1; i < n; i++ {
+		cs.Constraints = append(cs.Constraints, r1cs.Constraint{
+			A: r1cs.LinearCombination{term(i+2, 1)},
+			B: r1cs.LinearCombination{term(i+2, 1)},
+			C: r1cs.LinearCombination{term(i+3, 1), term(0, -1)},
+		})
+		w[i+3] = new(big.Int).Mul(w[i+2], w[i+2])
+		w[i+3].Add(w[i+3], big.NewInt(1))
+		w[i+3].Mod(w[i+3], types.R)
+	}
+	w[1] = w[n+2]
+	// the output is the last intermediate, with a constraint of a
+	// different shape: (intermediate[n-1] + 3 * in) * 2 = 2 * out + 6 * in
+	cs.Constraints = append(cs.Constraints, r1cs.Constraint{
+		A: r1cs.LinearCombination{term(n+2, 1), term(2, 3)},
+		B: r1cs.LinearCombination{term(0, 2)},
+		C: r1cs.LinearCombination{term(1, 2), term(2, 6)},
+	})
+	return cs, w
+}
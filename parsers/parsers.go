@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"math/big"
 	"os"
 	"sort"
@@ -15,7 +16,9 @@ import (
 	"strings"
 
 	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-circom-prover-verifier/internal/binfile"
 	"github.com/iden3/go-circom-prover-verifier/types"
+	"github.com/iden3/go-iden3-crypto/ff"
 )
 
 // PkString is the equivalent to the Pk struct in string representation, containing the ProvingKey
@@ -37,6 +40,24 @@ type PkString struct {
 	PolsB      []map[string]string `json:"polsB"`
 }
 
+// UnmarshalJSON parses the PkString, accepting the vk_alpha_1 name of the
+// current snarkjs for VkAlpha1
+func (ps *PkString) UnmarshalJSON(b []byte) error {
+	type pkString PkString
+	var aux struct {
+		pkString
+		VkAlpha1 []string `json:"vk_alpha_1"`
+	}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	*ps = PkString(aux.pkString)
+	if ps.VkAlpha1 == nil {
+		ps.VkAlpha1 = aux.VkAlpha1
+	}
+	return nil
+}
+
 // WitnessString contains the Witness in string representation
 type WitnessString []string
 
@@ -57,6 +78,24 @@ type VkString struct {
 	IC    [][]string `json:"IC"`
 }
 
+// UnmarshalJSON parses the VkString, accepting the vk_alpha_1 name of the
+// current snarkjs for Alpha
+func (vs *VkString) UnmarshalJSON(b []byte) error {
+	type vkString VkString
+	var aux struct {
+		vkString
+		Alpha []string `json:"vk_alpha_1"`
+	}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+	*vs = VkString(aux.vkString)
+	if vs.Alpha == nil {
+		vs.Alpha = aux.Alpha
+	}
+	return nil
+}
+
 // ParseWitness parses the json []byte data into the Witness struct
 func ParseWitness(wJson []byte) (types.Witness, error) {
 	var ws WitnessString
@@ -141,14 +180,16 @@ func pkStringToPk(ps PkString) (*types.Pk, error) {
 
 	p.DomainSize = ps.DomainSize
 
-	p.PolsA, err = polsStringToBigInt(ps.PolsA)
+	polsA, err := polsStringToBigInt(ps.PolsA)
 	if err != nil {
 		return nil, err
 	}
-	p.PolsB, err = polsStringToBigInt(ps.PolsB)
+	p.PolsA = types.PolsFromMaps(polsA)
+	polsB, err := polsStringToBigInt(ps.PolsB)
 	if err != nil {
 		return nil, err
 	}
+	p.PolsB = types.PolsFromMaps(polsB)
 
 	return &p, nil
 }
@@ -248,6 +289,48 @@ func vkStringToVk(vr VkString) (*types.Vk, error) {
 	return &v, nil
 }
 
+// VkToString converts the Vk to VkString
+func VkToString(vk *types.Vk) VkString {
+	var vs VkString
+	vs.Alpha = g1ToString(vk.Alpha)
+	vs.Beta = g2ToString(vk.Beta)
+	vs.Gamma = g2ToString(vk.Gamma)
+	vs.Delta = g2ToString(vk.Delta)
+	for _, p := range vk.IC {
+		vs.IC = append(vs.IC, g1ToString(p))
+	}
+	return vs
+}
+
+// VkToJson outputs the Vk in Json format
+func VkToJson(vk *types.Vk) ([]byte, error) {
+	vs := VkToString(vk)
+	return json.Marshal(vs)
+}
+
+// g1ToString returns the projective coordinates of p as snarkjs writes them,
+// with the point at infinity as [0, 1, 0]
+func g1ToString(p *bn256.G1) []string {
+	b := p.Marshal()
+	if new(big.Int).SetBytes(b).Sign() == 0 {
+		return []string{"0", "1", "0"}
+	}
+	return []string{
+		new(big.Int).SetBytes(b[:32]).String(),
+		new(big.Int).SetBytes(b[32:64]).String(),
+		"1",
+	}
+}
+
+func g2ToString(p *bn256.G2) [][]string {
+	b := p.Marshal()
+	return [][]string{
+		{new(big.Int).SetBytes(b[32:64]).String(), new(big.Int).SetBytes(b[:32]).String()},
+		{new(big.Int).SetBytes(b[96:128]).String(), new(big.Int).SetBytes(b[64:96]).String()},
+		{"1", "0"},
+	}
+}
+
 // polsStringToBigInt is for taking string polynomials and converting it to *big.Int polynomials
 func polsStringToBigInt(s []map[string]string) ([]map[int]*big.Int, error) {
 	var o []map[int]*big.Int
@@ -591,26 +674,8 @@ func ParseWitnessBin(f *os.File) (types.Witness, error) {
 		if n != 32 {
 			return nil, fmt.Errorf("error on value format, expected 32 bytes, got %v", n)
 		}
-		w = append(w, new(big.Int).SetBytes(swapEndianness(b[0:32])))
-	}
-}
-
-// swapEndianness swaps the order of the bytes in the slice.
-func swapEndianness(b []byte) []byte {
-	o := make([]byte, len(b))
-	for i := range b {
-		o[len(b)-1-i] = b[i]
+		w = append(w, new(big.Int).SetBytes(binfile.SwapEndianness(b[0:32])))
 	}
-	return o
-}
-
-func readNBytes(r io.Reader, n int) ([]byte, error) {
-	b := make([]byte, n)
-	_, err := io.ReadFull(r, b)
-	if err != nil {
-		return b, err
-	}
-	return b, nil
 }
 
 // ParsePkBin parses binary file representation of the ProvingKey into the ProvingKey struct
@@ -619,7 +684,7 @@ func ParsePkBin(f *os.File) (*types.Pk, error) {
 	var pk types.Pk
 	r := bufio.NewReader(f)
 
-	b, err := readNBytes(r, 12)
+	b, err := binfile.ReadNBytes(r, 12)
 	if err != nil {
 		return nil, err
 	}
@@ -628,7 +693,7 @@ func ParsePkBin(f *os.File) (*types.Pk, error) {
 	pk.DomainSize = int(binary.LittleEndian.Uint32(b[8:12]))
 	o += 12
 
-	b, err = readNBytes(r, 8)
+	b, err = binfile.ReadNBytes(r, 8)
 	if err != nil {
 		return nil, err
 	}
@@ -636,7 +701,7 @@ func ParsePkBin(f *os.File) (*types.Pk, error) {
 	pPolsB := int(binary.LittleEndian.Uint32(b[4:8]))
 	o += 8
 
-	b, err = readNBytes(r, 20)
+	b, err = binfile.ReadNBytes(r, 20)
 	if err != nil {
 		return nil, err
 	}
@@ -647,50 +712,25 @@ func ParsePkBin(f *os.File) (*types.Pk, error) {
 	pPointsHExps := int(binary.LittleEndian.Uint32(b[16:20]))
 	o += 20
 
-	b, err = readNBytes(r, 64)
-	if err != nil {
-		return nil, err
-	}
-	pk.VkAlpha1 = new(bn256.G1)
-	_, err = pk.VkAlpha1.Unmarshal(fromMont1Q(b))
+	pk.VkAlpha1, err = binfile.ReadG1(r)
 	if err != nil {
 		return nil, err
 	}
 
-	b, err = readNBytes(r, 64)
-	if err != nil {
-		return nil, err
-	}
-	pk.VkBeta1 = new(bn256.G1)
-	_, err = pk.VkBeta1.Unmarshal(fromMont1Q(b))
+	pk.VkBeta1, err = binfile.ReadG1(r)
 	if err != nil {
 		return nil, err
 	}
 
-	b, err = readNBytes(r, 64)
+	pk.VkDelta1, err = binfile.ReadG1(r)
 	if err != nil {
 		return nil, err
 	}
-	pk.VkDelta1 = new(bn256.G1)
-	_, err = pk.VkDelta1.Unmarshal(fromMont1Q(b))
+	pk.VkBeta2, err = binfile.ReadG2(r)
 	if err != nil {
 		return nil, err
 	}
-	b, err = readNBytes(r, 128)
-	if err != nil {
-		return nil, err
-	}
-	pk.VkBeta2 = new(bn256.G2)
-	_, err = pk.VkBeta2.Unmarshal(fromMont2Q(b))
-	if err != nil {
-		return nil, err
-	}
-	b, err = readNBytes(r, 128)
-	if err != nil {
-		return nil, err
-	}
-	pk.VkDelta2 = new(bn256.G2)
-	_, err = pk.VkDelta2.Unmarshal(fromMont2Q(b))
+	pk.VkDelta2, err = binfile.ReadG2(r)
 	if err != nil {
 		return nil, err
 	}
@@ -700,71 +740,24 @@ func ParsePkBin(f *os.File) (*types.Pk, error) {
 	}
 
 	// PolsA
-	for i := 0; i < pk.NVars; i++ {
-		b, err = readNBytes(r, 4)
-		if err != nil {
-			return nil, err
-		}
-		keysLength := int(binary.LittleEndian.Uint32(b[:4]))
-		o += 4
-		polsMap := make(map[int]*big.Int)
-		for j := 0; j < keysLength; j++ {
-			bK, err := readNBytes(r, 4)
-			if err != nil {
-				return nil, err
-			}
-			key := int(binary.LittleEndian.Uint32(bK[:4]))
-			o += 4
-
-			b, err := readNBytes(r, 32)
-			if err != nil {
-				return nil, err
-			}
-			polsMap[key] = new(big.Int).SetBytes(fromMont1R(b[:32]))
-			o += 32
-		}
-		pk.PolsA = append(pk.PolsA, polsMap)
+	pk.PolsA, o, err = readPolsBin(r, pk.NVars, o, pPolsB, elementFromMontLE)
+	if err != nil {
+		return nil, err
 	}
 	if o != pPolsB {
 		return nil, fmt.Errorf("Unexpected offset, expected: %v, actual: %v", pPolsB, o)
 	}
 	// PolsB
-	for i := 0; i < pk.NVars; i++ {
-		b, err = readNBytes(r, 4)
-		if err != nil {
-			return nil, err
-		}
-		keysLength := int(binary.LittleEndian.Uint32(b[:4]))
-		o += 4
-		polsMap := make(map[int]*big.Int)
-		for j := 0; j < keysLength; j++ {
-			bK, err := readNBytes(r, 4)
-			if err != nil {
-				return nil, err
-			}
-			key := int(binary.LittleEndian.Uint32(bK[:4]))
-			o += 4
-
-			b, err := readNBytes(r, 32)
-			if err != nil {
-				return nil, err
-			}
-			polsMap[key] = new(big.Int).SetBytes(fromMont1R(b[:32]))
-			o += 32
-		}
-		pk.PolsB = append(pk.PolsB, polsMap)
+	pk.PolsB, o, err = readPolsBin(r, pk.NVars, o, pPointsA, elementFromMontLE)
+	if err != nil {
+		return nil, err
 	}
 	if o != pPointsA {
 		return nil, fmt.Errorf("Unexpected offset, expected: %v, actual: %v", pPointsA, o)
 	}
 	// A
 	for i := 0; i < pk.NVars; i++ {
-		b, err = readNBytes(r, 64)
-		if err != nil {
-			return nil, err
-		}
-		p1 := new(bn256.G1)
-		_, err = p1.Unmarshal(fromMont1Q(b))
+		p1, err := binfile.ReadG1(r)
 		if err != nil {
 			return nil, err
 		}
@@ -776,12 +769,7 @@ func ParsePkBin(f *os.File) (*types.Pk, error) {
 	}
 	// B1
 	for i := 0; i < pk.NVars; i++ {
-		b, err = readNBytes(r, 64)
-		if err != nil {
-			return nil, err
-		}
-		p1 := new(bn256.G1)
-		_, err = p1.Unmarshal(fromMont1Q(b))
+		p1, err := binfile.ReadG1(r)
 		if err != nil {
 			return nil, err
 		}
@@ -793,12 +781,7 @@ func ParsePkBin(f *os.File) (*types.Pk, error) {
 	}
 	// B2
 	for i := 0; i < pk.NVars; i++ {
-		b, err = readNBytes(r, 128)
-		if err != nil {
-			return nil, err
-		}
-		p2 := new(bn256.G2)
-		_, err = p2.Unmarshal(fromMont2Q(b))
+		p2, err := binfile.ReadG2(r)
 		if err != nil {
 			return nil, err
 		}
@@ -819,12 +802,7 @@ func ParsePkBin(f *os.File) (*types.Pk, error) {
 		pk.C = append(pk.C, z)
 	}
 	for i := pk.NPublic + 1; i < pk.NVars; i++ {
-		b, err = readNBytes(r, 64)
-		if err != nil {
-			return nil, err
-		}
-		p1 := new(bn256.G1)
-		_, err = p1.Unmarshal(fromMont1Q(b))
+		p1, err := binfile.ReadG1(r)
 		if err != nil {
 			return nil, err
 		}
@@ -836,12 +814,7 @@ func ParsePkBin(f *os.File) (*types.Pk, error) {
 	}
 	// HExps
 	for i := 0; i < pk.DomainSize; i++ {
-		b, err = readNBytes(r, 64)
-		if err != nil {
-			return nil, err
-		}
-		p1 := new(bn256.G1)
-		_, err = p1.Unmarshal(fromMont1Q(b))
+		p1, err := binfile.ReadG1(r)
 		if err != nil {
 			return nil, err
 		}
@@ -850,124 +823,85 @@ func ParsePkBin(f *os.File) (*types.Pk, error) {
 	return &pk, nil
 }
 
-func fromMont1Q(m []byte) []byte {
-	a := new(big.Int).SetBytes(swapEndianness(m[:32]))
-	b := new(big.Int).SetBytes(swapEndianness(m[32:64]))
-
-	x := coordFromMont(a, types.Q)
-	y := coordFromMont(b, types.Q)
-	if bytes.Equal(x.Bytes(), big.NewInt(1).Bytes()) {
-		x = big.NewInt(0)
-	}
-	if bytes.Equal(y.Bytes(), big.NewInt(1).Bytes()) {
-		y = big.NewInt(0)
+// elementFromMontLE returns the field element of the 32 bytes in little
+// endian Montgomery form, the format of the coefficients of the .bin and
+// .zkey files
+func elementFromMontLE(b []byte) (ff.Element, error) {
+	var e ff.Element
+	for i := range e {
+		e[i] = binary.LittleEndian.Uint64(b[i*8 : (i+1)*8])
 	}
-
-	xBytes := x.Bytes()
-	yBytes := y.Bytes()
-	if len(xBytes) != 32 {
-		xBytes = addZPadding(xBytes)
+	if !lessThanR(&e) {
+		return e, fmt.Errorf("Coef outside the field")
 	}
-	if len(yBytes) != 32 {
-		yBytes = addZPadding(yBytes)
-	}
-
-	var p []byte
-	p = append(p, xBytes...)
-	p = append(p, yBytes...)
-
-	return p
+	return e, nil
 }
 
-func fromMont2Q(m []byte) []byte {
-	a := new(big.Int).SetBytes(swapEndianness(m[:32]))
-	b := new(big.Int).SetBytes(swapEndianness(m[32:64]))
-	c := new(big.Int).SetBytes(swapEndianness(m[64:96]))
-	d := new(big.Int).SetBytes(swapEndianness(m[96:128]))
-
-	x := coordFromMont(a, types.Q)
-	y := coordFromMont(b, types.Q)
-	z := coordFromMont(c, types.Q)
-	t := coordFromMont(d, types.Q)
-
-	if bytes.Equal(x.Bytes(), big.NewInt(1).Bytes()) {
-		x = big.NewInt(0)
-	}
-	if bytes.Equal(y.Bytes(), big.NewInt(1).Bytes()) {
-		y = big.NewInt(0)
-	}
-	if bytes.Equal(z.Bytes(), big.NewInt(1).Bytes()) {
-		z = big.NewInt(0)
-	}
-	if bytes.Equal(t.Bytes(), big.NewInt(1).Bytes()) {
-		t = big.NewInt(0)
-	}
-
-	xBytes := x.Bytes()
-	yBytes := y.Bytes()
-	zBytes := z.Bytes()
-	tBytes := t.Bytes()
-	if len(xBytes) != 32 {
-		xBytes = addZPadding(xBytes)
-	}
-	if len(yBytes) != 32 {
-		yBytes = addZPadding(yBytes)
+// elementFromBE returns the field element of the 32 bytes in big endian
+// regular form, the format of the coefficients of the .go.bin files
+func elementFromBE(b []byte) (ff.Element, error) {
+	var e ff.Element
+	for i := range e {
+		e[i] = binary.BigEndian.Uint64(b[32-(i+1)*8 : 32-i*8])
 	}
-	if len(zBytes) != 32 {
-		zBytes = addZPadding(zBytes)
+	if !lessThanR(&e) {
+		return e, fmt.Errorf("Coef outside the field")
 	}
-	if len(tBytes) != 32 {
-		tBytes = addZPadding(tBytes)
-	}
-
-	var p []byte
-	p = append(p, yBytes...) // swap
-	p = append(p, xBytes...)
-	p = append(p, tBytes...)
-	p = append(p, zBytes...)
-
-	return p
+	return *e.ToMont(), nil
 }
 
-func fromMont1R(m []byte) []byte {
-	a := new(big.Int).SetBytes(swapEndianness(m[:32]))
-
-	x := coordFromMont(a, types.R)
-
-	return x.Bytes()
-}
-
-func fromMont2R(m []byte) []byte {
-	a := new(big.Int).SetBytes(swapEndianness(m[:32]))
-	b := new(big.Int).SetBytes(swapEndianness(m[32:64]))
-	c := new(big.Int).SetBytes(swapEndianness(m[64:96]))
-	d := new(big.Int).SetBytes(swapEndianness(m[96:128]))
-
-	x := coordFromMont(a, types.R)
-	y := coordFromMont(b, types.R)
-	z := coordFromMont(c, types.R)
-	t := coordFromMont(d, types.R)
-
-	var p []byte
-	p = append(p, y.Bytes()...) // swap
-	p = append(p, x.Bytes()...)
-	p = append(p, t.Bytes()...)
-	p = append(p, z.Bytes()...)
-
-	return p
+// rLimbs are the 64 bits limbs of R, least significant first
+var rLimbs = func() [4]uint64 {
+	var l [4]uint64
+	r := addPadding32(types.R.Bytes())
+	for i := range l {
+		l[i] = binary.BigEndian.Uint64(r[32-(i+1)*8 : 32-i*8])
+	}
+	return l
+}()
+
+// lessThanR returns true when the limbs of e are a value smaller than R
+func lessThanR(e *ff.Element) bool {
+	for i := len(e) - 1; i >= 0; i-- {
+		if e[i] != rLimbs[i] {
+			return e[i] < rLimbs[i]
+		}
+	}
+	return false
 }
 
-func coordFromMont(u, q *big.Int) *big.Int {
-	return new(big.Int).Mod(
-		new(big.Int).Mul(
-			u,
-			new(big.Int).ModInverse(
-				new(big.Int).Lsh(big.NewInt(1), 256),
-				q,
-			),
-		),
-		q,
-	)
+// readPolsBin reads the coefficients of the nVars wires of PolsA or PolsB in
+// the format of the .bin and .go.bin files, which end at the offset end,
+// converting each one with elem, and returns the Pols and the offset after
+// them
+func readPolsBin(r io.Reader, nVars, o, end int, elem func([]byte) (ff.Element, error)) (types.Pols, int, error) {
+	pols := types.NewPols(0)
+	for i := 0; i < nVars; i++ {
+		b, err := binfile.ReadNBytes(r, 4)
+		if err != nil {
+			return pols, o, err
+		}
+		keysLength := int(binary.LittleEndian.Uint32(b[:4]))
+		o += 4
+		if int64(keysLength)*36 > int64(end-o) {
+			return pols, o, fmt.Errorf("Pols of wire %v exceed the offset %v", i, end)
+		}
+		b, err = binfile.ReadNBytes(r, keysLength*36)
+		if err != nil {
+			return pols, o, err
+		}
+		for j := 0; j < keysLength; j++ {
+			key := int(binary.LittleEndian.Uint32(b[j*36 : j*36+4]))
+			v, err := elem(b[j*36+4 : (j+1)*36])
+			if err != nil {
+				return pols, o, err
+			}
+			pols.Append(key, &v)
+		}
+		pols.EndWire()
+		o += keysLength * 36
+	}
+	return pols, o, nil
 }
 
 func sortedKeys(m map[int]*big.Int) []int {
@@ -979,6 +913,21 @@ func sortedKeys(m map[int]*big.Int) []int {
 	return keys
 }
 
+// The go.bin files start with goBinMagic and the version of the format.
+// The files written before the version have no magic, start with NVars, and
+// have DomainSize+1 HExps over the domain, without the number of HExps and
+// the flags that follow the verification key points since version 1.  The
+// offsets of the sections are uint32 up to version 1, and uint64 since
+// version 2.
+const (
+	goBinMagic   = "gbin"
+	goBinVersion = 2
+)
+
+// goBinHExpsCoset is the flag of the go.bin files whose HExps are the
+// Lagrange basis over the odd coset (types.Pk.HExpsCoset)
+const goBinHExpsCoset = 1
+
 // PkToGoBin converts the ProvingKey (*types.Pk) into binary format defined by
 // go-circom-prover-verifier.  PkGoBin is a own go-circom-prover-verifier
 // binary format that allows to go faster when parsing.
@@ -986,6 +935,11 @@ func PkToGoBin(pk *types.Pk) ([]byte, error) {
 	var r []byte
 	o := 0
 	var b [4]byte
+	r = append(r, goBinMagic...)
+	binary.LittleEndian.PutUint32(b[:], goBinVersion)
+	r = append(r, b[:]...)
+	o += 8
+
 	binary.LittleEndian.PutUint32(b[:], uint32(pk.NVars))
 	r = append(r, b[:]...)
 
@@ -997,17 +951,17 @@ func PkToGoBin(pk *types.Pk) ([]byte, error) {
 	o += 12
 
 	// reserve space for pols (A, B) pos
-	b = [4]byte{}
-	r = append(r, b[:]...) // 12:16
-	r = append(r, b[:]...) // 16:20
-	o += 8
+	var b8 [8]byte
+	r = append(r, b8[:]...) // 20:28
+	r = append(r, b8[:]...) // 28:36
+	o += 16
 	// reserve space for points (A, B1, B2, C, HExps) pos
-	r = append(r, b[:]...) // 20:24
-	r = append(r, b[:]...) // 24
-	r = append(r, b[:]...) // 28
-	r = append(r, b[:]...) // 32
-	r = append(r, b[:]...) // 36:40
-	o += 20
+	r = append(r, b8[:]...) // 36:44
+	r = append(r, b8[:]...) // 44
+	r = append(r, b8[:]...) // 52
+	r = append(r, b8[:]...) // 60
+	r = append(r, b8[:]...) // 68:76
+	o += 40
 
 	pb1 := pk.VkAlpha1.Marshal()
 	r = append(r, pb1[:]...)
@@ -1021,65 +975,81 @@ func PkToGoBin(pk *types.Pk) ([]byte, error) {
 	r = append(r, pb2[:]...)
 	o += 448
 
+	// number of HExps and flags
+	if len(pk.HExps) < pk.DomainSize {
+		return nil, fmt.Errorf("Unexpected HExps length, expected: >= %v, actual: %v", pk.DomainSize, len(pk.HExps))
+	}
+	binary.LittleEndian.PutUint32(b[:], uint32(len(pk.HExps)))
+	r = append(r, b[:]...)
+	var flags uint32
+	if pk.HExpsCoset {
+		flags |= goBinHExpsCoset
+	}
+	binary.LittleEndian.PutUint32(b[:], flags)
+	r = append(r, b[:]...)
+	o += 8
+
 	// polsA
-	binary.LittleEndian.PutUint32(r[12:16], uint32(o))
+	binary.LittleEndian.PutUint64(r[20:28], uint64(o))
 	for i := 0; i < pk.NVars; i++ {
-		binary.LittleEndian.PutUint32(b[:], uint32(len(pk.PolsA[i])))
+		constraints, coefs := pk.PolsA.Wire(i)
+		binary.LittleEndian.PutUint32(b[:], uint32(len(constraints)))
 		r = append(r, b[:]...)
 		o += 4
-		for _, j := range sortedKeys(pk.PolsA[i]) {
-			v := pk.PolsA[i][j]
-			binary.LittleEndian.PutUint32(b[:], uint32(j))
+		for j, c := range constraints {
+			v := coefs[j].ToBigIntRegular(new(big.Int))
+			binary.LittleEndian.PutUint32(b[:], c)
 			r = append(r, b[:]...)
 			r = append(r, addPadding32(v.Bytes())...)
 			o += 32 + 4
 		}
 	}
 	// polsB
-	binary.LittleEndian.PutUint32(r[16:20], uint32(o))
+	binary.LittleEndian.PutUint64(r[28:36], uint64(o))
 	for i := 0; i < pk.NVars; i++ {
-		binary.LittleEndian.PutUint32(b[:], uint32(len(pk.PolsB[i])))
+		constraints, coefs := pk.PolsB.Wire(i)
+		binary.LittleEndian.PutUint32(b[:], uint32(len(constraints)))
 		r = append(r, b[:]...)
 		o += 4
-		for _, j := range sortedKeys(pk.PolsB[i]) {
-			v := pk.PolsB[i][j]
-			binary.LittleEndian.PutUint32(b[:], uint32(j))
+		for j, c := range constraints {
+			v := coefs[j].ToBigIntRegular(new(big.Int))
+			binary.LittleEndian.PutUint32(b[:], c)
 			r = append(r, b[:]...)
 			r = append(r, addPadding32(v.Bytes())...)
 			o += 32 + 4
 		}
 	}
 	// A
-	binary.LittleEndian.PutUint32(r[20:24], uint32(o))
+	binary.LittleEndian.PutUint64(r[36:44], uint64(o))
 	for i := 0; i < pk.NVars; i++ {
 		pb1 = pk.A[i].Marshal()
 		r = append(r, pb1[:]...)
 		o += 64
 	}
 	// B1
-	binary.LittleEndian.PutUint32(r[24:28], uint32(o))
+	binary.LittleEndian.PutUint64(r[44:52], uint64(o))
 	for i := 0; i < pk.NVars; i++ {
 		pb1 = pk.B1[i].Marshal()
 		r = append(r, pb1[:]...)
 		o += 64
 	}
 	// B2
-	binary.LittleEndian.PutUint32(r[28:32], uint32(o))
+	binary.LittleEndian.PutUint64(r[52:60], uint64(o))
 	for i := 0; i < pk.NVars; i++ {
 		pb2 = pk.B2[i].Marshal()
 		r = append(r, pb2[:]...)
 		o += 128
 	}
 	// C
-	binary.LittleEndian.PutUint32(r[32:36], uint32(o))
+	binary.LittleEndian.PutUint64(r[60:68], uint64(o))
 	for i := pk.NPublic + 1; i < pk.NVars; i++ {
 		pb1 = pk.C[i].Marshal()
 		r = append(r, pb1[:]...)
 		o += 64
 	}
 	// HExps
-	binary.LittleEndian.PutUint32(r[36:40], uint32(o))
-	for i := 0; i < pk.DomainSize+1; i++ {
+	binary.LittleEndian.PutUint64(r[68:76], uint64(o))
+	for i := range pk.HExps {
 		pb1 = pk.HExps[i].Marshal()
 		r = append(r, pb1[:]...)
 		o += 64
@@ -1093,236 +1063,627 @@ func PkToGoBin(pk *types.Pk) ([]byte, error) {
 // go-circom-prover-verifier binary format that allows to go faster when
 // parsing.
 func ParsePkGoBin(f *os.File) (*types.Pk, error) {
-	o := 0
-	var pk types.Pk
 	r := bufio.NewReader(f)
-
-	b, err := readNBytes(r, 12)
+	pk, points, nHExps, err := parsePkGoBinHeader(r)
 	if err != nil {
 		return nil, err
 	}
-	pk.NVars = int(binary.LittleEndian.Uint32(b[:4]))
-	pk.NPublic = int(binary.LittleEndian.Uint32(b[4:8]))
-	pk.DomainSize = int(binary.LittleEndian.Uint32(b[8:12]))
-	o += 12
-
-	b, err = readNBytes(r, 8)
+	o := int(points.A)
+	var b []byte
+	// A
+	for i := 0; i < pk.NVars; i++ {
+		b, err = binfile.ReadNBytes(r, 64)
+		if err != nil {
+			return nil, err
+		}
+		p1 := new(bn256.G1)
+		_, err = p1.Unmarshal(b)
+		if err != nil {
+			return nil, err
+		}
+		pk.A = append(pk.A, p1)
+		o += 64
+	}
+	if o != int(points.B1) {
+		return nil, fmt.Errorf("Unexpected offset, expected: %v, actual: %v", int(points.B1), o)
+	}
+	// B1
+	for i := 0; i < pk.NVars; i++ {
+		b, err = binfile.ReadNBytes(r, 64)
+		if err != nil {
+			return nil, err
+		}
+		p1 := new(bn256.G1)
+		_, err = p1.Unmarshal(b)
+		if err != nil {
+			return nil, err
+		}
+		pk.B1 = append(pk.B1, p1)
+		o += 64
+	}
+	if o != int(points.B2) {
+		return nil, fmt.Errorf("Unexpected offset, expected: %v, actual: %v", int(points.B2), o)
+	}
+	// B2
+	for i := 0; i < pk.NVars; i++ {
+		b, err = binfile.ReadNBytes(r, 128)
+		if err != nil {
+			return nil, err
+		}
+		p2 := new(bn256.G2)
+		_, err = p2.Unmarshal(b)
+		if err != nil {
+			return nil, err
+		}
+		pk.B2 = append(pk.B2, p2)
+		o += 128
+	}
+	if o != int(points.C) {
+		return nil, fmt.Errorf("Unexpected offset, expected: %v, actual: %v", int(points.C), o)
+	}
+	// C
+	zb := make([]byte, 64)
+	z := new(bn256.G1)
+	_, err = z.Unmarshal(zb)
 	if err != nil {
 		return nil, err
 	}
-	pPolsA := int(binary.LittleEndian.Uint32(b[:4]))
-	pPolsB := int(binary.LittleEndian.Uint32(b[4:8]))
-	o += 8
+	for i := 0; i < pk.NPublic+1; i++ {
+		pk.C = append(pk.C, z)
+	}
+	for i := pk.NPublic + 1; i < pk.NVars; i++ {
+		b, err = binfile.ReadNBytes(r, 64)
+		if err != nil {
+			return nil, err
+		}
+		p1 := new(bn256.G1)
+		_, err = p1.Unmarshal(b)
+		if err != nil {
+			return nil, err
+		}
+		pk.C = append(pk.C, p1)
+		o += 64
+	}
+	if o != int(points.HExps) {
+		return nil, fmt.Errorf("Unexpected offset, expected: %v, actual: %v", int(points.HExps), o)
+	}
+	// HExps
+	for i := 0; i < nHExps; i++ {
+		b, err = binfile.ReadNBytes(r, 64)
+		if err != nil {
+			return nil, err
+		}
+		p1 := new(bn256.G1)
+		_, err = p1.Unmarshal(b)
+		if err != nil {
+			return nil, err
+		}
+		pk.HExps = append(pk.HExps, p1)
+	}
+
+	return pk, nil
+}
 
-	b, err = readNBytes(r, 20)
+// parsePkGoBinHeader reads the go.bin file up to the points, returning the
+// ProvingKey without its points, the positions of the point sections and
+// the number of HExps
+func parsePkGoBinHeader(r io.Reader) (*types.Pk, *types.PkPoints, int, error) {
+	o := 0
+	var pk types.Pk
+
+	b, err := binfile.ReadNBytes(r, 4)
 	if err != nil {
-		return nil, err
+		return nil, nil, 0, err
 	}
-	pPointsA := int(binary.LittleEndian.Uint32(b[:4]))
-	pPointsB1 := int(binary.LittleEndian.Uint32(b[4:8]))
-	pPointsB2 := int(binary.LittleEndian.Uint32(b[8:12]))
-	pPointsC := int(binary.LittleEndian.Uint32(b[12:16]))
-	pPointsHExps := int(binary.LittleEndian.Uint32(b[16:20]))
-	o += 20
+	version := 0
+	if string(b) == goBinMagic {
+		if b, err = binfile.ReadNBytes(r, 4); err != nil {
+			return nil, nil, 0, err
+		}
+		version = int(binary.LittleEndian.Uint32(b))
+		if version < 1 || version > goBinVersion {
+			return nil, nil, 0, fmt.Errorf("Unsupported go.bin version: %v", version)
+		}
+		o += 8
+		if b, err = binfile.ReadNBytes(r, 4); err != nil {
+			return nil, nil, 0, err
+		}
+	}
+	pk.NVars = int(binary.LittleEndian.Uint32(b))
+	b, err = binfile.ReadNBytes(r, 8)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	pk.NPublic = int(binary.LittleEndian.Uint32(b[:4]))
+	pk.DomainSize = int(binary.LittleEndian.Uint32(b[4:8]))
+	o += 12
 
-	b, err = readNBytes(r, 64)
+	// the offsets of the pols (A, B) and of the points (A, B1, B2, C, HExps)
+	offsetSize := 4
+	if version >= 2 {
+		offsetSize = 8
+	}
+	b, err = binfile.ReadNBytes(r, 7*offsetSize)
 	if err != nil {
-		return nil, err
+		return nil, nil, 0, err
+	}
+	var offsets [7]int
+	for i := range offsets {
+		if offsetSize == 4 {
+			offsets[i] = int(binary.LittleEndian.Uint32(b[4*i:]))
+			continue
+		}
+		v := binary.LittleEndian.Uint64(b[8*i:])
+		if v > math.MaxInt64 {
+			return nil, nil, 0, fmt.Errorf("Invalid offset: %v", v)
+		}
+		offsets[i] = int(v)
+	}
+	pPolsA, pPolsB := offsets[0], offsets[1]
+	pPointsA, pPointsB1, pPointsB2, pPointsC, pPointsHExps := offsets[2], offsets[3], offsets[4], offsets[5], offsets[6]
+	o += 7 * offsetSize
+
+	b, err = binfile.ReadNBytes(r, 64)
+	if err != nil {
+		return nil, nil, 0, err
 	}
 	pk.VkAlpha1 = new(bn256.G1)
 	_, err = pk.VkAlpha1.Unmarshal(b)
 	if err != nil {
-		return &pk, err
+		return nil, nil, 0, err
 	}
-	b, err = readNBytes(r, 64)
+	b, err = binfile.ReadNBytes(r, 64)
 	if err != nil {
-		return nil, err
+		return nil, nil, 0, err
 	}
 	pk.VkBeta1 = new(bn256.G1)
 	_, err = pk.VkBeta1.Unmarshal(b)
 	if err != nil {
-		return &pk, err
+		return nil, nil, 0, err
 	}
-	b, err = readNBytes(r, 64)
+	b, err = binfile.ReadNBytes(r, 64)
 	if err != nil {
-		return nil, err
+		return nil, nil, 0, err
 	}
 	pk.VkDelta1 = new(bn256.G1)
 	_, err = pk.VkDelta1.Unmarshal(b)
 	if err != nil {
-		return &pk, err
+		return nil, nil, 0, err
 	}
-	b, err = readNBytes(r, 128)
+	b, err = binfile.ReadNBytes(r, 128)
 	if err != nil {
-		return nil, err
+		return nil, nil, 0, err
 	}
 	pk.VkBeta2 = new(bn256.G2)
 	_, err = pk.VkBeta2.Unmarshal(b)
 	if err != nil {
-		return &pk, err
+		return nil, nil, 0, err
 	}
-	b, err = readNBytes(r, 128)
+	b, err = binfile.ReadNBytes(r, 128)
 	if err != nil {
-		return nil, err
+		return nil, nil, 0, err
 	}
 	pk.VkDelta2 = new(bn256.G2)
 	_, err = pk.VkDelta2.Unmarshal(b)
 	if err != nil {
-		return &pk, err
+		return nil, nil, 0, err
 	}
 	o += 448
+	nHExps := pk.DomainSize + 1
+	if version >= 1 {
+		b, err = binfile.ReadNBytes(r, 8)
+		if err != nil {
+			return nil, nil, 0, err
+		}
+		nHExps = int(binary.LittleEndian.Uint32(b[:4]))
+		flags := binary.LittleEndian.Uint32(b[4:8])
+		pk.HExpsCoset = flags&goBinHExpsCoset != 0
+		o += 8
+		if nHExps < pk.DomainSize {
+			return nil, nil, 0, fmt.Errorf("Unexpected HExps length, expected: >= %v, actual: %v", pk.DomainSize, nHExps)
+		}
+	}
 	if o != pPolsA {
-		return nil, fmt.Errorf("Unexpected offset, expected: %v, actual: %v", pPolsA, o)
+		return nil, nil, 0, fmt.Errorf("Unexpected offset, expected: %v, actual: %v", pPolsA, o)
 	}
 
 	// PolsA
-	for i := 0; i < pk.NVars; i++ {
-		b, err = readNBytes(r, 4)
-		if err != nil {
-			return nil, err
-		}
-		keysLength := int(binary.LittleEndian.Uint32(b[:4]))
-		o += 4
-		polsMap := make(map[int]*big.Int)
-		for j := 0; j < keysLength; j++ {
-			bK, err := readNBytes(r, 4)
-			if err != nil {
-				return nil, err
-			}
-			key := int(binary.LittleEndian.Uint32(bK[:4]))
-			o += 4
-
-			b, err := readNBytes(r, 32)
-			if err != nil {
-				return nil, err
-			}
-			polsMap[key] = new(big.Int).SetBytes(b[:32])
-			o += 32
-		}
-		pk.PolsA = append(pk.PolsA, polsMap)
+	pk.PolsA, o, err = readPolsBin(r, pk.NVars, o, pPolsB, elementFromBE)
+	if err != nil {
+		return nil, nil, 0, err
 	}
 	if o != pPolsB {
-		return nil, fmt.Errorf("Unexpected offset, expected: %v, actual: %v", pPolsB, o)
+		return nil, nil, 0, fmt.Errorf("Unexpected offset, expected: %v, actual: %v", pPolsB, o)
 	}
 	// PolsB
-	for i := 0; i < pk.NVars; i++ {
-		b, err = readNBytes(r, 4)
-		if err != nil {
-			return nil, err
+	pk.PolsB, o, err = readPolsBin(r, pk.NVars, o, pPointsA, elementFromBE)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if o != pPointsA {
+		return nil, nil, 0, fmt.Errorf("Unexpected offset, expected: %v, actual: %v", pPointsA, o)
+	}
+	return &pk, &types.PkPoints{
+		Encoding: types.PointsBE,
+		A:        int64(pPointsA),
+		B1:       int64(pPointsB1),
+		B2:       int64(pPointsB2),
+		C:        int64(pPointsC),
+		HExps:    int64(pPointsHExps),
+	}, nHExps, nil
+}
+
+// ParsePkGoBinHeader parses the go.bin file of the ProvingKey without its
+// points, and returns the positions of the point sections of the file, to
+// generate the proofs reading the points from the file in chunks (see
+// prover.NewStreamProver).  The sizes of the sections and of the file are
+// checked, but the points are not read.
+func ParsePkGoBinHeader(f *os.File) (*types.Pk, *types.PkPoints, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return nil, nil, err
+	}
+	pk, points, nHExps, err := parsePkGoBinHeader(bufio.NewReader(f))
+	if err != nil {
+		return nil, nil, err
+	}
+	nVars, nPrivate := int64(pk.NVars), int64(pk.NVars-pk.NPublic-1)
+	for _, s := range []struct {
+		name           string
+		start, end, sz int64
+	}{
+		{"A", points.A, points.B1, 64 * nVars},
+		{"B1", points.B1, points.B2, 64 * nVars},
+		{"B2", points.B2, points.C, 128 * nVars},
+		{"C", points.C, points.HExps, 64 * nPrivate},
+	} {
+		if s.end-s.start != s.sz {
+			return nil, nil, fmt.Errorf("Unexpected %s section size, expected: %v, actual: %v", s.name, s.sz, s.end-s.start)
 		}
-		keysLength := int(binary.LittleEndian.Uint32(b[:4]))
-		o += 4
-		polsMap := make(map[int]*big.Int)
-		for j := 0; j < keysLength; j++ {
-			bK, err := readNBytes(r, 4)
-			if err != nil {
-				return nil, err
-			}
-			key := int(binary.LittleEndian.Uint32(bK[:4]))
-			o += 4
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	if expected := points.HExps + 64*int64(nHExps); fi.Size() != expected {
+		return nil, nil, fmt.Errorf("Unexpected file size, expected: %v, actual: %v", expected, fi.Size())
+	}
+	return pk, points, nil
+}
 
-			b, err := readNBytes(r, 32)
-			if err != nil {
-				return nil, err
-			}
-			polsMap[key] = new(big.Int).SetBytes(b[:32])
-			o += 32
+// parseZkeyHeader reads the sections of the .zkey file up to the points,
+// returning the ProvingKey without its points, the VerificationKey and the
+// sections of the file, whose sizes are checked
+func parseZkeyHeader(f *os.File) (*types.Pk, *types.Vk, map[int]binfile.Section, error) {
+	_, sections, err := binfile.ReadHeader(f, "zkey", 1)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	// Header
+	r, size, err := binfile.StartReadSection(f, sections, 1)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if size != 4 {
+		return nil, nil, nil, fmt.Errorf("Unexpected zkey header size, expected: 4, actual: %v", size)
+	}
+	b, err := binfile.ReadNBytes(r, 4)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	protocol := int(binary.LittleEndian.Uint32(b[:4]))
+	if protocol != 1 {
+		return nil, nil, nil, fmt.Errorf("Unsupported zkey protocol, expected: 1 (groth16), actual: %v", protocol)
+	}
+
+	// Groth16 header
+	var pk types.Pk
+	var vk types.Vk
+	r, _, err = binfile.StartReadSection(f, sections, 2)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if err = binfile.ReadFieldHeader(r, types.Q); err != nil {
+		return nil, nil, nil, err
+	}
+	if err = binfile.ReadFieldHeader(r, types.R); err != nil {
+		return nil, nil, nil, err
+	}
+	b, err = binfile.ReadNBytes(r, 12)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	pk.NVars = int(binary.LittleEndian.Uint32(b[:4]))
+	pk.NPublic = int(binary.LittleEndian.Uint32(b[4:8]))
+	pk.DomainSize = int(binary.LittleEndian.Uint32(b[8:12]))
+
+	if pk.VkAlpha1, err = binfile.ReadG1(r); err != nil {
+		return nil, nil, nil, err
+	}
+	if pk.VkBeta1, err = binfile.ReadG1(r); err != nil {
+		return nil, nil, nil, err
+	}
+	if pk.VkBeta2, err = binfile.ReadG2(r); err != nil {
+		return nil, nil, nil, err
+	}
+	if vk.Gamma, err = binfile.ReadG2(r); err != nil {
+		return nil, nil, nil, err
+	}
+	if pk.VkDelta1, err = binfile.ReadG1(r); err != nil {
+		return nil, nil, nil, err
+	}
+	if pk.VkDelta2, err = binfile.ReadG2(r); err != nil {
+		return nil, nil, nil, err
+	}
+	vk.Alpha = pk.VkAlpha1
+	vk.Beta = pk.VkBeta2
+	vk.Delta = pk.VkDelta2
+
+	// IC
+	r, size, err = binfile.StartReadSection(f, sections, 3)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	if size != int64(pk.NPublic+1)*64 {
+		return nil, nil, nil, fmt.Errorf("Unexpected IC section size, expected: %v, actual: %v", (pk.NPublic+1)*64, size)
+	}
+	for i := 0; i < pk.NPublic+1; i++ {
+		p1, err := binfile.ReadG1(r)
+		if err != nil {
+			return nil, nil, nil, err
 		}
-		pk.PolsB = append(pk.PolsB, polsMap)
+		vk.IC = append(vk.IC, p1)
 	}
-	if o != pPointsA {
-		return nil, fmt.Errorf("Unexpected offset, expected: %v, actual: %v", pPointsA, o)
+
+	// Coefs (PolsA & PolsB)
+	r, size, err = binfile.StartReadSection(f, sections, 4)
+	if err != nil {
+		return nil, nil, nil, err
 	}
-	// A
-	for i := 0; i < pk.NVars; i++ {
-		b, err = readNBytes(r, 64)
+	b, err = binfile.ReadNBytes(r, 4)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	nCoefs := int(binary.LittleEndian.Uint32(b[:4]))
+	if size != 4+int64(nCoefs)*44 {
+		return nil, nil, nil, fmt.Errorf("Unexpected coefs section size, expected: %v, actual: %v", 4+nCoefs*44, size)
+	}
+	// the coefs are grouped by wire, keeping the order of the section
+	var wires, constraints [2][]uint32
+	var coefs [2][]ff.Element
+	for i := 0; i < nCoefs; i++ {
+		b, err = binfile.ReadNBytes(r, 44)
 		if err != nil {
-			return nil, err
+			return nil, nil, nil, err
 		}
-		p1 := new(bn256.G1)
-		_, err = p1.Unmarshal(b)
+		matrix := int(binary.LittleEndian.Uint32(b[:4]))
+		constraint := binary.LittleEndian.Uint32(b[4:8])
+		signal := binary.LittleEndian.Uint32(b[8:12])
+		if int(signal) >= pk.NVars {
+			return nil, nil, nil, fmt.Errorf("Coef signal out of range: %v", signal)
+		}
+		if int(constraint) >= pk.DomainSize {
+			return nil, nil, nil, fmt.Errorf("Coef constraint out of range: %v", constraint)
+		}
+		if matrix != 0 && matrix != 1 {
+			return nil, nil, nil, fmt.Errorf("Unexpected coef matrix: %v", matrix)
+		}
+		// the coefs are stored in Montgomery form multiplied again by
+		// the Montgomery factor
+		v, err := elementFromMontLE(b[12:44])
 		if err != nil {
-			return nil, err
+			return nil, nil, nil, err
+		}
+		v.FromMont()
+		wires[matrix] = append(wires[matrix], signal)
+		constraints[matrix] = append(constraints[matrix], constraint)
+		coefs[matrix] = append(coefs[matrix], v)
+	}
+	pk.PolsA = types.PolsFromCoefs(pk.NVars, wires[0], constraints[0], coefs[0])
+	pk.PolsB = types.PolsFromCoefs(pk.NVars, wires[1], constraints[1], coefs[1])
+	pk.HExpsCoset = true
+
+	// the sizes of the point sections
+	nVars, nPrivate := int64(pk.NVars), int64(pk.NVars-pk.NPublic-1)
+	for _, sc := range []struct {
+		name  string
+		sType int
+		size  int64
+	}{
+		{"A", 5, 64 * nVars},
+		{"B1", 6, 64 * nVars},
+		{"B2", 7, 128 * nVars},
+		{"C", 8, 64 * nPrivate},
+		{"H", 9, 64 * int64(pk.DomainSize)},
+	} {
+		s, ok := sections[sc.sType]
+		if !ok {
+			return nil, nil, nil, fmt.Errorf("Missing section %v", sc.sType)
+		}
+		if s.Size != sc.size {
+			return nil, nil, nil, fmt.Errorf("Unexpected %s section size, expected: %v, actual: %v", sc.name, sc.size, s.Size)
 		}
-		pk.A = append(pk.A, p1)
-		o += 64
 	}
-	if o != pPointsB1 {
-		return nil, fmt.Errorf("Unexpected offset, expected: %v, actual: %v", pPointsB1, o)
+	return &pk, &vk, sections, nil
+}
+
+// ParseZkey parses the snarkjs binary .zkey file representation of the
+// Groth16 ProvingKey into the ProvingKey and VerificationKey structs
+// (*types.Pk, *types.Vk).  The HExps of the returned ProvingKey are in the
+// Lagrange basis over the odd coset of the domain, as in the .zkey file.
+func ParseZkey(f *os.File) (*types.Pk, *types.Vk, error) {
+	pk, vk, sections, err := parseZkeyHeader(f)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// A
+	r, _, err := binfile.StartReadSection(f, sections, 5)
+	if err != nil {
+		return nil, nil, err
 	}
-	// B1
 	for i := 0; i < pk.NVars; i++ {
-		b, err = readNBytes(r, 64)
+		p1, err := binfile.ReadG1(r)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		p1 := new(bn256.G1)
-		_, err = p1.Unmarshal(b)
+		pk.A = append(pk.A, p1)
+	}
+	// B1
+	r, _, err = binfile.StartReadSection(f, sections, 6)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := 0; i < pk.NVars; i++ {
+		p1, err := binfile.ReadG1(r)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		pk.B1 = append(pk.B1, p1)
-		o += 64
-	}
-	if o != pPointsB2 {
-		return nil, fmt.Errorf("Unexpected offset, expected: %v, actual: %v", pPointsB2, o)
 	}
 	// B2
+	r, _, err = binfile.StartReadSection(f, sections, 7)
+	if err != nil {
+		return nil, nil, err
+	}
 	for i := 0; i < pk.NVars; i++ {
-		b, err = readNBytes(r, 128)
+		p2, err := binfile.ReadG2(r)
 		if err != nil {
-			return nil, err
-		}
-		p2 := new(bn256.G2)
-		_, err = p2.Unmarshal(b)
-		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		pk.B2 = append(pk.B2, p2)
-		o += 128
-	}
-	if o != pPointsC {
-		return nil, fmt.Errorf("Unexpected offset, expected: %v, actual: %v", pPointsC, o)
 	}
 	// C
-	zb := make([]byte, 64)
-	z := new(bn256.G1)
-	_, err = z.Unmarshal(zb)
+	r, _, err = binfile.StartReadSection(f, sections, 8)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
+	z := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
 	for i := 0; i < pk.NPublic+1; i++ {
 		pk.C = append(pk.C, z)
 	}
 	for i := pk.NPublic + 1; i < pk.NVars; i++ {
-		b, err = readNBytes(r, 64)
+		p1, err := binfile.ReadG1(r)
 		if err != nil {
-			return nil, err
-		}
-		p1 := new(bn256.G1)
-		_, err = p1.Unmarshal(b)
-		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 		pk.C = append(pk.C, p1)
-		o += 64
-	}
-	if o != pPointsHExps {
-		return nil, fmt.Errorf("Unexpected offset, expected: %v, actual: %v", pPointsHExps, o)
 	}
 	// HExps
-	for i := 0; i < pk.DomainSize+1; i++ {
-		b, err = readNBytes(r, 64)
+	r, _, err = binfile.StartReadSection(f, sections, 9)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := 0; i < pk.DomainSize; i++ {
+		p1, err := binfile.ReadG1(r)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
-		p1 := new(bn256.G1)
-		_, err = p1.Unmarshal(b)
+		pk.HExps = append(pk.HExps, p1)
+	}
+	return pk, vk, nil
+}
+
+// ParseZkeyHeader parses the .zkey file without the points of the
+// ProvingKey, and returns the positions of the point sections of the file,
+// to generate the proofs reading the points from the file in chunks (see
+// prover.NewStreamProver).  The sizes of the sections are checked, but the
+// points are not read.
+func ParseZkeyHeader(f *os.File) (*types.Pk, *types.Vk, *types.PkPoints, error) {
+	pk, vk, sections, err := parseZkeyHeader(f)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return pk, vk, &types.PkPoints{
+		Encoding: types.PointsMontLE,
+		A:        sections[5].Pos,
+		B1:       sections[6].Pos,
+		B2:       sections[7].Pos,
+		C:        sections[8].Pos,
+		HExps:    sections[9].Pos,
+	}, nil
+}
+
+// ParseWtns parses the iden3 binary .wtns file representation of the Witness
+// (generated by the circom witness calculators) into the Witness struct.  The
+// versions 1 and 2 of the format have the same sections.
+func ParseWtns(f *os.File) (types.Witness, error) {
+	_, sections, err := binfile.ReadHeader(f, "wtns", 2)
+	if err != nil {
+		return nil, err
+	}
+
+	// Header
+	r, size, err := binfile.StartReadSection(f, sections, 1)
+	if err != nil {
+		return nil, err
+	}
+	if size != 40 {
+		return nil, fmt.Errorf("Unexpected wtns header size, expected: 40, actual: %v", size)
+	}
+	if err = binfile.ReadFieldHeader(r, types.R); err != nil {
+		return nil, err
+	}
+	b, err := binfile.ReadNBytes(r, 4)
+	if err != nil {
+		return nil, err
+	}
+	nWitness := int(binary.LittleEndian.Uint32(b[:4]))
+
+	// Witness values
+	r, size, err = binfile.StartReadSection(f, sections, 2)
+	if err != nil {
+		return nil, err
+	}
+	if size != int64(nWitness)*32 {
+		return nil, fmt.Errorf("Unexpected wtns values size, expected: %v, actual: %v", nWitness*32, size)
+	}
+	w := make(types.Witness, nWitness)
+	for i := 0; i < nWitness; i++ {
+		b, err = binfile.ReadNBytes(r, 32)
 		if err != nil {
 			return nil, err
 		}
-		pk.HExps = append(pk.HExps, p1)
+		w[i] = new(big.Int).SetBytes(binfile.SwapEndianness(b))
+		if w[i].Cmp(types.R) != -1 {
+			return nil, fmt.Errorf("Witness value %v outside the field", i)
+		}
 	}
+	return w, nil
+}
 
-	return &pk, nil
+// WtnsToBytes converts the Witness into the iden3 binary .wtns file format
+func WtnsToBytes(w types.Witness) ([]byte, error) {
+	var r []byte
+	var b [4]byte
+	var b8 [8]byte
+	r = append(r, []byte("wtns")...)
+	binary.LittleEndian.PutUint32(b[:], 2) // version
+	r = append(r, b[:]...)
+	binary.LittleEndian.PutUint32(b[:], 2) // nSections
+	r = append(r, b[:]...)
+
+	// Header
+	binary.LittleEndian.PutUint32(b[:], 1)
+	r = append(r, b[:]...)
+	binary.LittleEndian.PutUint64(b8[:], 40)
+	r = append(r, b8[:]...)
+	binary.LittleEndian.PutUint32(b[:], 32)
+	r = append(r, b[:]...)
+	r = append(r, binfile.SwapEndianness(addPadding32(types.R.Bytes()))...)
+	binary.LittleEndian.PutUint32(b[:], uint32(len(w)))
+	r = append(r, b[:]...)
+
+	// Witness values
+	binary.LittleEndian.PutUint32(b[:], 2)
+	r = append(r, b[:]...)
+	binary.LittleEndian.PutUint64(b8[:], uint64(len(w))*32)
+	r = append(r, b8[:]...)
+	for i := 0; i < len(w); i++ {
+		if w[i].Sign() == -1 || w[i].Cmp(types.R) != -1 {
+			return nil, fmt.Errorf("Witness value %v outside the field", i)
+		}
+		r = append(r, binfile.SwapEndianness(addPadding32(w[i].Bytes()))...)
+	}
+	return r, nil
 }
@@ -1,12 +1,21 @@
 package parsers
 
 import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
 	"encoding/json"
 	"io/ioutil"
+	"math/big"
 	"os"
 	"testing"
 
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-circom-prover-verifier/internal/binfile"
+	"github.com/iden3/go-circom-prover-verifier/internal/curve"
+	"github.com/iden3/go-circom-prover-verifier/prover"
 	"github.com/iden3/go-circom-prover-verifier/types"
+	"github.com/iden3/go-circom-prover-verifier/verifier"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -212,6 +221,48 @@ func TestProofJSON(t *testing.T) {
 	require.Equal(t, *proof, proof1)
 }
 
+func TestVkJSON(t *testing.T) {
+	var vk types.Vk
+	var err error
+	_, vk.Alpha, err = bn256.RandomG1(rand.Reader)
+	require.Nil(t, err)
+	for _, p := range []**bn256.G2{&vk.Beta, &vk.Gamma, &vk.Delta} {
+		_, *p, err = bn256.RandomG2(rand.Reader)
+		require.Nil(t, err)
+	}
+	for i := 0; i < 3; i++ {
+		_, p, err := bn256.RandomG1(rand.Reader)
+		require.Nil(t, err)
+		vk.IC = append(vk.IC, p)
+	}
+	// the point at infinity
+	vk.IC = append(vk.IC, new(bn256.G1).ScalarBaseMult(big.NewInt(0)))
+	assert.Equal(t, []string{"0", "1", "0"}, VkToString(&vk).IC[3])
+
+	vkJson, err := VkToJson(&vk)
+	require.Nil(t, err)
+	vk1, err := ParseVk(vkJson)
+	require.Nil(t, err)
+	assert.Equal(t, vk.Alpha.Marshal(), vk1.Alpha.Marshal())
+	assert.Equal(t, vk.Beta.Marshal(), vk1.Beta.Marshal())
+	assert.Equal(t, vk.Gamma.Marshal(), vk1.Gamma.Marshal())
+	assert.Equal(t, vk.Delta.Marshal(), vk1.Delta.Marshal())
+	require.Equal(t, len(vk.IC), len(vk1.IC))
+	for i := range vk.IC {
+		assert.Equal(t, vk.IC[i].Marshal(), vk1.IC[i].Marshal())
+	}
+
+	// the current snarkjs names alpha vk_alpha_1
+	vkJson = bytes.Replace(vkJson, []byte(`"vk_alfa_1"`), []byte(`"vk_alpha_1"`), 1)
+	vk1, err = ParseVk(vkJson)
+	require.Nil(t, err)
+	assert.Equal(t, vk.Alpha.Marshal(), vk1.Alpha.Marshal())
+	var pkS PkString
+	require.Nil(t, json.Unmarshal([]byte(`{"vk_alpha_1": ["1", "2", "1"], "nVars": 3}`), &pkS))
+	assert.Equal(t, []string{"1", "2", "1"}, pkS.VkAlpha1)
+	assert.Equal(t, 3, pkS.NVars)
+}
+
 func testCircuitParsePkBin(t *testing.T, circuit string) {
 	pkBinFile, err := os.Open("../testdata/" + circuit + "/proving_key.bin")
 	require.Nil(t, err)
@@ -244,6 +295,95 @@ func testCircuitParsePkBin(t *testing.T, circuit string) {
 	assert.Equal(t, pkJ.DomainSize, pk.DomainSize)
 }
 
+// pkBinBytes builds a .bin file of the ProvingKey, following the layout of
+// the wasmsnark buildpkey tool
+func pkBinBytes(pk *types.Pk) []byte {
+	u32 := func(v int) []byte {
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], uint32(v))
+		return b[:]
+	}
+	pols := func(p types.Pols) []byte {
+		var b []byte
+		for i := 0; i < pk.NVars; i++ {
+			constraints, coefs := p.Wire(i)
+			b = append(b, u32(len(constraints))...)
+			for j, c := range constraints {
+				b = append(b, u32(int(c))...)
+				for _, l := range coefs[j] {
+					var lb [8]byte
+					binary.LittleEndian.PutUint64(lb[:], l)
+					b = append(b, lb[:]...)
+				}
+			}
+		}
+		return b
+	}
+	g1s := func(points []*bn256.G1) []byte {
+		var b []byte
+		for _, p := range points {
+			b = append(b, binfile.G1ToMont(p)...)
+		}
+		return b
+	}
+	var b2 []byte
+	for _, p := range pk.B2 {
+		b2 = append(b2, binfile.G2ToMont(p)...)
+	}
+	vk := g1s([]*bn256.G1{pk.VkAlpha1, pk.VkBeta1, pk.VkDelta1})
+	vk = append(vk, binfile.G2ToMont(pk.VkBeta2)...)
+	vk = append(vk, binfile.G2ToMont(pk.VkDelta2)...)
+	sections := [][]byte{pols(pk.PolsA), pols(pk.PolsB), g1s(pk.A), g1s(pk.B1), b2,
+		g1s(pk.C[pk.NPublic+1:]), g1s(pk.HExps)}
+
+	r := append(append(u32(pk.NVars), u32(pk.NPublic)...), u32(pk.DomainSize)...)
+	o := 12 + 4*len(sections) + len(vk)
+	for _, s := range sections {
+		r = append(r, u32(o)...)
+		o += len(s)
+	}
+	r = append(r, vk...)
+	for _, s := range sections {
+		r = append(r, s...)
+	}
+	return r
+}
+
+func TestPkBin(t *testing.T) {
+	pk, _ := randomPkVk(t)
+	// the generator (1, 2) and the point at infinity
+	pk.A[0] = new(bn256.G1).ScalarBaseMult(big.NewInt(1))
+	pk.A[1] = new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	pk.B2[1] = new(bn256.G2).ScalarBaseMult(big.NewInt(0))
+
+	f, err := ioutil.TempFile("", "proving_key.bin")
+	require.Nil(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	_, err = f.Write(pkBinBytes(pk))
+	require.Nil(t, err)
+	_, err = f.Seek(0, 0)
+	require.Nil(t, err)
+	pkB, err := ParsePkBin(f)
+	require.Nil(t, err)
+	assert.Equal(t, pk.PolsA, pkB.PolsA)
+	assert.Equal(t, pk.PolsB, pkB.PolsB)
+	for _, s := range []struct{ a, b []*bn256.G1 }{
+		{pk.A, pkB.A}, {pk.B1, pkB.B1}, {pk.C, pkB.C}, {pk.HExps, pkB.HExps},
+		{[]*bn256.G1{pk.VkAlpha1, pk.VkBeta1, pk.VkDelta1}, []*bn256.G1{pkB.VkAlpha1, pkB.VkBeta1, pkB.VkDelta1}},
+	} {
+		require.Equal(t, len(s.a), len(s.b))
+		for i := range s.a {
+			assert.Equal(t, s.a[i].Marshal(), s.b[i].Marshal())
+		}
+	}
+	for i := range pk.B2 {
+		assert.Equal(t, pk.B2[i].Marshal(), pkB.B2[i].Marshal())
+	}
+	assert.Equal(t, pk.VkBeta2.Marshal(), pkB.VkBeta2.Marshal())
+	assert.Equal(t, pk.VkDelta2.Marshal(), pkB.VkDelta2.Marshal())
+}
+
 func TestParsePkBin(t *testing.T) {
 	testCircuitParsePkBin(t, "circuit1k")
 	testCircuitParsePkBin(t, "circuit5k")
@@ -331,3 +471,398 @@ func BenchmarkParsePk(b *testing.B) {
 	// benchmarkParsePk(b, "circuit10k")
 	// benchmarkParsePk(b, "circuit20k")
 }
+
+func toMontLE(v, q *big.Int) []byte {
+	m := new(big.Int).Mod(new(big.Int).Lsh(v, 256), q)
+	return binfile.SwapEndianness(addPadding32(m.Bytes()))
+}
+
+func g1ToMontLE(p *bn256.G1) []byte {
+	b := p.Marshal()
+	var r []byte
+	r = append(r, toMontLE(new(big.Int).SetBytes(b[:32]), types.Q)...)
+	r = append(r, toMontLE(new(big.Int).SetBytes(b[32:64]), types.Q)...)
+	return r
+}
+
+func g2ToMontLE(p *bn256.G2) []byte {
+	b := p.Marshal()
+	var r []byte
+	r = append(r, toMontLE(new(big.Int).SetBytes(b[32:64]), types.Q)...)
+	r = append(r, toMontLE(new(big.Int).SetBytes(b[:32]), types.Q)...)
+	r = append(r, toMontLE(new(big.Int).SetBytes(b[96:128]), types.Q)...)
+	r = append(r, toMontLE(new(big.Int).SetBytes(b[64:96]), types.Q)...)
+	return r
+}
+
+// zkeyBytes builds a .zkey file from the given ProvingKey and
+// VerificationKey, following the snarkjs binary layout
+func zkeyBytes(pk *types.Pk, vk *types.Vk) []byte {
+	u32 := func(v int) []byte {
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], uint32(v))
+		return b[:]
+	}
+	var sections [][]byte
+	sections = append(sections, u32(1))
+
+	var s []byte
+	s = append(s, u32(32)...)
+	s = append(s, binfile.SwapEndianness(types.Q.Bytes())...)
+	s = append(s, u32(32)...)
+	s = append(s, binfile.SwapEndianness(types.R.Bytes())...)
+	s = append(s, u32(pk.NVars)...)
+	s = append(s, u32(pk.NPublic)...)
+	s = append(s, u32(pk.DomainSize)...)
+	s = append(s, g1ToMontLE(pk.VkAlpha1)...)
+	s = append(s, g1ToMontLE(pk.VkBeta1)...)
+	s = append(s, g2ToMontLE(pk.VkBeta2)...)
+	s = append(s, g2ToMontLE(vk.Gamma)...)
+	s = append(s, g1ToMontLE(pk.VkDelta1)...)
+	s = append(s, g2ToMontLE(pk.VkDelta2)...)
+	sections = append(sections, s)
+
+	s = nil
+	for _, p := range vk.IC {
+		s = append(s, g1ToMontLE(p)...)
+	}
+	sections = append(sections, s)
+
+	s = nil
+	nCoefs := 0
+	for m, pols := range []types.Pols{pk.PolsA, pk.PolsB} {
+		for i := 0; i < pk.NVars; i++ {
+			constraints, coefs := pols.Wire(i)
+			for j, c := range constraints {
+				v := coefs[j].ToBigIntRegular(new(big.Int))
+				s = append(s, u32(m)...)
+				s = append(s, u32(int(c))...)
+				s = append(s, u32(i)...)
+				s = append(s, toMontLE(new(big.Int).Lsh(v, 256), types.R)...)
+				nCoefs++
+			}
+		}
+	}
+	sections = append(sections, append(u32(nCoefs), s...))
+
+	for _, points := range [][]*bn256.G1{pk.A, pk.B1} {
+		s = nil
+		for _, p := range points {
+			s = append(s, g1ToMontLE(p)...)
+		}
+		sections = append(sections, s)
+	}
+	s = nil
+	for _, p := range pk.B2 {
+		s = append(s, g2ToMontLE(p)...)
+	}
+	sections = append(sections, s)
+	for _, points := range [][]*bn256.G1{pk.C[pk.NPublic+1:], pk.HExps} {
+		s = nil
+		for _, p := range points {
+			s = append(s, g1ToMontLE(p)...)
+		}
+		sections = append(sections, s)
+	}
+
+	var r []byte
+	r = append(r, []byte("zkey")...)
+	r = append(r, u32(1)...)
+	r = append(r, u32(len(sections))...)
+	for i, s := range sections {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], uint64(len(s)))
+		r = append(r, u32(i+1)...)
+		r = append(r, b[:]...)
+		r = append(r, s...)
+	}
+	return r
+}
+
+// randomPkVk returns a ProvingKey and VerificationKey with random points and
+// coefficients, with the HExps of the .zkey format
+func randomPkVk(t *testing.T) (*types.Pk, *types.Vk) {
+	randG1 := func() *bn256.G1 {
+		_, p, err := bn256.RandomG1(rand.Reader)
+		require.Nil(t, err)
+		return p
+	}
+	randG2 := func() *bn256.G2 {
+		_, p, err := bn256.RandomG2(rand.Reader)
+		require.Nil(t, err)
+		return p
+	}
+	pk := &types.Pk{NVars: 5, NPublic: 1, DomainSize: 8, HExpsCoset: true}
+	vk := &types.Vk{Gamma: randG2()}
+	pk.VkAlpha1, pk.VkBeta1, pk.VkDelta1 = randG1(), randG1(), randG1()
+	pk.VkBeta2, pk.VkDelta2 = randG2(), randG2()
+	vk.Alpha, vk.Beta, vk.Delta = pk.VkAlpha1, pk.VkBeta2, pk.VkDelta2
+	z := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	var polsA, polsB []map[int]*big.Int
+	for i := 0; i < pk.NVars; i++ {
+		pk.A = append(pk.A, randG1())
+		pk.B1 = append(pk.B1, randG1())
+		pk.B2 = append(pk.B2, randG2())
+		if i <= pk.NPublic {
+			pk.C = append(pk.C, z)
+			vk.IC = append(vk.IC, randG1())
+		} else {
+			pk.C = append(pk.C, randG1())
+		}
+		polsA = append(polsA, map[int]*big.Int{i: big.NewInt(1)})
+		v, err := rand.Int(rand.Reader, types.R)
+		require.Nil(t, err)
+		polsB = append(polsB, map[int]*big.Int{i + 1: v, 0: big.NewInt(5)})
+	}
+	pk.PolsA = types.PolsFromMaps(polsA)
+	pk.PolsB = types.PolsFromMaps(polsB)
+	for i := 0; i < pk.DomainSize; i++ {
+		pk.HExps = append(pk.HExps, randG1())
+	}
+	return pk, vk
+}
+
+func TestParseZkey(t *testing.T) {
+	pk, vk := randomPkVk(t)
+
+	zkeyFile, err := ioutil.TempFile("", "circuit.zkey")
+	require.Nil(t, err)
+	defer os.Remove(zkeyFile.Name())
+	defer zkeyFile.Close()
+	_, err = zkeyFile.Write(zkeyBytes(pk, vk))
+	require.Nil(t, err)
+
+	pkZ, vkZ, err := ParseZkey(zkeyFile)
+	require.Nil(t, err)
+	assert.Equal(t, pk, pkZ)
+	assert.Equal(t, vk, vkZ)
+
+	// the header, with the offsets of the points
+	pkH, vkH, points, err := ParseZkeyHeader(zkeyFile)
+	require.Nil(t, err)
+	assert.Equal(t, withoutPoints(pk), pkH)
+	assert.Equal(t, vk, vkH)
+	assert.Equal(t, types.PointsMontLE, points.Encoding)
+	for _, s := range []struct {
+		offset int64
+		p      *bn256.G1
+	}{{points.A, pk.A[0]}, {points.B1, pk.B1[0]}, {points.C, pk.C[pk.NPublic+1]}, {points.HExps, pk.HExps[0]}} {
+		b := make([]byte, 64)
+		_, err = zkeyFile.ReadAt(b, s.offset)
+		require.Nil(t, err)
+		var a curve.G1Affine
+		require.Nil(t, a.UnmarshalMontLE(b))
+		assert.Equal(t, curve.G1FromBn256(s.p), a)
+	}
+	b := make([]byte, 128)
+	_, err = zkeyFile.ReadAt(b, points.B2)
+	require.Nil(t, err)
+	var a2 curve.G2Affine
+	require.Nil(t, a2.UnmarshalMontLE(b))
+	assert.Equal(t, curve.G2FromBn256(pk.B2[0]), a2)
+
+	// invalid file type
+	_, err = zkeyFile.WriteAt([]byte("wtns"), 0)
+	require.Nil(t, err)
+	_, _, err = ParseZkey(zkeyFile)
+	assert.NotNil(t, err)
+}
+
+func TestCircuitParseZkey(t *testing.T) {
+	// the .zkey of circuit1k generated by snarkjs with a powers of tau
+	// ceremony and a phase 2 contribution
+	zkeyFile, err := os.Open("../testdata/circuit1k/circuit.zkey")
+	require.Nil(t, err)
+	defer zkeyFile.Close()
+	pk, vk, err := ParseZkey(zkeyFile)
+	require.Nil(t, err)
+
+	vkJson, err := ioutil.ReadFile("../testdata/circuit1k/verification_key.zkey.json")
+	require.Nil(t, err)
+	vkS, err := ParseVk(vkJson)
+	require.Nil(t, err)
+	assert.Equal(t, VkToString(vkS), VkToString(vk))
+
+	wtnsFile, err := os.Open("../testdata/circuit1k/witness.wtns")
+	require.Nil(t, err)
+	defer wtnsFile.Close()
+	w, err := ParseWtns(wtnsFile)
+	require.Nil(t, err)
+
+	proof, pubSignals, err := prover.GenerateProof(pk, w)
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vkS, proof, pubSignals))
+}
+
+func TestPkGoBinPols(t *testing.T) {
+	pk, _ := randomPkVk(t)
+	_, p, err := bn256.RandomG1(rand.Reader)
+	require.Nil(t, err)
+	pk.HExps = append(pk.HExps, p)
+	pk.HExpsCoset = false
+
+	pkGBin, err := PkToGoBin(pk)
+	require.Nil(t, err)
+	pkGoBinFile, err := ioutil.TempFile("", "proving_key.go.bin")
+	require.Nil(t, err)
+	defer os.Remove(pkGoBinFile.Name())
+	defer pkGoBinFile.Close()
+	_, err = pkGoBinFile.Write(pkGBin)
+	require.Nil(t, err)
+	_, err = pkGoBinFile.Seek(0, 0)
+	require.Nil(t, err)
+
+	pkG, err := ParsePkGoBin(pkGoBinFile)
+	require.Nil(t, err)
+	assert.Equal(t, pk.PolsA, pkG.PolsA)
+	assert.Equal(t, pk.PolsB, pkG.PolsB)
+	assert.Equal(t, pk.NVars, pkG.PolsA.NVars())
+	assert.Equal(t, pk.NVars, pkG.PolsB.NVars())
+	assert.Equal(t, 2*pk.NVars, pkG.PolsB.Len())
+	assert.Equal(t, pk.PolsB.Maps(), pkG.PolsB.Maps())
+
+	// the header, with the offsets of the points
+	pkH, points, err := ParsePkGoBinHeader(pkGoBinFile)
+	require.Nil(t, err)
+	assert.Equal(t, withoutPoints(pkG), pkH)
+	assert.Equal(t, types.PointsBE, points.Encoding)
+	for _, s := range []struct {
+		offset int64
+		p      []byte
+	}{{points.A, pk.A[0].Marshal()}, {points.B1, pk.B1[0].Marshal()}, {points.B2, pk.B2[0].Marshal()},
+		{points.C, pk.C[pk.NPublic+1].Marshal()}, {points.HExps, pk.HExps[0].Marshal()}} {
+		b := make([]byte, len(s.p))
+		_, err = pkGoBinFile.ReadAt(b, s.offset)
+		require.Nil(t, err)
+		assert.Equal(t, s.p, b)
+	}
+}
+
+func TestPkGoBinHExps(t *testing.T) {
+	parse := func(b []byte) (*types.Pk, error) {
+		f, err := ioutil.TempFile("", "proving_key.go.bin")
+		require.Nil(t, err)
+		defer os.Remove(f.Name())
+		defer f.Close()
+		_, err = f.Write(b)
+		require.Nil(t, err)
+		_, err = f.Seek(0, 0)
+		require.Nil(t, err)
+		return ParsePkGoBin(f)
+	}
+
+	// the keys of the .zkey files have DomainSize HExps over the coset
+	pk, _ := randomPkVk(t)
+	pkGBin, err := PkToGoBin(pk)
+	require.Nil(t, err)
+	pkG, err := parse(pkGBin)
+	require.Nil(t, err)
+	assert.True(t, pkG.HExpsCoset)
+	assert.Equal(t, pk.HExps, pkG.HExps)
+
+	// the files without the version have DomainSize+1 HExps over the
+	// domain, and no number of HExps and flags
+	_, p, err := bn256.RandomG1(rand.Reader)
+	require.Nil(t, err)
+	pk.HExps = append(pk.HExps, p)
+	pk.HExpsCoset = false
+	pkGBin, err = PkToGoBin(pk)
+	require.Nil(t, err)
+	old := append(pkGoBinV1(pkGBin)[8:496], pkGBin[532:]...)
+	for i := 12; i < 40; i += 4 {
+		binary.LittleEndian.PutUint32(old[i:], binary.LittleEndian.Uint32(old[i:])-16)
+	}
+	pkG, err = parse(old)
+	require.Nil(t, err)
+	assert.False(t, pkG.HExpsCoset)
+	assert.Equal(t, pk.HExps, pkG.HExps)
+	assert.Equal(t, pk.A, pkG.A)
+
+	// the version 1 files have uint32 offsets
+	pkG, err = parse(pkGoBinV1(pkGBin))
+	require.Nil(t, err)
+	assert.Equal(t, pk.HExps, pkG.HExps)
+	assert.Equal(t, pk.A, pkG.A)
+	assert.Equal(t, pk.PolsB, pkG.PolsB)
+
+	// an unknown version
+	binary.LittleEndian.PutUint32(pkGBin[4:], goBinVersion+1)
+	_, err = parse(pkGBin)
+	assert.NotNil(t, err)
+
+	pk.HExps = pk.HExps[:pk.DomainSize-1]
+	_, err = PkToGoBin(pk)
+	assert.NotNil(t, err)
+}
+
+// pkGoBinV1 converts the go.bin file b into the version 1 of the format,
+// with uint32 offsets
+func pkGoBinV1(b []byte) []byte {
+	v1 := append([]byte{}, b[:20]...)
+	binary.LittleEndian.PutUint32(v1[4:], 1)
+	var u [4]byte
+	for i := 20; i < 76; i += 8 {
+		binary.LittleEndian.PutUint32(u[:], uint32(binary.LittleEndian.Uint64(b[i:])-28))
+		v1 = append(v1, u[:]...)
+	}
+	return append(v1, b[76:]...)
+}
+
+// withoutPoints returns a copy of the proving key without the points of
+// the sections read by the streaming prover
+func withoutPoints(pk *types.Pk) *types.Pk {
+	h := *pk
+	h.A, h.B1, h.B2, h.C, h.HExps = nil, nil, nil, nil, nil
+	return &h
+}
+
+func TestWtns(t *testing.T) {
+	var w types.Witness
+	w = append(w, big.NewInt(1))
+	for i := 0; i < 100; i++ {
+		v, err := rand.Int(rand.Reader, types.R)
+		require.Nil(t, err)
+		w = append(w, v)
+	}
+	w = append(w, big.NewInt(0))
+	w = append(w, new(big.Int).Sub(types.R, big.NewInt(1)))
+
+	wtnsBytes, err := WtnsToBytes(w)
+	require.Nil(t, err)
+	wtnsFile, err := ioutil.TempFile("", "witness.wtns")
+	require.Nil(t, err)
+	defer os.Remove(wtnsFile.Name())
+	defer wtnsFile.Close()
+	_, err = wtnsFile.Write(wtnsBytes)
+	require.Nil(t, err)
+
+	wP, err := ParseWtns(wtnsFile)
+	require.Nil(t, err)
+	require.Equal(t, len(w), len(wP))
+	for i := range w {
+		assert.Equal(t, w[i].String(), wP[i].String())
+	}
+
+	// version 1 files
+	_, err = wtnsFile.WriteAt([]byte{1}, 4)
+	require.Nil(t, err)
+	wP, err = ParseWtns(wtnsFile)
+	require.Nil(t, err)
+	assert.Equal(t, len(w), len(wP))
+	_, err = wtnsFile.WriteAt([]byte{3}, 4)
+	require.Nil(t, err)
+	_, err = ParseWtns(wtnsFile)
+	assert.NotNil(t, err)
+	_, err = wtnsFile.WriteAt([]byte{2}, 4)
+	require.Nil(t, err)
+
+	// value outside the field
+	_, err = WtnsToBytes(types.Witness{types.R})
+	assert.NotNil(t, err)
+
+	// witness of a different field
+	_, err = wtnsFile.WriteAt([]byte{0xff}, 24)
+	require.Nil(t, err)
+	_, err = ParseWtns(wtnsFile)
+	assert.NotNil(t, err)
+}
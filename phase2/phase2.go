@@ -0,0 +1,357 @@
+// Package phase2 implements the circuit specific phase 2 of the trusted
+// setup over a proving key, with its own transcript of the contributions: a
+// sha512 chain over the records of this package, which is not compatible
+// with the contributions of the snarkjs .zkey files.
+package phase2
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-circom-prover-verifier/internal/fr"
+	"github.com/iden3/go-circom-prover-verifier/internal/mpc"
+	"github.com/iden3/go-circom-prover-verifier/types"
+	"github.com/iden3/go-iden3-crypto/ff"
+)
+
+// Contribution is the record of a contribution to the phase 2 of the trusted
+// setup, where the contributor multiplies delta by a secret d: DeltaAfter =
+// d * DeltaBefore.  The public key proves the knowledge of d: G1SX = d * G1S
+// and G2SPX = d * G2SP, where G2SP is derived from the transcript.
+type Contribution struct {
+	DeltaAfter *bn256.G1
+	G1S        *bn256.G1
+	G1SX       *bn256.G1
+	G2SPX      *bn256.G2
+	// Transcript is the challenge answered by the contribution, the hash of
+	// the initial key and of the previous contributions
+	Transcript []byte
+	Name       string
+}
+
+type contributionAux struct {
+	DeltaAfter string `json:"deltaAfter"`
+	G1S        string `json:"g1_s"`
+	G1SX       string `json:"g1_sx"`
+	G2SPX      string `json:"g2_spx"`
+	Transcript string `json:"transcript"`
+	Name       string `json:"name"`
+}
+
+func (c Contribution) MarshalJSON() ([]byte, error) {
+	var ca contributionAux
+	ca.DeltaAfter = hex.EncodeToString(c.DeltaAfter.Marshal())
+	ca.G1S = hex.EncodeToString(c.G1S.Marshal())
+	ca.G1SX = hex.EncodeToString(c.G1SX.Marshal())
+	ca.G2SPX = hex.EncodeToString(c.G2SPX.Marshal())
+	ca.Transcript = hex.EncodeToString(c.Transcript)
+	ca.Name = c.Name
+	return json.Marshal(ca)
+}
+
+func (c *Contribution) UnmarshalJSON(data []byte) error {
+	var ca contributionAux
+	if err := json.Unmarshal(data, &ca); err != nil {
+		return err
+	}
+	var err error
+	for _, g1 := range []struct {
+		s string
+		p **bn256.G1
+	}{{ca.DeltaAfter, &c.DeltaAfter}, {ca.G1S, &c.G1S}, {ca.G1SX, &c.G1SX}} {
+		b, err := hex.DecodeString(g1.s)
+		if err != nil {
+			return err
+		}
+		*g1.p = new(bn256.G1)
+		if _, err := (*g1.p).Unmarshal(b); err != nil {
+			return err
+		}
+	}
+	b, err := hex.DecodeString(ca.G2SPX)
+	if err != nil {
+		return err
+	}
+	c.G2SPX = new(bn256.G2)
+	if _, err := c.G2SPX.Unmarshal(b); err != nil {
+		return err
+	}
+	if c.Transcript, err = hex.DecodeString(ca.Transcript); err != nil {
+		return err
+	}
+	c.Name = ca.Name
+	return nil
+}
+
+// bytes returns the serialization of the contribution used for the
+// transcript
+func (c *Contribution) bytes() []byte {
+	var b []byte
+	b = append(b, c.DeltaAfter.Marshal()...)
+	b = append(b, c.G1S.Marshal()...)
+	b = append(b, c.G1SX.Marshal()...)
+	b = append(b, c.G2SPX.Marshal()...)
+	b = append(b, []byte(c.Name)...)
+	return b
+}
+
+// initialTranscript returns the challenge of the first contribution, the
+// sha512 hash of the parts of the initial key that are not modified by the
+// contributions, and of its delta.  The transcript is specific to this
+// package, snarkjs hashes the .zkey contributions differently.
+func initialTranscript(pk *types.Pk, vk *types.Vk) []byte {
+	h := sha512.New()
+	h.Write([]byte("phase2"))
+	var b [12]byte
+	binary.LittleEndian.PutUint32(b[:4], uint32(pk.NVars))
+	binary.LittleEndian.PutUint32(b[4:8], uint32(pk.NPublic))
+	binary.LittleEndian.PutUint32(b[8:12], uint32(pk.DomainSize))
+	h.Write(b[:])
+	h.Write(pk.VkAlpha1.Marshal())
+	h.Write(pk.VkBeta1.Marshal())
+	h.Write(pk.VkBeta2.Marshal())
+	h.Write(pk.VkDelta1.Marshal())
+	h.Write(pk.VkDelta2.Marshal())
+	h.Write(vk.Gamma.Marshal())
+	for _, p := range vk.IC {
+		h.Write(p.Marshal())
+	}
+	return h.Sum(nil)
+}
+
+// nextTranscript returns the challenge of the contribution following c
+func nextTranscript(c *Contribution) []byte {
+	h := sha512.New()
+	h.Write(c.Transcript)
+	h.Write(c.bytes())
+	return h.Sum(nil)
+}
+
+// hashToG2 derives the G2SP point from the transcript and the G1 points of
+// the public key, mapping their hash to G2 like snarkjs
+func hashToG2(transcript []byte, g1s, g1sx *bn256.G1) *bn256.G2 {
+	h := sha512.New()
+	h.Write(transcript)
+	h.Write(mpc.G1Uncompressed(g1s))
+	h.Write(mpc.G1Uncompressed(g1sx))
+	return mpc.HashToG2(h.Sum(nil))
+}
+
+// scaleG1 multiplies each point by k
+func scaleG1(points []*bn256.G1, k *big.Int) {
+	mpc.Parallel(len(points), func(start, end int) {
+		for i := start; i < end; i++ {
+			points[i] = new(bn256.G1).ScalarMult(points[i], k)
+		}
+	})
+}
+
+// Contribute adds a contribution to the phase 2 of the trusted setup of the
+// key, using a secret d sampled from rnd (crypto/rand.Reader when nil):
+// delta is multiplied by d, and C and HExps by 1/d.  The pk and vk are
+// updated in place, and the contributions are the previous contributions of
+// the ceremony.  The returned record must be appended to them.
+func Contribute(pk *types.Pk, vk *types.Vk, contributions []*Contribution, name string, rnd io.Reader) (*Contribution, error) {
+	if rnd == nil {
+		rnd = rand.Reader
+	}
+	if !bytes.Equal(vk.Delta.Marshal(), pk.VkDelta2.Marshal()) {
+		return nil, fmt.Errorf("Delta of the proving key and of the verification key do not match")
+	}
+	transcript := initialTranscript(pk, vk)
+	if len(contributions) > 0 {
+		last := contributions[len(contributions)-1]
+		if !bytes.Equal(last.DeltaAfter.Marshal(), pk.VkDelta1.Marshal()) {
+			return nil, fmt.Errorf("The last contribution does not match the key")
+		}
+		transcript = nextTranscript(last)
+	}
+
+	d, err := fr.RandNonZero(rnd)
+	if err != nil {
+		return nil, err
+	}
+	s, err := fr.RandNonZero(rnd)
+	if err != nil {
+		return nil, err
+	}
+	dBig := d.ToBigIntRegular(new(big.Int))
+	dInv := ff.NewElement().Inverse(d).ToBigIntRegular(new(big.Int))
+
+	c := Contribution{
+		Transcript: transcript,
+		Name:       name,
+	}
+	c.G1S = new(bn256.G1).ScalarBaseMult(s.ToBigIntRegular(new(big.Int)))
+	c.G1SX = new(bn256.G1).ScalarMult(c.G1S, dBig)
+	c.G2SPX = new(bn256.G2).ScalarMult(hashToG2(transcript, c.G1S, c.G1SX), dBig)
+
+	// the points are replaced (not modified) as they may be shared
+	pk.VkDelta1 = new(bn256.G1).ScalarMult(pk.VkDelta1, dBig)
+	pk.VkDelta2 = new(bn256.G2).ScalarMult(pk.VkDelta2, dBig)
+	vk.Delta = pk.VkDelta2
+	scaleG1(pk.C[pk.NPublic+1:], dInv)
+	scaleG1(pk.HExps, dInv)
+
+	c.DeltaAfter = new(bn256.G1).Set(pk.VkDelta1)
+	return &c, nil
+}
+
+func equalG1(a, b []*bn256.G1) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i].Marshal(), b[i].Marshal()) {
+			return false
+		}
+	}
+	return true
+}
+
+func equalPols(a, b types.Pols) bool {
+	if len(a.Offsets) != len(b.Offsets) || len(a.Constraints) != len(b.Constraints) || len(a.Coefs) != len(b.Coefs) {
+		return false
+	}
+	for i := range a.Offsets {
+		if a.Offsets[i] != b.Offsets[i] {
+			return false
+		}
+	}
+	for i := range a.Constraints {
+		if a.Constraints[i] != b.Constraints[i] || a.Coefs[i] != b.Coefs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func equalG2(a, b []*bn256.G2) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i].Marshal(), b[i].Marshal()) {
+			return false
+		}
+	}
+	return true
+}
+
+// randomCombinations returns sum(r_i * a[i]) and sum(r_i * b[i]) for random
+// 64 bit r_i
+func randomCombinations(a, b []*bn256.G1) (*bn256.G1, *bn256.G1, error) {
+	rb := make([]byte, 8*len(a))
+	if _, err := io.ReadFull(rand.Reader, rb); err != nil {
+		return nil, nil, err
+	}
+	var mu sync.Mutex
+	ra := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	rbp := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	mpc.Parallel(len(a), func(start, end int) {
+		pa := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+		pb := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+		for i := start; i < end; i++ {
+			r := new(big.Int).SetUint64(binary.LittleEndian.Uint64(rb[8*i:]))
+			pa.Add(pa, new(bn256.G1).ScalarMult(a[i], r))
+			pb.Add(pb, new(bn256.G1).ScalarMult(b[i], r))
+		}
+		mu.Lock()
+		ra.Add(ra, pa)
+		rbp.Add(rbp, pb)
+		mu.Unlock()
+	})
+	return ra, rbp, nil
+}
+
+// Verify verifies that the key pk, vk is the result of applying the chain of
+// contributions to the initial key initialPk, initialVk: the proofs of
+// knowledge of each contribution, that each one updates the delta of the
+// previous one, and that only delta, the private part of C and HExps were
+// modified, consistently with the final delta.  The contributions are the
+// records returned by Contribute, with the transcript of this package.
+func Verify(initialPk *types.Pk, initialVk *types.Vk, pk *types.Pk, vk *types.Vk, contributions []*Contribution) error {
+	if pk.NVars != initialPk.NVars || pk.NPublic != initialPk.NPublic ||
+		pk.DomainSize != initialPk.DomainSize || pk.HExpsCoset != initialPk.HExpsCoset {
+		return fmt.Errorf("The key sizes do not match the initial key")
+	}
+	if !equalG1([]*bn256.G1{pk.VkAlpha1, pk.VkBeta1, vk.Alpha}, []*bn256.G1{initialPk.VkAlpha1, initialPk.VkBeta1, initialVk.Alpha}) ||
+		!equalG2([]*bn256.G2{pk.VkBeta2, vk.Beta, vk.Gamma}, []*bn256.G2{initialPk.VkBeta2, initialVk.Beta, initialVk.Gamma}) {
+		return fmt.Errorf("Alpha, beta or gamma do not match the initial key")
+	}
+	if !equalG1(vk.IC, initialVk.IC) {
+		return fmt.Errorf("IC does not match the initial key")
+	}
+	if !equalG1(pk.A, initialPk.A) || !equalG1(pk.B1, initialPk.B1) || !equalG2(pk.B2, initialPk.B2) {
+		return fmt.Errorf("A or B do not match the initial key")
+	}
+	if !equalPols(pk.PolsA, initialPk.PolsA) || !equalPols(pk.PolsB, initialPk.PolsB) {
+		return fmt.Errorf("PolsA or PolsB do not match the initial key")
+	}
+	if len(pk.C) != len(initialPk.C) || len(pk.C) <= pk.NPublic || len(pk.HExps) != len(initialPk.HExps) {
+		return fmt.Errorf("C or HExps lengths do not match the initial key")
+	}
+	if !equalG1(pk.C[:pk.NPublic+1], initialPk.C[:pk.NPublic+1]) {
+		return fmt.Errorf("C of the public signals does not match the initial key")
+	}
+
+	// chain of contributions
+	transcript := initialTranscript(initialPk, initialVk)
+	delta := initialPk.VkDelta1
+	for i, c := range contributions {
+		if !bytes.Equal(c.Transcript, transcript) {
+			return fmt.Errorf("Contribution %v (%s): unexpected transcript", i+1, c.Name)
+		}
+		if bytes.Equal(c.G1S.Marshal(), new(bn256.G1).ScalarBaseMult(big.NewInt(0)).Marshal()) {
+			return fmt.Errorf("Contribution %v (%s): invalid public key", i+1, c.Name)
+		}
+		g2sp := hashToG2(transcript, c.G1S, c.G1SX)
+		if !mpc.SameRatio(c.G1S, c.G1SX, g2sp, c.G2SPX) {
+			return fmt.Errorf("Contribution %v (%s): invalid proof of knowledge", i+1, c.Name)
+		}
+		if !mpc.SameRatio(delta, c.DeltaAfter, g2sp, c.G2SPX) {
+			return fmt.Errorf("Contribution %v (%s): delta not updated by the contribution", i+1, c.Name)
+		}
+		delta = c.DeltaAfter
+		transcript = nextTranscript(c)
+	}
+
+	// final key
+	if !bytes.Equal(delta.Marshal(), pk.VkDelta1.Marshal()) {
+		return fmt.Errorf("Delta does not match the last contribution")
+	}
+	g1 := new(bn256.G1).ScalarBaseMult(big.NewInt(1))
+	g2 := new(bn256.G2).ScalarBaseMult(big.NewInt(1))
+	if !mpc.SameRatio(g1, pk.VkDelta1, g2, pk.VkDelta2) {
+		return fmt.Errorf("VkDelta1 and VkDelta2 do not match")
+	}
+	if !bytes.Equal(vk.Delta.Marshal(), pk.VkDelta2.Marshal()) {
+		return fmt.Errorf("Delta of the proving key and of the verification key do not match")
+	}
+
+	// C' = C * delta / delta', so e(C', delta') == e(C, delta)
+	c0, c1, err := randomCombinations(initialPk.C[pk.NPublic+1:], pk.C[pk.NPublic+1:])
+	if err != nil {
+		return err
+	}
+	if !mpc.SameRatio(c0, c1, pk.VkDelta2, initialPk.VkDelta2) {
+		return fmt.Errorf("C not updated by the contributions")
+	}
+	h0, h1, err := randomCombinations(initialPk.HExps, pk.HExps)
+	if err != nil {
+		return err
+	}
+	if !mpc.SameRatio(h0, h1, pk.VkDelta2, initialPk.VkDelta2) {
+		return fmt.Errorf("HExps not updated by the contributions")
+	}
+	return nil
+}
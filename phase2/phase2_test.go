@@ -0,0 +1,115 @@
+package phase2
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-circom-prover-verifier/internal/testutil"
+	"github.com/iden3/go-circom-prover-verifier/prover"
+	"github.com/iden3/go-circom-prover-verifier/setup"
+	"github.com/iden3/go-circom-prover-verifier/types"
+	"github.com/iden3/go-circom-prover-verifier/verifier"
+	"github.com/iden3/go-iden3-crypto/ff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testKey returns the keys of the circuit of testutil.Circuit with 4
+// constraints, and its witness for in = 3
+func testKey(t *testing.T) (*types.Pk, *types.Vk, types.Witness) {
+	cs, w := testutil.Circuit(3, 3)
+	pk, vk, err := setup.Setup(cs, rand.Reader)
+	require.Nil(t, err)
+	return pk, vk, w
+}
+
+// cloneKey returns a copy of the key that is not modified by Contribute
+func cloneKey(pk *types.Pk, vk *types.Vk) (*types.Pk, *types.Vk) {
+	pk2 := *pk
+	pk2.C = append([]*bn256.G1{}, pk.C...)
+	pk2.HExps = append([]*bn256.G1{}, pk.HExps...)
+	vk2 := *vk
+	return &pk2, &vk2
+}
+
+func TestPhase2(t *testing.T) {
+	initialPk, initialVk, w := testKey(t)
+	pk, vk := cloneKey(initialPk, initialVk)
+
+	var contributions []*Contribution
+	require.Nil(t, Verify(initialPk, initialVk, pk, vk, contributions))
+	for _, name := range []string{"first", "second", "third"} {
+		c, err := Contribute(pk, vk, contributions, name, nil)
+		require.Nil(t, err)
+		contributions = append(contributions, c)
+		require.Nil(t, Verify(initialPk, initialVk, pk, vk, contributions))
+	}
+	assert.NotEqual(t, initialPk.VkDelta1.Marshal(), pk.VkDelta1.Marshal())
+
+	// the contributed key generates valid proofs
+	proof, pubSignals, err := prover.GenerateProof(pk, w)
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+	assert.False(t, verifier.Verify(initialVk, proof, pubSignals))
+
+	// json round trip of the contributions
+	b, err := json.Marshal(contributions)
+	require.Nil(t, err)
+	var contributions2 []*Contribution
+	require.Nil(t, json.Unmarshal(b, &contributions2))
+	require.Nil(t, Verify(initialPk, initialVk, pk, vk, contributions2))
+
+	two := big.NewInt(2)
+
+	// missing contribution
+	assert.NotNil(t, Verify(initialPk, initialVk, pk, vk, contributions[1:]))
+	assert.NotNil(t, Verify(initialPk, initialVk, pk, vk, contributions[:2]))
+
+	// invalid proof of knowledge
+	c := *contributions[1]
+	c.G1SX = new(bn256.G1).ScalarMult(c.G1SX, two)
+	assert.NotNil(t, Verify(initialPk, initialVk, pk, vk, []*Contribution{contributions[0], &c, contributions[2]}))
+
+	// C or HExps not updated consistently
+	pk2, vk2 := cloneKey(pk, vk)
+	pk2.C[4] = new(bn256.G1).ScalarMult(pk2.C[4], two)
+	assert.NotNil(t, Verify(initialPk, initialVk, pk2, vk2, contributions))
+	pk2, vk2 = cloneKey(pk, vk)
+	pk2.HExps[0] = new(bn256.G1).ScalarMult(pk2.HExps[0], two)
+	assert.NotNil(t, Verify(initialPk, initialVk, pk2, vk2, contributions))
+
+	// a modified part of the key that is not updated by the contributions
+	pk2, vk2 = cloneKey(pk, vk)
+	vk2.IC = append([]*bn256.G1{}, vk.IC...)
+	vk2.IC[1] = new(bn256.G1).ScalarMult(vk2.IC[1], two)
+	assert.NotNil(t, Verify(initialPk, initialVk, pk2, vk2, contributions))
+	pk2, vk2 = cloneKey(pk, vk)
+	pk2.C[pk.NPublic] = new(bn256.G1).ScalarBaseMult(two)
+	assert.NotNil(t, Verify(initialPk, initialVk, pk2, vk2, contributions))
+
+	// modified polynomials
+	for _, tamper := range []func(pk *types.Pk){
+		func(pk *types.Pk) { pk.PolsA.Offsets[1]++ },
+		func(pk *types.Pk) { pk.PolsA.Constraints[0]++ },
+		func(pk *types.Pk) { pk.PolsB.Coefs[0].SetUint64(5) },
+		func(pk *types.Pk) { pk.PolsB.Coefs = pk.PolsB.Coefs[:len(pk.PolsB.Coefs)-1] },
+	} {
+		pk2, vk2 = cloneKey(pk, vk)
+		pk2.PolsA = clonePols(pk.PolsA)
+		pk2.PolsB = clonePols(pk.PolsB)
+		require.Nil(t, Verify(initialPk, initialVk, pk2, vk2, contributions))
+		tamper(pk2)
+		assert.NotNil(t, Verify(initialPk, initialVk, pk2, vk2, contributions))
+	}
+}
+
+func clonePols(p types.Pols) types.Pols {
+	return types.Pols{
+		Offsets:     append([]int{}, p.Offsets...),
+		Constraints: append([]uint32{}, p.Constraints...),
+		Coefs:       append([]ff.Element{}, p.Coefs...),
+	}
+}
@@ -0,0 +1,81 @@
+package prover
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/iden3/go-circom-prover-verifier/types"
+)
+
+// maxBatchSize is the largest number of witnesses whose proofs are generated
+// together
+const maxBatchSize = 16
+
+// BatchResult is the result of the proof of a witness of a batch
+type BatchResult struct {
+	Proof      *types.Proof
+	PubSignals []*big.Int
+	Err        error
+}
+
+// batchSize returns the number of witnesses whose proofs are generated
+// together, so that their buffers in the Workspace fit in half of the
+// memory of the Tuning: the witness in Montgomery and regular form and split
+// for G1 and G2, the evaluations of A, B and C, and the split scalars of H
+func batchSize(pk *types.Pk, t *Tuning) int {
+	perWitness := int64(32 * (8*pk.NVars + 5*pk.DomainSize))
+	n := t.memory() / 2 / perWitness
+	if n < 1 {
+		return 1
+	}
+	if n > maxBatchSize {
+		return maxBatchSize
+	}
+	return int(n)
+}
+
+// generateProofBatches generates the proofs of the witnesses in batches of
+// batchSize
+func generateProofBatches(pr *proofRun, pk *types.Pk, ws []types.Witness, me multiExp) []BatchResult {
+	res := make([]BatchResult, len(ws))
+	if len(ws) > 1 && (pr.opts.R != nil || pr.opts.S != nil) {
+		// the same blinding factors in several proofs would reveal the
+		// differences of the witnesses
+		for i := range res {
+			res[i].Err = fmt.Errorf("R and S can not be given for a batch of proofs")
+		}
+		return res
+	}
+	n := batchSize(pk, pr.tuning())
+	if pr.opts.Workspace == nil && len(ws) > n {
+		// the batches share the buffers
+		pr.opts.Workspace = NewWorkspace(pk.DomainSize)
+	}
+	for start := 0; start < len(ws); start += n {
+		end := start + n
+		if end > len(ws) {
+			end = len(ws)
+		}
+		copy(res[start:end], generateProofs(pr, pk, ws[start:end], me))
+	}
+	return res
+}
+
+// GenerateProofs generates the Groth16 zkSNARK proofs of the witnesses for
+// the same proving key, with the given options.  The MSMs of several
+// witnesses are computed together over each range of the points, and the
+// proofs are returned in the order of the witnesses, with an error for each
+// witness.  The blinding factors are read from the Rand of the options in
+// the order of the witnesses, also for the witnesses with errors, and R and
+// S can only be given for a single witness.
+func GenerateProofs(ctx context.Context, pk *types.Pk, ws []types.Witness, opts Options) []BatchResult {
+	pr := newProofRun(ctx, opts)
+	return generateProofBatches(pr, pk, ws, newPippengerMultiExp(pr, pk))
+}
+
+// GenerateProofs generates the Groth16 zkSNARK proofs of the witnesses using
+// the tables of the Prover, like GenerateProofs
+func (p *Prover) GenerateProofs(ctx context.Context, ws []types.Witness, opts Options) []BatchResult {
+	return generateProofBatches(newProofRun(ctx, opts), p.pk, ws, p)
+}
@@ -0,0 +1,87 @@
+package prover
+
+import (
+	"context"
+	"math/big"
+	mrand "math/rand"
+	"testing"
+
+	"github.com/iden3/go-circom-prover-verifier/internal/testutil"
+	"github.com/iden3/go-circom-prover-verifier/types"
+	"github.com/iden3/go-circom-prover-verifier/verifier"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testWitness returns the witness for x of the circuit of testCircuit with n
+// constraints
+func testWitness(n int, x int64) types.Witness {
+	_, w := testutil.Circuit(n, x)
+	return w
+}
+
+func TestGenerateProofs(t *testing.T) {
+	pk, vk, _ := testCircuit(t, 50, 3)
+	ws := []types.Witness{testWitness(50, 3), testWitness(50, 5), testWitness(50, 3)[:10], testWitness(50, 7)}
+
+	small := *DefaultTuning
+	small.Memory = 1
+	p := NewProver(pk)
+	for _, gen := range []func(opts Options) []BatchResult{
+		func(opts Options) []BatchResult { return GenerateProofs(context.Background(), pk, ws, opts) },
+		func(opts Options) []BatchResult { return p.GenerateProofs(context.Background(), ws, opts) },
+	} {
+		// in batches of one witness with the small memory
+		for _, opts := range []Options{{}, {Workers: 1}, {Tuning: &small}} {
+			res := gen(opts)
+			require.Equal(t, len(ws), len(res))
+			for i, r := range res {
+				if i == 2 {
+					assert.NotNil(t, r.Err)
+					continue
+				}
+				require.Nil(t, r.Err)
+				assert.Equal(t, []*big.Int(ws[i][1:3]), r.PubSignals)
+				assert.True(t, verifier.Verify(vk, r.Proof, r.PubSignals))
+			}
+		}
+
+		// the same proofs as one by one, with the blinding factors read in
+		// the order of the witnesses
+		res := gen(Options{Rand: mrand.New(mrand.NewSource(1))})
+		rnd := mrand.New(mrand.NewSource(1))
+		for i, w := range ws {
+			proof, _, err := GenerateProofWithOptions(context.Background(), pk, w, Options{Rand: rnd})
+			if i == 2 {
+				assert.NotNil(t, err)
+				continue
+			}
+			require.Nil(t, err)
+			assert.Equal(t, proof, res[i].Proof)
+		}
+
+		// the blinding factors of the invalid witness are read too, so the
+		// proofs of the following witnesses do not depend on it
+		valid := append([]types.Witness{}, ws...)
+		valid[2] = testWitness(50, 3)
+		res2 := GenerateProofs(context.Background(), pk, valid, Options{Rand: mrand.New(mrand.NewSource(1))})
+		require.Nil(t, res2[3].Err)
+		assert.Equal(t, res2[3].Proof, res[3].Proof)
+
+		// the blinding factors can not be given for several proofs
+		res = gen(Options{R: big.NewInt(1), S: big.NewInt(2)})
+		for _, r := range res {
+			assert.NotNil(t, r.Err)
+		}
+
+		// cancelled
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		res = GenerateProofs(ctx, pk, ws, Options{})
+		for _, r := range res {
+			assert.NotNil(t, r.Err)
+		}
+	}
+
+	assert.Equal(t, 0, len(GenerateProofs(context.Background(), pk, nil, Options{})))
+}
@@ -1,109 +1,177 @@
 package prover
 
 import (
-	"math"
-	"math/big"
+	"math/bits"
+	"sync"
 
-	"github.com/iden3/go-circom-prover-verifier/types"
+	"github.com/iden3/go-circom-prover-verifier/internal/fr"
 	"github.com/iden3/go-iden3-crypto/ff"
 )
 
-type rootsT struct {
-	roots [][]*ff.Element
-	w     []*ff.Element
+// minParallelFFT is the minimum number of butterflies of an FFT stage to be
+// split across goroutines
+const minParallelFFT = 1 << 10
+
+// domain contains the precomputed values of the FFT over the 2^bits roots of
+// unity
+type domain struct {
+	bits int
+	m    int
+	// w is the primitive m-th root of unity used for the domain
+	w *ff.Element
+	// roots are w^i and rootsInv are w^-i, for i in [0, m/2)
+	roots    []ff.Element
+	rootsInv []ff.Element
+	// mInv is 1/m
+	mInv *ff.Element
+	// cosetShift is the primitive 2m-th root of unity g, which shifts the
+	// domain to its odd coset g·w^i, where the vanishing polynomial of the
+	// domain is the constant g^m - 1 = -2.  cosetPowers are g^i and
+	// cosetPowersInv are g^-i, for i in [0, m)
+	cosetShift     *ff.Element
+	cosetPowers    []ff.Element
+	cosetPowersInv []ff.Element
 }
 
-func newRootsT() rootsT {
-	var roots rootsT
+var (
+	domains   = make(map[int]*domain)
+	domainsMu sync.Mutex
+)
 
-	rem := new(big.Int).Sub(types.R, big.NewInt(1))
-	s := 0
-	for rem.Bit(0) == 0 { // rem.Bit==0 when even
-		s++
-		rem = new(big.Int).Rsh(rem, 1)
+// getDomain returns the domain of size 2^bits, which is computed once and
+// shared by all the proofs of the process
+func getDomain(bits int) *domain {
+	domainsMu.Lock()
+	defer domainsMu.Unlock()
+	if d, ok := domains[bits]; ok {
+		return d
 	}
-	roots.w = make([]*ff.Element, s+1)
-	roots.w[s] = ff.NewElement().SetBigInt(fExp(big.NewInt(5), rem))
+	d := newDomain(bits)
+	domains[bits] = d
+	return d
+}
 
-	n := s - 1
-	for n >= 0 {
-		roots.w[n] = ff.NewElement().Mul(roots.w[n+1], roots.w[n+1])
-		n--
+func newDomain(bits int) *domain {
+	m := 1 << uint(bits)
+	d := &domain{
+		bits: bits,
+		m:    m,
+		w:    fr.RootOfUnity(bits),
 	}
-	roots.roots = make([][]*ff.Element, 50) // TODO WIP
-
-	roots.setRoots(15)
-	return roots
+	half := m / 2
+	if half == 0 {
+		half = 1
+	}
+	d.roots = make([]ff.Element, half)
+	d.rootsInv = make([]ff.Element, half)
+	wInv := ff.NewElement().Inverse(d.w)
+	d.roots[0].SetOne()
+	d.rootsInv[0].SetOne()
+	for i := 1; i < half; i++ {
+		d.roots[i].Mul(&d.roots[i-1], d.w)
+		d.rootsInv[i].Mul(&d.rootsInv[i-1], wInv)
+	}
+	d.mInv = ff.NewElement().Inverse(ff.NewElement().SetUint64(uint64(m)))
+
+	d.cosetShift = fr.RootOfUnity(bits + 1)
+	gInv := ff.NewElement().Inverse(d.cosetShift)
+	d.cosetPowers = make([]ff.Element, m)
+	d.cosetPowersInv = make([]ff.Element, m)
+	d.cosetPowers[0].SetOne()
+	d.cosetPowersInv[0].SetOne()
+	for i := 1; i < m; i++ {
+		d.cosetPowers[i].Mul(&d.cosetPowers[i-1], d.cosetShift)
+		d.cosetPowersInv[i].Mul(&d.cosetPowersInv[i-1], gInv)
+	}
+	return d
 }
 
-func (roots rootsT) setRoots(n int) {
-	for i := n; i >= 0 && nil == roots.roots[i]; i-- { // TODO tmp i<=len(r)
-		r := ff.NewElement().SetBigInt(big.NewInt(1))
-		nroots := 1 << i
-		var rootsi []*ff.Element
-		for j := 0; j < nroots; j++ {
-			rootsi = append(rootsi, r)
-			r = ff.NewElement().Mul(r, roots.w[i])
+// bitReverse permutes the elements of a, of size 2^bits, to the bit reversed
+// order of their indexes
+func bitReverse(a []ff.Element, nbits int) {
+	shift := uint(bits.UintSize - nbits)
+	for i := range a {
+		j := int(bits.Reverse(uint(i)) >> shift)
+		if i < j {
+			a[i], a[j] = a[j], a[i]
 		}
-		roots.roots[i] = rootsi
 	}
 }
 
-func fftroots(roots rootsT, pall []*ff.Element, bits, offset, step int) []*ff.Element {
-	n := 1 << bits
-	if n == 1 {
-		return []*ff.Element{pall[offset]}
-	} else if n == 2 {
-		return []*ff.Element{
-			ff.NewElement().Add(pall[offset], pall[offset+step]), // TODO tmp
-			ff.NewElement().Sub(pall[offset], pall[offset+step]),
+// ntt computes in place the iterative radix-2 FFT of a with the given
+// twiddles (the powers of the root of unity).  The context is checked before
+// each stage.
+func (d *domain) ntt(pr *proofRun, a []ff.Element, twiddles []ff.Element) error {
+	if d.m == 1 {
+		return nil
+	}
+	bitReverse(a, d.bits)
+	for half := 1; half < d.m; half <<= 1 {
+		if err := pr.ctx.Err(); err != nil {
+			return err
 		}
+		stride := d.m / (2 * half)
+		// the butterflies of the stage are indexed by b in [0, m/2)
+		pr.runMin(d.m/2, minParallelFFT, func(start, end int) {
+			var t ff.Element
+			for b := start; b < end; b++ {
+				j := b % half
+				k := (b/half)*2*half + j
+				t.Mul(&a[k+half], &twiddles[j*stride])
+				a[k+half].Sub(&a[k], &t)
+				a[k].Add(&a[k], &t)
+			}
+		})
 	}
+	return nil
+}
 
-	ndiv2 := n >> 1
-	p1 := fftroots(roots, pall, bits-1, offset, step*2)
-	p2 := fftroots(roots, pall, bits-1, offset+step, step*2)
-
-	out := make([]*ff.Element, n)
-	for i := 0; i < ndiv2; i++ {
-		out[i] = ff.NewElement().Add(p1[i], ff.NewElement().Mul(roots.roots[bits][i], p2[i]))
-		out[i+ndiv2] = ff.NewElement().Sub(p1[i], ff.NewElement().Mul(roots.roots[bits][i], p2[i]))
-	}
-	return out
+// fft replaces the coefficients of the polynomial a, of the size of the
+// domain, by its evaluations over the domain
+func (d *domain) fft(pr *proofRun, a []ff.Element) error {
+	return d.ntt(pr, a, d.roots)
 }
 
-func fft(p []*ff.Element) []*ff.Element {
-	if len(p) <= 1 {
-		return p
+// ifft replaces the evaluations over the domain a by the coefficients of the
+// polynomial
+func (d *domain) ifft(pr *proofRun, a []ff.Element) error {
+	if err := d.ntt(pr, a, d.rootsInv); err != nil {
+		return err
 	}
-	bits := math.Log2(float64(len(p)-1)) + 1
-	roots := newRootsT()
-	roots.setRoots(int(bits))
-	m := 1 << int(bits)
-	ep := extend(p, m)
-	res := fftroots(roots, ep, int(bits), 0, 1)
-	return res
+	pr.runMin(d.m, minParallelFFT, func(start, end int) {
+		for i := start; i < end; i++ {
+			a[i].Mul(&a[i], d.mInv)
+		}
+	})
+	return nil
 }
 
-func ifft(p []*ff.Element) []*ff.Element {
-	res := fft(p)
-	bits := math.Log2(float64(len(p)-1)) + 1
-	m := 1 << int(bits)
-
-	twoinvm := ff.NewElement().SetBigInt(fInv(fMul(big.NewInt(1), big.NewInt(int64(m)))))
+// cosetFFT replaces the coefficients of the polynomial a by its evaluations
+// over the coset of the domain
+func (d *domain) cosetFFT(pr *proofRun, a []ff.Element) error {
+	pr.runMin(d.m, minParallelFFT, func(start, end int) {
+		for i := start; i < end; i++ {
+			a[i].Mul(&a[i], &d.cosetPowers[i])
+		}
+	})
+	return d.fft(pr, a)
+}
 
-	var resn []*ff.Element
-	for i := 0; i < m; i++ {
-		resn = append(resn, ff.NewElement().Mul(res[(m-i)%m], twoinvm))
+// cosetIFFT replaces the evaluations over the coset of the domain a by the
+// coefficients of the polynomial
+func (d *domain) cosetIFFT(pr *proofRun, a []ff.Element) error {
+	if err := d.ifft(pr, a); err != nil {
+		return err
 	}
-
-	return resn
+	pr.runMin(d.m, minParallelFFT, func(start, end int) {
+		for i := start; i < end; i++ {
+			a[i].Mul(&a[i], &d.cosetPowersInv[i])
+		}
+	})
+	return nil
 }
 
-func extend(p []*ff.Element, e int) []*ff.Element {
-	if e == len(p) {
-		return p
-	}
-	z := arrayOfZeroesE(e - len(p))
-	return append(p, z...)
+// log2 returns the bits of the domain of size n, which must be a power of two
+func log2(n int) int {
+	return bits.Len(uint(n)) - 1
 }
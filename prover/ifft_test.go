@@ -0,0 +1,79 @@
+package prover
+
+import (
+	"context"
+	"testing"
+
+	"github.com/iden3/go-iden3-crypto/ff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// evalPol evaluates the polynomial with coefficients p at x
+func evalPol(p []ff.Element, x *ff.Element) *ff.Element {
+	r := ff.NewElement()
+	for i := len(p) - 1; i >= 0; i-- {
+		r.Mul(r, x)
+		r.Add(r, &p[i])
+	}
+	return r
+}
+
+// copyElements returns a copy of the elements of a
+func copyElements(a []ff.Element) []ff.Element {
+	return append([]ff.Element(nil), a...)
+}
+
+func TestFFT(t *testing.T) {
+	for _, bits := range []int{0, 1, 2, 5, 12} {
+		m := 1 << bits
+		pr := newProofRun(context.Background(), Options{})
+		p := make([]ff.Element, m)
+		elementsFromBigInts(pr, p, randomBigIntArray(m))
+		d := getDomain(bits)
+		assert.True(t, d == getDomain(bits))
+
+		e := copyElements(p)
+		require.Nil(t, d.fft(pr, e))
+		// check some of the evaluations
+		wi := ff.NewElement().SetOne()
+		for i := 0; i < m && i < 8; i++ {
+			assert.Equal(t, evalPol(p, wi).String(), e[i].String())
+			wi.Mul(wi, d.w)
+		}
+		if m > 8 {
+			x := ff.NewElement().Exp(*d.w, uint64(m-1))
+			assert.Equal(t, evalPol(p, x).String(), e[m-1].String())
+		}
+
+		require.Nil(t, d.ifft(pr, e))
+		require.Equal(t, len(p), len(e))
+		for i := range p {
+			assert.Equal(t, p[i].String(), e[i].String())
+		}
+
+		// coset evaluations at g·w^i
+		require.Nil(t, d.cosetFFT(pr, e))
+		x := ff.NewElement().Set(d.cosetShift)
+		for i := 0; i < m && i < 8; i++ {
+			assert.Equal(t, evalPol(p, x).String(), e[i].String())
+			x.Mul(x, d.w)
+		}
+		require.Nil(t, d.cosetIFFT(pr, e))
+		for i := range p {
+			assert.Equal(t, p[i].String(), e[i].String())
+		}
+	}
+}
+
+func BenchmarkFFT(b *testing.B) {
+	bits := 16
+	pr := newProofRun(context.Background(), Options{})
+	p := make([]ff.Element, 1<<bits)
+	elementsFromBigInts(pr, p, randomBigIntArray(1<<bits))
+	d := getDomain(bits)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		d.fft(pr, p)
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package prover
+
+// availableMemory returns 0, as the available memory of the system is
+// unknown
+func availableMemory() int64 {
+	return 0
+}
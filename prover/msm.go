@@ -0,0 +1,455 @@
+package prover
+
+import (
+	"math/bits"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-circom-prover-verifier/internal/curve"
+	"github.com/iden3/go-iden3-crypto/ff"
+)
+
+// getWindow returns the c bits of the scalar starting at the bit start
+func getWindow(k *ff.Element, start, c int) uint {
+	w := start / 64
+	if w >= len(k) {
+		return 0
+	}
+	o := uint(start % 64)
+	v := k[w] >> o
+	if o+uint(c) > 64 && w+1 < len(k) {
+		v |= k[w+1] << (64 - o)
+	}
+	return uint(v & (1<<uint(c) - 1))
+}
+
+// scalarBitLen returns the bit length of the scalar k
+func scalarBitLen(k *ff.Element) int {
+	for i := len(k) - 1; i >= 0; i-- {
+		if k[i] != 0 {
+			return 64*i + bits.Len64(k[i])
+		}
+	}
+	return 0
+}
+
+// scalars are the scalars of the multi-scalar multiplications, as field
+// elements in regular form, and when they are used, split for the
+// multiplications with the endomorphisms of the groups (GLV method)
+type scalars struct {
+	k []ff.Element
+	// g1 are the scalars split for G1: k[i] = g1[0][i] + g1[1][i]·λ
+	g1 [2][]ff.Element
+	// g2 are the scalars split for G2: k[i] = sum(g2[j][i]·μ^j)
+	g2 [4][]ff.Element
+}
+
+// split sets s to the scalars k, split in parallel for G1 and G2 when
+// requested, reusing the memory of the split scalars of s
+func (s *scalars) split(pr *proofRun, k []ff.Element, g1, g2 bool) *scalars {
+	s.k = k
+	for j := range s.g1 {
+		if g1 {
+			s.g1[j] = growElements(s.g1[j], len(k))
+		} else {
+			s.g1[j] = nil
+		}
+	}
+	for j := range s.g2 {
+		if g2 {
+			s.g2[j] = growElements(s.g2[j], len(k))
+		} else {
+			s.g2[j] = nil
+		}
+	}
+	if !g1 && !g2 {
+		return s
+	}
+	pr.run(len(k), func(start, end int) {
+		for i := start; i < end; i++ {
+			ki := (*[4]uint64)(&k[i])
+			if g1 {
+				k1, k2 := curve.SplitG1(ki)
+				s.g1[0][i], s.g1[1][i] = k1, k2
+			}
+			if g2 {
+				parts := curve.SplitG2(ki)
+				for j := range parts {
+					s.g2[j][i] = parts[j]
+				}
+			}
+		}
+	})
+	return s
+}
+
+// slice returns the scalars [start, end)
+func (s *scalars) slice(start, end int) scalars {
+	r := scalars{k: s.k[start:end]}
+	for j := range s.g1 {
+		if s.g1[j] != nil {
+			r.g1[j] = s.g1[j][start:end]
+		}
+	}
+	for j := range s.g2 {
+		if s.g2[j] != nil {
+			r.g2[j] = s.g2[j][start:end]
+		}
+	}
+	return r
+}
+
+// msmScalars returns the indexes of the scalars different from zero and
+// one, the indexes of the scalars one, and the maximum bit length of the
+// scalars.  The scalars are field elements in regular form.  The indexes
+// are appended to idx and ones.
+func msmScalars(k []ff.Element, idx, ones []int) ([]int, []int, int) {
+	nbits := 0
+	for i := range k {
+		l := scalarBitLen(&k[i])
+		if l == 0 {
+			continue
+		}
+		if l == 1 {
+			ones = append(ones, i)
+			continue
+		}
+		idx = append(idx, i)
+		if l > nbits {
+			nbits = l
+		}
+	}
+	return idx, ones, nbits
+}
+
+// minBatchAffine is the minimum number of additions of a batch of affine
+// additions: with smaller batches, the shared inversion costs more than the
+// Jacobian additions that it saves
+const minBatchAffine = 64
+
+// batchAffineSize returns the number of additions of the batches of affine
+// additions to nbuckets buckets, or 0 when there are too few buckets.  The
+// batches are a small fraction of the buckets, so that few points go to a
+// bucket that already has an addition in the batch.
+func batchAffineSize(nbuckets int) int {
+	b := nbuckets / 4
+	if b > 1024 {
+		b = 1024
+	}
+	if b < minBatchAffine {
+		return 0
+	}
+	return b
+}
+
+// bucketsG1 are the buckets of a window of the Pippenger method.  The
+// points are accumulated in the affine buckets with batches of affine
+// additions (BatchAddG1), which share an inversion.  The points of a bucket
+// that already has an addition in the batch are added to the Jacobian part
+// of the bucket instead.
+type bucketsG1 struct {
+	affine []curve.G1Affine
+	jac    []curve.G1Jac
+	// busy[b] is true when the bucket b has an addition in the batch
+	busy []bool
+	// the batch of up to batch additions: the points q[i] are added to the
+	// buckets idx[i], p[i]
+	batch int
+	idx   []int
+	p, q  []*curve.G1Affine
+	prod  []curve.Fp
+}
+
+func newBucketsG1(n, batch int) *bucketsG1 {
+	bk := new(bucketsG1)
+	bk.resize(n, batch)
+	return bk
+}
+
+// resize sets the number of buckets and the number of additions of the
+// batches, reusing the memory of the buckets when it is large enough.  The
+// buckets must be reset before they are used.
+func (bk *bucketsG1) resize(n, batch int) {
+	if cap(bk.affine) < n {
+		bk.affine = make([]curve.G1Affine, n)
+		bk.jac = make([]curve.G1Jac, n)
+		bk.busy = make([]bool, n)
+	}
+	bk.affine, bk.jac, bk.busy = bk.affine[:n], bk.jac[:n], bk.busy[:n]
+	if cap(bk.prod) < batch {
+		bk.idx = make([]int, 0, batch)
+		bk.p = make([]*curve.G1Affine, 0, batch)
+		bk.q = make([]*curve.G1Affine, 0, batch)
+		bk.prod = make([]curve.Fp, batch)
+	}
+	bk.batch = batch
+}
+
+// reset sets the buckets to the point at infinity
+func (bk *bucketsG1) reset() {
+	for i := range bk.affine {
+		bk.affine[i] = curve.G1Affine{}
+		bk.jac[i].SetInfinity()
+	}
+}
+
+// add adds the point a to the bucket b
+func (bk *bucketsG1) add(b int, a *curve.G1Affine) {
+	if bk.batch == 0 || bk.busy[b] {
+		bk.jac[b].AddMixed(a)
+		return
+	}
+	if bk.affine[b].IsInfinity() {
+		bk.affine[b] = *a
+		return
+	}
+	bk.busy[b] = true
+	bk.idx = append(bk.idx, b)
+	bk.p = append(bk.p, &bk.affine[b])
+	bk.q = append(bk.q, a)
+	if len(bk.p) == bk.batch {
+		bk.flush()
+	}
+}
+
+// flush adds the points of the batch
+func (bk *bucketsG1) flush() {
+	curve.BatchAddG1(bk.p, bk.q, bk.prod)
+	for _, b := range bk.idx {
+		bk.busy[b] = false
+	}
+	bk.idx, bk.p, bk.q = bk.idx[:0], bk.p[:0], bk.q[:0]
+}
+
+// reduce returns sum(i * bucket[i-1]) with a running sum:
+// sum over i of (bucket[n-1] + ... + bucket[i])
+func (bk *bucketsG1) reduce() curve.G1Jac {
+	bk.flush()
+	var sum, acc curve.G1Jac
+	for i := len(bk.affine) - 1; i >= 0; i-- {
+		sum.AddMixed(&bk.affine[i])
+		sum.Add(&bk.jac[i])
+		acc.Add(&sum)
+	}
+	return acc
+}
+
+// G2 version of bucketsG1
+type bucketsG2 struct {
+	affine []curve.G2Affine
+	jac    []curve.G2Jac
+	busy   []bool
+	batch  int
+	idx    []int
+	p, q   []*curve.G2Affine
+	prod   []curve.Fp2
+}
+
+func newBucketsG2(n, batch int) *bucketsG2 {
+	bk := new(bucketsG2)
+	bk.resize(n, batch)
+	return bk
+}
+
+func (bk *bucketsG2) resize(n, batch int) {
+	if cap(bk.affine) < n {
+		bk.affine = make([]curve.G2Affine, n)
+		bk.jac = make([]curve.G2Jac, n)
+		bk.busy = make([]bool, n)
+	}
+	bk.affine, bk.jac, bk.busy = bk.affine[:n], bk.jac[:n], bk.busy[:n]
+	if cap(bk.prod) < batch {
+		bk.idx = make([]int, 0, batch)
+		bk.p = make([]*curve.G2Affine, 0, batch)
+		bk.q = make([]*curve.G2Affine, 0, batch)
+		bk.prod = make([]curve.Fp2, batch)
+	}
+	bk.batch = batch
+}
+
+func (bk *bucketsG2) reset() {
+	for i := range bk.affine {
+		bk.affine[i] = curve.G2Affine{}
+		bk.jac[i].SetInfinity()
+	}
+}
+
+func (bk *bucketsG2) add(b int, a *curve.G2Affine) {
+	if bk.batch == 0 || bk.busy[b] {
+		bk.jac[b].AddMixed(a)
+		return
+	}
+	if bk.affine[b].IsInfinity() {
+		bk.affine[b] = *a
+		return
+	}
+	bk.busy[b] = true
+	bk.idx = append(bk.idx, b)
+	bk.p = append(bk.p, &bk.affine[b])
+	bk.q = append(bk.q, a)
+	if len(bk.p) == bk.batch {
+		bk.flush()
+	}
+}
+
+func (bk *bucketsG2) flush() {
+	curve.BatchAddG2(bk.p, bk.q, bk.prod)
+	for _, b := range bk.idx {
+		bk.busy[b] = false
+	}
+	bk.idx, bk.p, bk.q = bk.idx[:0], bk.p[:0], bk.q[:0]
+}
+
+func (bk *bucketsG2) reduce() curve.G2Jac {
+	bk.flush()
+	var sum, acc curve.G2Jac
+	for i := len(bk.affine) - 1; i >= 0; i-- {
+		sum.AddMixed(&bk.affine[i])
+		sum.Add(&bk.jac[i])
+		acc.Add(&sum)
+	}
+	return acc
+}
+
+// Multiply the points by the scalars and add the results (multi-scalar
+// multiplication) with the Pippenger bucket method. For each window of c bits
+// of the scalars, each point is added to the bucket of its c bits value, and
+// the buckets are reduced with a running sum: sum(i * bucket[i]). Zero
+// scalars are skipped and the points with scalar one are added directly.
+// The points are in affine coordinates, so that they are added to the
+// buckets with batches of affine additions (see bucketsG1), or with mixed
+// additions when the windows are small.  The window size is chosen with the
+// costs of the operations of m.  The buckets and the indexes of the scalars
+// are kept in buf.
+func pippengerG1(a []curve.G1Affine, k []ff.Element, m *msmParams, buf *msmBuffers) curve.G1Jac {
+	idx, ones, nbits := msmScalars(k, buf.idx[:0], buf.ones[:0])
+	buf.idx, buf.ones = idx, ones
+
+	var R curve.G1Jac
+	if len(idx) > 0 {
+		c := m.window(len(idx), nbits)
+		nbuckets := 1<<uint(c) - 1
+		bk := buf.bucketsG1(nbuckets, batchAffineSize(nbuckets))
+		for start := ((nbits - 1) / c) * c; start >= 0; start -= c {
+			for i := 0; i < c; i++ {
+				R.Double(&R)
+			}
+			bk.reset()
+			for _, j := range idx {
+				b := getWindow(&k[j], start, c)
+				if b == 0 {
+					continue
+				}
+				bk.add(int(b-1), &a[j])
+			}
+			acc := bk.reduce()
+			R.Add(&acc)
+		}
+	}
+	for _, i := range ones {
+		R.AddMixed(&a[i])
+	}
+	return R
+}
+
+// G2 version of pippengerG1
+func pippengerG2(a []curve.G2Affine, k []ff.Element, m *msmParams, buf *msmBuffers) curve.G2Jac {
+	idx, ones, nbits := msmScalars(k, buf.idx[:0], buf.ones[:0])
+	buf.idx, buf.ones = idx, ones
+
+	var R curve.G2Jac
+	if len(idx) > 0 {
+		c := m.window(len(idx), nbits)
+		nbuckets := 1<<uint(c) - 1
+		bk := buf.bucketsG2(nbuckets, batchAffineSize(nbuckets))
+		for start := ((nbits - 1) / c) * c; start >= 0; start -= c {
+			for i := 0; i < c; i++ {
+				R.Double(&R)
+			}
+			bk.reset()
+			for _, j := range idx {
+				b := getWindow(&k[j], start, c)
+				if b == 0 {
+					continue
+				}
+				bk.add(int(b-1), &a[j])
+			}
+			acc := bk.reduce()
+			R.Add(&acc)
+		}
+	}
+	for _, i := range ones {
+		R.AddMixed(&a[i])
+	}
+	return R
+}
+
+// pointsG1 returns the points [start, end) of p in affine coordinates: the
+// cached points when they are not nil, or the points converted in buf
+func pointsG1(p []*bn256.G1, cached []curve.G1Affine, start, end int, buf *msmBuffers) []curve.G1Affine {
+	if cached != nil {
+		return cached[start:end]
+	}
+	buf.p1 = growG1Affine(buf.p1, end-start)
+	for i := range buf.p1 {
+		buf.p1[i] = curve.G1FromBn256(p[start+i])
+	}
+	return buf.p1
+}
+
+// G2 version of pointsG1
+func pointsG2(p []*bn256.G2, cached []curve.G2Affine, start, end int, buf *msmBuffers) []curve.G2Affine {
+	if cached != nil {
+		return cached[start:end]
+	}
+	buf.p2 = growG2Affine(buf.p2, end-start)
+	for i := range buf.p2 {
+		buf.p2[i] = curve.G2FromBn256(p[start+i])
+	}
+	return buf.p2
+}
+
+// pippengerGLVG1 multiplies the points by each of the scalars of a batch,
+// split for the GLV method, with the Pippenger bucket method over the
+// points and their endomorphisms: the scalars have half the bits, so there
+// are half the windows to reduce and to double.  The endomorphisms are
+// computed once for all the scalars, in buf, and the results are returned
+// in buf.
+func pippengerGLVG1(p []curve.G1Affine, k []*scalars, m *msmParams, buf *msmBuffers) []curve.G1Jac {
+	n := len(p)
+	buf.a1 = growG1Affine(buf.a1, 2*n)
+	buf.s = growElements(buf.s, 2*n)
+	a, s := buf.a1, buf.s
+	copy(a, p)
+	for i := range p {
+		a[n+i].Endo(&p[i])
+	}
+	buf.r1 = growG1Jac(buf.r1, len(k))
+	for i := range k {
+		copy(s, k[i].g1[0])
+		copy(s[n:], k[i].g1[1])
+		buf.r1[i] = pippengerG1(a, s, m, buf)
+	}
+	return buf.r1
+}
+
+// G2 version of pippengerGLVG1, with the powers of ψ
+func pippengerGLVG2(p []curve.G2Affine, k []*scalars, m *msmParams, buf *msmBuffers) []curve.G2Jac {
+	n := len(p)
+	buf.a2 = growG2Affine(buf.a2, 4*n)
+	buf.s = growElements(buf.s, 4*n)
+	a, s := buf.a2, buf.s
+	copy(a, p)
+	for i := range p {
+		for j := 1; j < 4; j++ {
+			a[j*n+i].Psi(&a[(j-1)*n+i])
+		}
+	}
+	buf.r2 = growG2Jac(buf.r2, len(k))
+	for i := range k {
+		for j := range k[i].g2 {
+			copy(s[j*n:], k[i].g2[j])
+		}
+		buf.r2[i] = pippengerG2(a, s, m, buf)
+	}
+	return buf.r2
+}
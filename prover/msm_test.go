@@ -0,0 +1,208 @@
+package prover
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+	"time"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-circom-prover-verifier/internal/curve"
+	"github.com/iden3/go-circom-prover-verifier/types"
+	"github.com/iden3/go-iden3-crypto/ff"
+	"github.com/stretchr/testify/assert"
+)
+
+// the parameters of the Pippenger method of the tests
+var (
+	testParamsG1 = DefaultTuning.msmParams(false, 1)
+	testParamsG2 = DefaultTuning.msmParams(true, 1)
+)
+
+// randomWitnessArray returns n scalars like the ones of a circuit witness,
+// with zeros, ones and small values mixed with random field elements
+func randomWitnessArray(n int) []*big.Int {
+	k := randomBigIntArray(n)
+	for i := range k {
+		switch i % 5 {
+		case 0:
+			k[i] = big.NewInt(0)
+		case 1:
+			k[i] = big.NewInt(1)
+		case 2:
+			k[i] = big.NewInt(int64(i))
+		}
+	}
+	return k
+}
+
+// scalarsFromBigInts returns the values as field elements in regular form
+func scalarsFromBigInts(k []*big.Int) []ff.Element {
+	s := make([]ff.Element, len(k))
+	for i := range k {
+		s[i].SetBigInt(k[i]).FromMont()
+	}
+	return s
+}
+
+func TestPippengerG1(t *testing.T) {
+	// the buffers are reused by all the multiplications
+	buf := new(msmBuffers)
+	for _, n := range []int{1, 2, 10, 100, N1} {
+		arrayW := randomWitnessArray(n)
+		arrayG1 := randomG1Array(n)
+		if n > 2 {
+			arrayW[n-1] = new(big.Int).Sub(types.R, big.NewInt(1))
+		}
+
+		beforeT := time.Now()
+		Q1 := new(bn256.G1).ScalarBaseMult(new(big.Int))
+		for i := 0; i < n; i++ {
+			Q1.Add(Q1, new(bn256.G1).ScalarMult(arrayG1[i], arrayW[i]))
+		}
+		fmt.Printf("n: %d, Std. Mult. time elapsed: %s\n", n, time.Since(beforeT))
+
+		beforeT = time.Now()
+		Q2 := pippengerG1(curve.G1SliceFromBn256(arrayG1), scalarsFromBigInts(arrayW), testParamsG1, buf)
+		fmt.Printf("n: %d, Pippenger time elapsed: %s\n", n, time.Since(beforeT))
+
+		if !bytes.Equal(Q1.Marshal(), Q2.Bn256().Marshal()) {
+			t.Errorf("Error in Pippenger, n: %d", n)
+		}
+	}
+
+	// repeated points, which are doubled in the buckets
+	arrayG1 := randomG1Array(1)
+	arrayG1 = append(arrayG1, arrayG1[0], arrayG1[0], arrayG1[0])
+	arrayW := []*big.Int{big.NewInt(3), big.NewInt(3), big.NewInt(1), big.NewInt(1)}
+	Q1 := new(bn256.G1).ScalarMult(arrayG1[0], big.NewInt(8))
+	Q2 := pippengerG1(curve.G1SliceFromBn256(arrayG1), scalarsFromBigInts(arrayW), testParamsG1, buf)
+	if !bytes.Equal(Q1.Marshal(), Q2.Bn256().Marshal()) {
+		t.Error("Error in Pippenger with repeated points")
+	}
+
+	// all zero scalars
+	arrayG1 = randomG1Array(3)
+	Q := pippengerG1(curve.G1SliceFromBn256(arrayG1), make([]ff.Element, 3), testParamsG1, buf)
+	if !Q.IsInfinity() {
+		t.Error("Error in Pippenger with zero scalars")
+	}
+}
+
+func TestPippengerG2(t *testing.T) {
+	buf := new(msmBuffers)
+	for _, n := range []int{1, 2, 10, 100, 1000} {
+		arrayW := randomWitnessArray(n)
+		arrayG2 := randomG2Array(n)
+
+		beforeT := time.Now()
+		Q1 := new(bn256.G2).ScalarBaseMult(new(big.Int))
+		for i := 0; i < n; i++ {
+			Q1.Add(Q1, new(bn256.G2).ScalarMult(arrayG2[i], arrayW[i]))
+		}
+		fmt.Printf("n: %d, Std. Mult. time elapsed: %s\n", n, time.Since(beforeT))
+
+		beforeT = time.Now()
+		Q2 := pippengerG2(curve.G2SliceFromBn256(arrayG2), scalarsFromBigInts(arrayW), testParamsG2, buf)
+		fmt.Printf("n: %d, Pippenger time elapsed: %s\n", n, time.Since(beforeT))
+
+		if !bytes.Equal(Q1.Marshal(), Q2.Bn256().Marshal()) {
+			t.Errorf("Error in Pippenger, n: %d", n)
+		}
+	}
+}
+
+func TestBucketsBatchAffine(t *testing.T) {
+	// points repeated and negated, so that the batches have doublings,
+	// sums to infinity and points to buckets that are busy
+	arrayG1 := curve.G1SliceFromBn256(randomG1Array(50))
+	arrayG2 := curve.G2SliceFromBn256(randomG2Array(50))
+	for i := 0; i < 50; i++ {
+		var n1 curve.G1Affine
+		var n2 curve.G2Affine
+		arrayG1 = append(arrayG1, arrayG1[i], *n1.Neg(&arrayG1[i]))
+		arrayG2 = append(arrayG2, arrayG2[i], *n2.Neg(&arrayG2[i]))
+	}
+	const nbuckets = 7
+	for _, batch := range []int{0, 1, 4, 16} {
+		bk1 := newBucketsG1(nbuckets, batch)
+		bk2 := newBucketsG2(nbuckets, batch)
+		// two rounds, to check that reset empties the buckets
+		for r := 0; r < 2; r++ {
+			bk1.reset()
+			bk2.reset()
+			var exp1 [nbuckets]curve.G1Jac
+			var exp2 [nbuckets]curve.G2Jac
+			for j := range arrayG1 {
+				// the point, its copy and its negation are in the same
+				// bucket, with the copy and the negation swapped in the
+				// second round
+				i, o := j, j
+				if j >= 50 {
+					i, o = j^r, (j-50)/2
+				}
+				b := o * (r + 1) % nbuckets
+				bk1.add(b, &arrayG1[i])
+				bk2.add(b, &arrayG2[i])
+				exp1[b].AddMixed(&arrayG1[i])
+				exp2[b].AddMixed(&arrayG2[i])
+			}
+			var R1, S1 curve.G1Jac
+			var R2, S2 curve.G2Jac
+			for b := nbuckets - 1; b >= 0; b-- {
+				S1.Add(&exp1[b])
+				R1.Add(&S1)
+				S2.Add(&exp2[b])
+				R2.Add(&S2)
+			}
+			Q1 := bk1.reduce()
+			Q2 := bk2.reduce()
+			assert.True(t, R1.Equal(&Q1), "batch: %d", batch)
+			assert.True(t, R2.Equal(&Q2), "batch: %d", batch)
+		}
+	}
+}
+
+func TestPippengerGLV(t *testing.T) {
+	buf := new(msmBuffers)
+	pr := newProofRun(context.Background(), Options{})
+	for _, n := range []int{1, 10, 200} {
+		arrayW := randomWitnessArray(n)
+		if n > 2 {
+			arrayW[n-1] = new(big.Int).Sub(types.R, big.NewInt(1))
+		}
+		arrayG1 := randomG1Array(n)
+		arrayG2 := randomG2Array(n)
+		Q1 := new(bn256.G1).ScalarBaseMult(new(big.Int))
+		Q2 := new(bn256.G2).ScalarBaseMult(new(big.Int))
+		for i := 0; i < n; i++ {
+			Q1.Add(Q1, new(bn256.G1).ScalarMult(arrayG1[i], arrayW[i]))
+			Q2.Add(Q2, new(bn256.G2).ScalarMult(arrayG2[i], arrayW[i]))
+		}
+
+		k := new(scalars).split(pr, scalarsFromBigInts(arrayW), true, true)
+		// a batch with the scalars doubled
+		arrayW2 := make([]*big.Int, n)
+		for i := range arrayW {
+			arrayW2[i] = new(big.Int).Lsh(arrayW[i], 1)
+			arrayW2[i].Mod(arrayW2[i], types.R)
+		}
+		k2 := new(scalars).split(pr, scalarsFromBigInts(arrayW2), true, true)
+		R1s := pippengerGLVG1(curve.G1SliceFromBn256(arrayG1), []*scalars{k, k2}, testParamsG1, buf)
+		assert.Equal(t, Q1.Marshal(), R1s[0].Bn256().Marshal())
+		assert.Equal(t, new(bn256.G1).Add(Q1, Q1).Marshal(), R1s[1].Bn256().Marshal())
+		R2s := pippengerGLVG2(curve.G2SliceFromBn256(arrayG2), []*scalars{k, k2}, testParamsG2, buf)
+		assert.Equal(t, Q2.Marshal(), R2s[0].Bn256().Marshal())
+		assert.Equal(t, new(bn256.G2).Add(Q2, Q2).Marshal(), R2s[1].Bn256().Marshal())
+
+		// over a part of the points
+		Q1 = new(bn256.G1).ScalarBaseMult(new(big.Int))
+		for i := n / 2; i < n; i++ {
+			Q1.Add(Q1, new(bn256.G1).ScalarMult(arrayG1[i], arrayW[i]))
+		}
+		R1s = pippengerGLVG1(pointsG1(arrayG1, nil, n/2, n, buf), buf.sliceScalars([]*scalars{k}, n/2, n), testParamsG1, buf)
+		assert.Equal(t, Q1.Marshal(), R1s[0].Bn256().Marshal())
+	}
+}
@@ -0,0 +1,312 @@
+package prover
+
+import (
+	"context"
+	"io"
+	"math/big"
+	"runtime"
+	"sync"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-circom-prover-verifier/internal/curve"
+)
+
+// Phases of the proof generation reported to the ProgressFunc
+const (
+	PhaseA     = "A"
+	PhaseB2    = "B2"
+	PhaseB1    = "B1"
+	PhaseC     = "C"
+	PhaseH     = "H"
+	PhaseHExps = "HExps"
+)
+
+// ProgressFunc is called during the proof generation with the phase and the
+// fraction of the phase done, in [0, 1].  The calls are not concurrent.
+type ProgressFunc func(phase string, fraction float64)
+
+// Options are the options of the proof generation
+type Options struct {
+	// Progress, when not nil, is called to report the progress of the proof
+	Progress ProgressFunc
+	// Workers is the number of goroutines used by the parallel sections of
+	// the proof.  When 0 it is the size of the Pool, or runtime.NumCPU()
+	// without Pool.  With 1 the proof is computed in the calling goroutine,
+	// in a deterministic order.
+	Workers int
+	// Pool, when not nil, bounds the number of goroutines working at the
+	// same time across all the proofs that share it
+	Pool *WorkerPool
+	// Rand is the source of the r and s blinding factors of the proof.  When
+	// nil crypto/rand is used.  With the same proving key, witness and Rand
+	// the same proof is generated.
+	Rand io.Reader
+	// R and S, when not nil, are used as the blinding factors of the proof
+	// instead of sampling them from Rand.  Both must be given, in [0, R).
+	R, S *big.Int
+	// Tuning, when not nil, is used instead of DefaultTuning to choose the
+	// window sizes of the multi-scalar multiplications
+	Tuning *Tuning
+	// Workspace, when not nil, provides the buffers of the proof, which are
+	// reused by the following proofs given the same Workspace.  Its domain
+	// size must be the one of the proving key.
+	Workspace *Workspace
+}
+
+// WorkerPool bounds the number of goroutines working at the same time across
+// concurrent proofs.  A WorkerPool can be shared by any number of proofs.
+type WorkerPool struct {
+	tokens chan struct{}
+}
+
+// NewWorkerPool returns a WorkerPool that runs at most n goroutines at the
+// same time
+func NewWorkerPool(n int) *WorkerPool {
+	if n < 1 {
+		n = 1
+	}
+	return &WorkerPool{tokens: make(chan struct{}, n)}
+}
+
+// Size returns the maximum number of goroutines of the WorkerPool
+func (wp *WorkerPool) Size() int {
+	return cap(wp.tokens)
+}
+
+func (wp *WorkerPool) acquire() {
+	if wp != nil {
+		wp.tokens <- struct{}{}
+	}
+}
+
+func (wp *WorkerPool) release() {
+	if wp != nil {
+		<-wp.tokens
+	}
+}
+
+// chunksPerWorker is the number of chunks in which the work of each worker
+// of a multi-scalar multiplication is split, to check the context and report
+// the progress between them
+const chunksPerWorker = 4
+
+// proofRun contains the state of a proof generation
+type proofRun struct {
+	ctx     context.Context
+	opts    Options
+	workers int
+	// sched bounds to workers the goroutines working at the same time in
+	// the concurrent phases of the proof, so that they share the workers
+	sched      *WorkerPool
+	progressMu sync.Mutex
+	// stats, when not nil, collects the measures of the phases
+	stats   *Stats
+	statsMu sync.Mutex
+}
+
+func newProofRun(ctx context.Context, opts Options) *proofRun {
+	workers := opts.Workers
+	if workers <= 0 {
+		if opts.Pool != nil {
+			workers = opts.Pool.Size()
+		} else {
+			workers = runtime.NumCPU()
+		}
+	}
+	return &proofRun{
+		ctx:     ctx,
+		opts:    opts,
+		workers: workers,
+		sched:   NewWorkerPool(workers),
+	}
+}
+
+// tuning returns the Tuning of the options, or DefaultTuning
+func (pr *proofRun) tuning() *Tuning {
+	if pr.opts.Tuning != nil {
+		return pr.opts.Tuning
+	}
+	return DefaultTuning
+}
+
+// acquire waits for a worker of the proof, and of the pool of the options
+func (pr *proofRun) acquire() {
+	pr.sched.acquire()
+	pr.opts.Pool.acquire()
+}
+
+func (pr *proofRun) release() {
+	pr.opts.Pool.release()
+	pr.sched.release()
+}
+
+// report calls the progress function of the options
+func (pr *proofRun) report(phase string, fraction float64) {
+	if pr.opts.Progress == nil {
+		return
+	}
+	pr.progressMu.Lock()
+	pr.opts.Progress(phase, fraction)
+	pr.progressMu.Unlock()
+}
+
+// chunks splits [0, n) in ranges aligned to groups of gsize for the workers
+func (pr *proofRun) chunks(n, gsize int) [][2]int {
+	return groupRanges(n, pr.workers*chunksPerWorker, gsize)
+}
+
+// run splits [0, n) in a range for each worker and calls f over them in
+// parallel, holding a token of the pool during each call
+func (pr *proofRun) run(n int, f func(start, end int)) {
+	pr.runMin(n, 1, f)
+}
+
+// runMin is run, calling f over the whole [0, n) in the calling goroutine
+// when n is smaller than min
+func (pr *proofRun) runMin(n, min int, f func(start, end int)) {
+	if pr.workers == 1 || n < min {
+		pr.acquire()
+		f(0, n)
+		pr.release()
+		return
+	}
+	var wg sync.WaitGroup
+	for _, r := range ranges(n, pr.workers) {
+		if r[0] == r[1] {
+			continue
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			pr.acquire()
+			defer pr.release()
+			f(start, end)
+		}(r[0], r[1])
+	}
+	wg.Wait()
+}
+
+// parallel calls f over the chunks of [0, n) on the workers of the proof,
+// checking the context before each chunk and reporting the progress of the
+// phase after each chunk.  f receives the worker index.  Each chunk is
+// computed holding a token of the pool.
+func (pr *proofRun) parallel(phase string, n, gsize int, f func(worker, start, end int)) error {
+	if n == 0 {
+		pr.report(phase, 1)
+		return pr.ctx.Err()
+	}
+	chunks := pr.chunks(n, gsize)
+	ch := make(chan [2]int, len(chunks))
+	for _, c := range chunks {
+		ch <- c
+	}
+	close(ch)
+
+	var mu sync.Mutex
+	var err error
+	done := 0
+	work := func(worker int) {
+		for c := range ch {
+			if e := pr.ctx.Err(); e != nil {
+				mu.Lock()
+				err = e
+				mu.Unlock()
+				return
+			}
+			pr.acquire()
+			f(worker, c[0], c[1])
+			pr.release()
+			mu.Lock()
+			done += c[1] - c[0]
+			pr.report(phase, float64(done)/float64(n))
+			mu.Unlock()
+		}
+	}
+	if pr.workers == 1 {
+		work(0)
+		if err != nil {
+			return err
+		}
+		return pr.ctx.Err()
+	}
+	var wg sync.WaitGroup
+	wg.Add(pr.workers)
+	for i := 0; i < pr.workers; i++ {
+		go func(worker int) {
+			defer wg.Done()
+			work(worker)
+		}(i)
+	}
+	wg.Wait()
+	if err != nil {
+		return err
+	}
+	return pr.ctx.Err()
+}
+
+// msmG1 adds the results of f over the chunks of [0, n), computed in
+// parallel, for each of the nk scalars of a batch.  f receives the buffers
+// of its worker, bufs[worker], where the results of each worker are added,
+// and reports its errors in their err.
+func (pr *proofRun) msmG1(phase string, n, gsize, nk int, bufs []*msmBuffers, f func(buf *msmBuffers, start, end int) []curve.G1Jac) ([]*bn256.G1, error) {
+	for _, b := range bufs[:pr.workers] {
+		b.sum1 = growG1Jac(b.sum1, nk)
+		b.err = nil
+	}
+	err := pr.parallel(phase, n, gsize, func(worker, start, end int) {
+		b := bufs[worker]
+		q := f(b, start, end)
+		for j := range q {
+			b.sum1[j].Add(&q[j])
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range bufs[:pr.workers] {
+		if b.err != nil {
+			return nil, b.err
+		}
+	}
+	r := make([]*bn256.G1, nk)
+	for j := range r {
+		var q curve.G1Jac
+		for _, b := range bufs[:pr.workers] {
+			q.Add(&b.sum1[j])
+		}
+		r[j] = q.Bn256()
+	}
+	return r, nil
+}
+
+// G2 version of msmG1
+func (pr *proofRun) msmG2(phase string, n, gsize, nk int, bufs []*msmBuffers, f func(buf *msmBuffers, start, end int) []curve.G2Jac) ([]*bn256.G2, error) {
+	for _, b := range bufs[:pr.workers] {
+		b.sum2 = growG2Jac(b.sum2, nk)
+		b.err = nil
+	}
+	err := pr.parallel(phase, n, gsize, func(worker, start, end int) {
+		b := bufs[worker]
+		q := f(b, start, end)
+		for j := range q {
+			b.sum2[j].Add(&q[j])
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range bufs[:pr.workers] {
+		if b.err != nil {
+			return nil, b.err
+		}
+	}
+	r := make([]*bn256.G2, nk)
+	for j := range r {
+		var q curve.G2Jac
+		for _, b := range bufs[:pr.workers] {
+			q.Add(&b.sum2[j])
+		}
+		r[j] = q.Bn256()
+	}
+	return r, nil
+}
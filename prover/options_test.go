@@ -0,0 +1,213 @@
+package prover
+
+import (
+	"bytes"
+	"context"
+	"math/big"
+	mrand "math/rand"
+	"sync"
+	"testing"
+
+	"github.com/iden3/go-circom-prover-verifier/types"
+	"github.com/iden3/go-circom-prover-verifier/verifier"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateProofWithOptions(t *testing.T) {
+	pk, vk, w := testCircuit(t, 50, 3)
+
+	allPhases := []string{PhaseA, PhaseB2, PhaseB1, PhaseC, PhaseH, PhaseHExps}
+	for _, workers := range []int{1, 0} {
+		var phases []string
+		last := make(map[string]float64)
+		opts := Options{
+			Progress: func(phase string, fraction float64) {
+				if _, ok := last[phase]; !ok {
+					phases = append(phases, phase)
+				}
+				assert.True(t, fraction >= last[phase])
+				assert.True(t, fraction <= 1)
+				last[phase] = fraction
+			},
+			Workers: workers,
+		}
+		proof, pubSignals, err := GenerateProofWithOptions(context.Background(), pk, w, opts)
+		require.Nil(t, err)
+		assert.True(t, verifier.Verify(vk, proof, pubSignals))
+		if workers == 1 {
+			assert.Equal(t, allPhases, phases)
+		} else {
+			// the H phases run at the same time as the others
+			assert.ElementsMatch(t, allPhases, phases)
+		}
+		for _, phase := range phases {
+			assert.Equal(t, 1.0, last[phase])
+		}
+	}
+
+	// cancelled before starting
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := GenerateProofWithOptions(ctx, pk, w, Options{})
+	assert.Equal(t, context.Canceled, err)
+
+	// cancelled during the proof, single-threaded so that no other phase
+	// is running
+	for _, cancelPhase := range []string{PhaseB1, PhaseH} {
+		ctx, cancel = context.WithCancel(context.Background())
+		var phasesAfterCancel []string
+		opts := Options{
+			Progress: func(phase string, fraction float64) {
+				if phase == cancelPhase {
+					cancel()
+				}
+				if ctx.Err() != nil && phase != cancelPhase {
+					phasesAfterCancel = append(phasesAfterCancel, phase)
+				}
+			},
+			Workers: 1,
+		}
+		_, _, err = GenerateProofWithOptions(ctx, pk, w, opts)
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, 0, len(phasesAfterCancel))
+
+		// with the phases running at the same time
+		ctx, cancel = context.WithCancel(context.Background())
+		opts.Progress = func(phase string, fraction float64) {
+			if phase == cancelPhase {
+				cancel()
+			}
+		}
+		opts.Workers = 0
+		_, _, err = GenerateProofWithOptions(ctx, pk, w, opts)
+		assert.Equal(t, context.Canceled, err)
+	}
+
+	// with the Prover tables
+	p := NewProver(pk)
+	_, _, err = p.GenerateProofWithOptions(ctx, w, Options{})
+	assert.Equal(t, context.Canceled, err)
+	proof, pubSignals, err := p.GenerateProofWithOptions(context.Background(), w, Options{})
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+}
+
+func TestGenerateProofWorkers(t *testing.T) {
+	pk, vk, w := testCircuit(t, 50, 3)
+
+	// single-threaded
+	proof, pubSignals, err := GenerateProofWithOptions(context.Background(), pk, w, Options{Workers: 1})
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+
+	// concurrent proofs sharing a pool
+	pool := NewWorkerPool(2)
+	assert.Equal(t, 2, pool.Size())
+	p := NewProver(pk)
+	var wg sync.WaitGroup
+	errs := make([]error, 4)
+	ok := make([]bool, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			opts := Options{Pool: pool}
+			if i%2 == 1 {
+				opts.Workers = 3
+			}
+			var proof *types.Proof
+			var pubSignals []*big.Int
+			if i < 2 {
+				proof, pubSignals, errs[i] = GenerateProofWithOptions(context.Background(), pk, w, opts)
+			} else {
+				proof, pubSignals, errs[i] = p.GenerateProofWithOptions(context.Background(), w, opts)
+			}
+			if errs[i] == nil {
+				ok[i] = verifier.Verify(vk, proof, pubSignals)
+			}
+		}(i)
+	}
+	wg.Wait()
+	for i := range errs {
+		assert.Nil(t, errs[i])
+		assert.True(t, ok[i])
+	}
+	assert.Equal(t, 0, len(pool.tokens))
+}
+
+func assertEqualProofs(t *testing.T, expected, actual *types.Proof) {
+	assert.Equal(t, expected.A.Marshal(), actual.A.Marshal())
+	assert.Equal(t, expected.B.Marshal(), actual.B.Marshal())
+	assert.Equal(t, expected.C.Marshal(), actual.C.Marshal())
+}
+
+func TestGenerateProofRand(t *testing.T) {
+	pk, vk, w := testCircuit(t, 50, 3)
+
+	// same seed, same proof, independently of the workers and the tables
+	proof, pubSignals, err := GenerateProofWithOptions(context.Background(), pk, w, Options{Rand: mrand.New(mrand.NewSource(1))})
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+	proof1, _, err := GenerateProofWithOptions(context.Background(), pk, w, Options{Rand: mrand.New(mrand.NewSource(1)), Workers: 1})
+	require.Nil(t, err)
+	assertEqualProofs(t, proof, proof1)
+	proof1, _, err = NewProver(pk).GenerateProofWithOptions(context.Background(), w, Options{Rand: mrand.New(mrand.NewSource(1))})
+	require.Nil(t, err)
+	assertEqualProofs(t, proof, proof1)
+
+	// different seed
+	proof1, _, err = GenerateProofWithOptions(context.Background(), pk, w, Options{Rand: mrand.New(mrand.NewSource(2))})
+	require.Nil(t, err)
+	assert.NotEqual(t, proof.A.Marshal(), proof1.A.Marshal())
+
+	// explicit r and s, equal to the ones sampled from the seed
+	rnd := mrand.New(mrand.NewSource(1))
+	r, err := randBigInt(rnd)
+	require.Nil(t, err)
+	s, err := randBigInt(rnd)
+	require.Nil(t, err)
+	proof1, _, err = GenerateProofWithOptions(context.Background(), pk, w, Options{R: r, S: s})
+	require.Nil(t, err)
+	assertEqualProofs(t, proof, proof1)
+	proof1, pubSignals, err = GenerateProofWithOptions(context.Background(), pk, w, Options{R: big.NewInt(0), S: big.NewInt(0)})
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk, proof1, pubSignals))
+
+	// invalid r and s
+	_, _, err = GenerateProofWithOptions(context.Background(), pk, w, Options{R: r})
+	assert.NotNil(t, err)
+	_, _, err = GenerateProofWithOptions(context.Background(), pk, w, Options{R: r, S: types.R})
+	assert.NotNil(t, err)
+	_, _, err = GenerateProofWithOptions(context.Background(), pk, w, Options{R: big.NewInt(-1), S: s})
+	assert.NotNil(t, err)
+
+	// source without enough randomness
+	_, _, err = GenerateProofWithOptions(context.Background(), pk, w, Options{Rand: bytes.NewReader(make([]byte, 40))})
+	assert.NotNil(t, err)
+}
+
+func TestRandBigInt(t *testing.T) {
+	// values over R are rejected
+	b := make([]byte, 64)
+	for i := 0; i < 32; i++ {
+		b[i] = 0xff
+	}
+	b[63] = 7
+	r, err := randBigInt(bytes.NewReader(b))
+	require.Nil(t, err)
+	assert.Equal(t, big.NewInt(7), r)
+
+	// the values are spread over the whole field
+	rnd := mrand.New(mrand.NewSource(1))
+	high := 0
+	for i := 0; i < 1000; i++ {
+		r, err = randBigInt(rnd)
+		require.Nil(t, err)
+		assert.True(t, r.Cmp(types.R) < 0)
+		if r.BitLen() == types.R.BitLen() {
+			high++
+		}
+	}
+	assert.True(t, high > 0)
+}
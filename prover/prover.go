@@ -1,211 +1,496 @@
 package prover
 
 import (
+	"context"
 	"crypto/rand"
-	"math"
+	"fmt"
+	"io"
 	"math/big"
-	"runtime"
-	"sync"
 
 	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-circom-prover-verifier/internal/curve"
 	"github.com/iden3/go-circom-prover-verifier/types"
-	"github.com/iden3/go-iden3-crypto/utils"
-	//"fmt"
+	"github.com/iden3/go-iden3-crypto/ff"
 )
 
-// Group Size
+// GSIZE is the group size of the tables of the Prover before it was chosen
+// for each group with the Tuning.
+//
+// Deprecated: the group sizes are chosen by NewProver.
 const (
 	GSIZE = 6
 )
 
-func randBigInt() (*big.Int, error) {
-	maxbits := types.R.BitLen()
-	b := make([]byte, (maxbits/8)-1)
-	_, err := rand.Read(b)
+// randBigInt returns a uniformly random element of [0, R) read from rnd, or
+// from crypto/rand when rnd is nil.  The values read that are not in [0, R)
+// are rejected, so the result only depends on the bytes of rnd.
+func randBigInt(rnd io.Reader) (*big.Int, error) {
+	if rnd == nil {
+		rnd = rand.Reader
+	}
+	bitLen := types.R.BitLen()
+	b := make([]byte, (bitLen+7)/8)
+	for {
+		if _, err := io.ReadFull(rnd, b); err != nil {
+			return nil, err
+		}
+		// clear the bits over the bit length of R
+		b[0] &= uint8(int(1<<uint(bitLen-8*(len(b)-1))) - 1)
+		r := new(big.Int).SetBytes(b)
+		if r.Cmp(types.R) < 0 {
+			return r, nil
+		}
+	}
+}
+
+// blindingFactors returns the r and s values of the proof, taken from the
+// options, or sampled from the randomness source of the options
+func blindingFactors(opts Options) (*big.Int, *big.Int, error) {
+	if opts.R != nil || opts.S != nil {
+		if opts.R == nil || opts.S == nil {
+			return nil, nil, fmt.Errorf("Both R and S must be given")
+		}
+		for _, v := range []*big.Int{opts.R, opts.S} {
+			if v.Sign() < 0 || v.Cmp(types.R) >= 0 {
+				return nil, nil, fmt.Errorf("Blinding factor outside the field: %v", v)
+			}
+		}
+		return opts.R, opts.S, nil
+	}
+	r, err := randBigInt(opts.Rand)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	r := new(big.Int).SetBytes(b)
-	rq := new(big.Int).Mod(r, types.R)
+	s, err := randBigInt(opts.Rand)
+	if err != nil {
+		return nil, nil, err
+	}
+	return r, s, nil
+}
+
+// multiExp computes the multi-scalar multiplications of the proof, the sums
+// of k[i] * P[i] for i in [start, end) over the points of the proving key,
+// for each of the scalars of a batch.  The results can be kept in the
+// buffers of the worker, buf, until the next call.
+type multiExp interface {
+	// groupSize is the alignment of the ranges in which the points of G1,
+	// or of G2 when g2 is true, can be split
+	groupSize(g2 bool) int
+	// glv returns true when the multiplications use the scalars split for
+	// the endomorphisms of the groups
+	glv() bool
+	// withWorkspace returns the multiExp that uses the points of the
+	// proving key cached in ws, which is locked by the proof, or the same
+	// multiExp when it does not use them
+	withWorkspace(pr *proofRun, ws *Workspace) multiExp
+	a(k []*scalars, start, end int, buf *msmBuffers) []curve.G1Jac
+	b1(k []*scalars, start, end int, buf *msmBuffers) []curve.G1Jac
+	b2(k []*scalars, start, end int, buf *msmBuffers) []curve.G2Jac
+	// c is over the points C[NPublic+1:]
+	c(k []*scalars, start, end int, buf *msmBuffers) []curve.G1Jac
+	hExps(k []*scalars, start, end int, buf *msmBuffers) []curve.G1Jac
+}
+
+// pippengerMultiExp computes the multiplications over the points of the
+// proving key with the Pippenger bucket method, with the GLV method.  The
+// points of each range are converted to affine coordinates once for all the
+// scalars of a batch, or once for all the proofs when they are cached in the
+// Workspace of the options.
+type pippengerMultiExp struct {
+	pk     *types.Pk
+	g1, g2 *msmParams
+	// points are the points of the proving key in affine coordinates, empty
+	// when they are converted for each range
+	points *keyPoints
+}
+
+func newPippengerMultiExp(pr *proofRun, pk *types.Pk) pippengerMultiExp {
+	t := pr.tuning()
+	return pippengerMultiExp{
+		pk:     pk,
+		g1:     t.msmParams(false, pr.workers),
+		g2:     t.msmParams(true, pr.workers),
+		points: new(keyPoints),
+	}
+}
+
+func (m pippengerMultiExp) groupSize(g2 bool) int { return 1 }
+
+func (m pippengerMultiExp) glv() bool { return true }
 
-	return rq, nil
+func (m pippengerMultiExp) withWorkspace(pr *proofRun, ws *Workspace) multiExp {
+	m.points = ws.keyPoints(pr, m.pk)
+	return m
+}
+
+func (m pippengerMultiExp) a(k []*scalars, start, end int, buf *msmBuffers) []curve.G1Jac {
+	p := pointsG1(m.pk.A, m.points.a, start, end, buf)
+	return pippengerGLVG1(p, buf.sliceScalars(k, start, end), m.g1, buf)
+}
+
+func (m pippengerMultiExp) b1(k []*scalars, start, end int, buf *msmBuffers) []curve.G1Jac {
+	p := pointsG1(m.pk.B1, m.points.b1, start, end, buf)
+	return pippengerGLVG1(p, buf.sliceScalars(k, start, end), m.g1, buf)
+}
+
+func (m pippengerMultiExp) b2(k []*scalars, start, end int, buf *msmBuffers) []curve.G2Jac {
+	p := pointsG2(m.pk.B2, m.points.b2, start, end, buf)
+	return pippengerGLVG2(p, buf.sliceScalars(k, start, end), m.g2, buf)
+}
+
+func (m pippengerMultiExp) c(k []*scalars, start, end int, buf *msmBuffers) []curve.G1Jac {
+	p := pointsG1(m.pk.C[m.pk.NPublic+1:], m.points.c, start, end, buf)
+	return pippengerGLVG1(p, buf.sliceScalars(k, start, end), m.g1, buf)
+}
+
+func (m pippengerMultiExp) hExps(k []*scalars, start, end int, buf *msmBuffers) []curve.G1Jac {
+	p := pointsG1(m.pk.HExps, m.points.hExps, start, end, buf)
+	return pippengerGLVG1(p, buf.sliceScalars(k, start, end), m.g1, buf)
+}
+
+// groupRanges splits [0, n) in parts ranges aligned to groups of gsize
+func groupRanges(n, parts, gsize int) [][2]int {
+	ngroups := (n + gsize - 1) / gsize
+	var s [][2]int
+	for _, r := range ranges(ngroups, parts) {
+		a, b := r[0]*gsize, r[1]*gsize
+		if b > n {
+			b = n
+		}
+		if a < b {
+			s = append(s, [2]int{a, b})
+		}
+	}
+	return s
 }
 
 // GenerateProof generates the Groth16 zkSNARK proof
 func GenerateProof(pk *types.Pk, w types.Witness) (*types.Proof, []*big.Int, error) {
-	var proof types.Proof
+	return GenerateProofWithOptions(context.Background(), pk, w, Options{})
+}
 
-	r, err := randBigInt()
-	if err != nil {
-		return nil, nil, err
+// GenerateProofWithOptions generates the Groth16 zkSNARK proof with the given
+// options.  The proof generation stops with the context error when the
+// context is done.
+func GenerateProofWithOptions(ctx context.Context, pk *types.Pk, w types.Witness, opts Options) (*types.Proof, []*big.Int, error) {
+	pr := newProofRun(ctx, opts)
+	return generateProof(pr, pk, w, newPippengerMultiExp(pr, pk))
+}
+
+func generateProof(pr *proofRun, pk *types.Pk, w types.Witness, me multiExp) (*types.Proof, []*big.Int, error) {
+	res := generateProofs(pr, pk, []types.Witness{w}, me)
+	return res[0].Proof, res[0].PubSignals, res[0].Err
+}
+
+// proofItem is the state of the proof of a witness of a batch
+type proofItem struct {
+	w    types.Witness
+	r, s *big.Int
+	// the witness is converted once to field elements: in Montgomery form
+	// for the polynomials of H, and in regular form for the scalars of the
+	// MSMs
+	wE, wK []ff.Element
+	// buf are the buffers of the witness in the Workspace of the proof
+	buf      *itemBuffers
+	proof    types.Proof
+	proofBG1 *bn256.G1
+	proofH   *bn256.G1
+}
+
+// generateProofs generates the proofs of the witnesses together: the MSMs of
+// all the witnesses are computed over each range of the points at once.  The
+// witnesses with errors do not stop the proofs of the others.
+func generateProofs(pr *proofRun, pk *types.Pk, ws []types.Witness, me multiExp) []BatchResult {
+	res := make([]BatchResult, len(ws))
+	var items []*proofItem
+	var idx []int
+	for i, w := range ws {
+		// the blinding factors are drawn before checking the witness, so
+		// that the ones of each witness do not depend on the others
+		r, s, err := blindingFactors(pr.opts)
+		if err != nil {
+			res[i].Err = err
+			continue
+		}
+		if len(w) != pk.NVars {
+			res[i].Err = fmt.Errorf("Unexpected witness length, expected: %v, actual: %v", pk.NVars, len(w))
+			continue
+		}
+		items = append(items, &proofItem{w: w, r: r, s: s})
+		idx = append(idx, i)
 	}
-	s, err := randBigInt()
-	if err != nil {
-		return nil, nil, err
+	if len(items) == 0 {
+		return res
 	}
+	if err := proveItems(pr, pk, items, me); err != nil {
+		for _, i := range idx {
+			res[i].Err = err
+		}
+		return res
+	}
+	for j, it := range items {
+		res[idx[j]] = BatchResult{
+			Proof:      &it.proof,
+			PubSignals: it.w[1 : pk.NPublic+1],
+		}
+	}
+	return res
+}
 
-	// BEGIN PAR
-	numcpu := runtime.NumCPU()
-
-	proofA := arrayOfZeroesG1(numcpu)
-	proofB := arrayOfZeroesG2(numcpu)
-	proofC := arrayOfZeroesG1(numcpu)
-	proofBG1 := arrayOfZeroesG1(numcpu)
-	gsize := GSIZE
-	var wg1 sync.WaitGroup
-	wg1.Add(numcpu)
-	for _cpu, _ranges := range ranges(pk.NVars, numcpu) {
-		// split 1
-		go func(cpu int, ranges [2]int) {
-			proofA[cpu] = scalarMultNoDoubleG1(pk.A[ranges[0]:ranges[1]],
-				w[ranges[0]:ranges[1]],
-				proofA[cpu],
-				gsize)
-			proofB[cpu] = scalarMultNoDoubleG2(pk.B2[ranges[0]:ranges[1]],
-				w[ranges[0]:ranges[1]],
-				proofB[cpu],
-				gsize)
-			proofBG1[cpu] = scalarMultNoDoubleG1(pk.B1[ranges[0]:ranges[1]],
-				w[ranges[0]:ranges[1]],
-				proofBG1[cpu],
-				gsize)
-			minLim := pk.NPublic + 1
-			if ranges[0] > pk.NPublic+1 {
-				minLim = ranges[0]
+// proveItems computes the proofs of the items, with the buffers of the
+// Workspace of the options, or of a new Workspace
+func proveItems(pr *proofRun, pk *types.Pk, items []*proofItem, me multiExp) error {
+	ws := pr.opts.Workspace
+	if ws == nil {
+		ws = NewWorkspace(pk.DomainSize)
+	}
+	if err := ws.lock(pk.DomainSize); err != nil {
+		return err
+	}
+	defer ws.unlock()
+	if pr.opts.Workspace != nil {
+		me = me.withWorkspace(pr, ws)
+	}
+	for i, it := range items {
+		it.buf = ws.item(i)
+		it.buf.wE = growElements(it.buf.wE, len(it.w))
+		it.buf.wK = growElements(it.buf.wK, len(it.w))
+		it.wE, it.wK = it.buf.wE, it.buf.wK
+		elementsFromBigInts(pr, it.wE, it.w)
+		elementsFromMont(pr, it.wK, it.wE)
+	}
+	msmW, msmH := ws.msm(false, pr.workers), ws.msm(true, pr.workers)
+
+	// the H phases (the evaluation of the polynomials, the FFTs and the
+	// HExps MSM) do not depend on the MSMs of A, B and C, so they run at
+	// the same time, sharing the workers of the proof
+	gsize, gsizeG2 := me.groupSize(false), me.groupSize(true)
+	splitScalars := func(name string, s *scalars, k []ff.Element, g2 bool) *scalars {
+		if !me.glv() {
+			return s.split(pr, k, false, false)
+		}
+		done := pr.measure(StatsSplit + " " + name)
+		defer done()
+		return s.split(pr, k, true, g2)
+	}
+	var errH error
+	hDone := make(chan struct{})
+	hPhases := func() {
+		defer close(hDone)
+		hS := make([]*scalars, len(items))
+		for i, it := range items {
+			// the progress of H is the fraction of all the witnesses
+			progress := func(fraction float64) {
+				pr.report(PhaseH, (float64(i)+fraction)/float64(len(items)))
 			}
-			if ranges[1] > pk.NPublic+1 {
-				proofC[cpu] = scalarMultNoDoubleG1(pk.C[minLim:ranges[1]],
-					w[minLim:ranges[1]],
-					proofC[cpu],
-					gsize)
+			h, err := calculateH(pr, pk, it.wE, it.buf, progress)
+			if err != nil {
+				errH = err
+				return
 			}
-			wg1.Done()
-		}(_cpu, _ranges)
+			hS[i] = splitScalars("H", &it.buf.hS, h, false)
+		}
+		done := pr.measure(PhaseHExps)
+		var proofH []*bn256.G1
+		proofH, errH = pr.msmG1(PhaseHExps, pk.DomainSize, gsize, len(items), msmH, func(buf *msmBuffers, start, end int) []curve.G1Jac {
+			return me.hExps(hS, start, end, buf)
+		})
+		done()
+		for i := range proofH {
+			items[i].proofH = proofH[i]
+		}
 	}
-	wg1.Wait()
-	// join 1
-	for cpu := 1; cpu < numcpu; cpu++ {
-		proofA[0].Add(proofA[0], proofA[cpu])
-		proofB[0].Add(proofB[0], proofB[cpu])
-		proofC[0].Add(proofC[0], proofC[cpu])
-		proofBG1[0].Add(proofBG1[0], proofBG1[cpu])
+	if pr.workers > 1 {
+		go hPhases()
 	}
-	proof.A = proofA[0]
-	proof.B = proofB[0]
-	proof.C = proofC[0]
-	// END PAR
 
-	h := calculateH(pk, w)
+	msmPhases := func() error {
+		wS := make([]*scalars, len(items))
+		for i, it := range items {
+			wS[i] = splitScalars("W", &it.buf.wS, it.wK, true)
+		}
+		done := pr.measure(PhaseA)
+		proofA, err := pr.msmG1(PhaseA, pk.NVars, gsize, len(items), msmW, func(buf *msmBuffers, start, end int) []curve.G1Jac {
+			return me.a(wS, start, end, buf)
+		})
+		done()
+		if err != nil {
+			return err
+		}
+		done = pr.measure(PhaseB2)
+		proofB, err := pr.msmG2(PhaseB2, pk.NVars, gsizeG2, len(items), msmW, func(buf *msmBuffers, start, end int) []curve.G2Jac {
+			return me.b2(wS, start, end, buf)
+		})
+		done()
+		if err != nil {
+			return err
+		}
+		done = pr.measure(PhaseB1)
+		proofBG1, err := pr.msmG1(PhaseB1, pk.NVars, gsize, len(items), msmW, func(buf *msmBuffers, start, end int) []curve.G1Jac {
+			return me.b1(wS, start, end, buf)
+		})
+		done()
+		if err != nil {
+			return err
+		}
+		wPrv := make([]*scalars, len(items))
+		for i := range wS {
+			prv := wS[i].slice(pk.NPublic+1, pk.NVars)
+			wPrv[i] = &prv
+		}
+		done = pr.measure(PhaseC)
+		proofC, err := pr.msmG1(PhaseC, pk.NVars-pk.NPublic-1, gsize, len(items), msmW, func(buf *msmBuffers, start, end int) []curve.G1Jac {
+			return me.c(wPrv, start, end, buf)
+		})
+		done()
+		if err != nil {
+			return err
+		}
+		for i, it := range items {
+			it.proof.A, it.proof.B, it.proof.C = proofA[i], proofB[i], proofC[i]
+			it.proofBG1 = proofBG1[i]
+		}
+		return nil
+	}
+	err := msmPhases()
+	if pr.workers > 1 {
+		<-hDone
+	} else if err == nil {
+		// single-threaded, the H phases run after the MSMs of A, B and C
+		hPhases()
+	}
+	if err != nil {
+		return err
+	}
+	if errH != nil {
+		return errH
+	}
+
+	done := pr.measure(StatsAssembly)
+	for _, it := range items {
+		assembleProof(pk, it)
+	}
+	done()
+	return nil
+}
 
+// assembleProof adds the blinding factors and the HExps MSM to the proof
+func assembleProof(pk *types.Pk, it *proofItem) {
+	proof, r, s := &it.proof, it.r, it.s
 	proof.A.Add(proof.A, pk.VkAlpha1)
 	proof.A.Add(proof.A, new(bn256.G1).ScalarMult(pk.VkDelta1, r))
 
 	proof.B.Add(proof.B, pk.VkBeta2)
 	proof.B.Add(proof.B, new(bn256.G2).ScalarMult(pk.VkDelta2, s))
 
-	proofBG1[0].Add(proofBG1[0], pk.VkBeta1)
-	proofBG1[0].Add(proofBG1[0], new(bn256.G1).ScalarMult(pk.VkDelta1, s))
-
-	proofC = arrayOfZeroesG1(numcpu)
-	var wg2 sync.WaitGroup
-	wg2.Add(numcpu)
-	for _cpu, _ranges := range ranges(len(h), numcpu) {
-		// split 2
-		go func(cpu int, ranges [2]int) {
-			proofC[cpu] = scalarMultNoDoubleG1(pk.HExps[ranges[0]:ranges[1]],
-				h[ranges[0]:ranges[1]],
-				proofC[cpu],
-				gsize)
-			wg2.Done()
-		}(_cpu, _ranges)
-	}
-	wg2.Wait()
-	// join 2
-	for cpu := 1; cpu < numcpu; cpu++ {
-		proofC[0].Add(proofC[0], proofC[cpu])
-	}
-	proof.C.Add(proof.C, proofC[0])
+	proofBG1 := it.proofBG1
+	proofBG1.Add(proofBG1, pk.VkBeta1)
+	proofBG1.Add(proofBG1, new(bn256.G1).ScalarMult(pk.VkDelta1, s))
+
+	proof.C.Add(proof.C, it.proofH)
 
 	proof.C.Add(proof.C, new(bn256.G1).ScalarMult(proof.A, s))
-	proof.C.Add(proof.C, new(bn256.G1).ScalarMult(proofBG1[0], r))
+	proof.C.Add(proof.C, new(bn256.G1).ScalarMult(proofBG1, r))
 	rsneg := new(big.Int).Mod(new(big.Int).Neg(new(big.Int).Mul(r, s)), types.R)
 	proof.C.Add(proof.C, new(bn256.G1).ScalarMult(pk.VkDelta1, rsneg))
-
-	pubSignals := w[1 : pk.NPublic+1]
-
-	return &proof, pubSignals, nil
 }
 
-func calculateH(pk *types.Pk, w types.Witness) []*big.Int {
+// calculateH returns the scalars of the HExps, in regular form: the
+// coefficients of the polynomial h = (A·B - C) / Z, or its evaluations over
+// the odd coset of the domain multiplied by -Z when the HExps are in the
+// Lagrange basis.  w is the witness in Montgomery form.  The polynomials are
+// evaluated in the buffers of buf, and the result is polA.  The progress of
+// the phase is reported to progress.
+func calculateH(pr *proofRun, pk *types.Pk, w []ff.Element, buf *itemBuffers, progress func(fraction float64)) ([]ff.Element, error) {
 	m := pk.DomainSize
-	polAT := arrayOfZeroes(m)
-	polBT := arrayOfZeroes(m)
-
-	numcpu := runtime.NumCPU()
+	done := pr.measure(StatsPolEval)
+	buf.polA = growElements(buf.polA, m)
+	buf.polB = growElements(buf.polB, m)
+	buf.polC = growElements(buf.polC, m)
+	polAT, polBT, polCT := buf.polA, buf.polB, buf.polC
+	pr.run(m, func(start, end int) {
+		clearElements(polAT[start:end])
+		clearElements(polBT[start:end])
+	})
 
-	var wg1 sync.WaitGroup
-	wg1.Add(2)
-	go func() {
-		for i := 0; i < pk.NVars; i++ {
-			for j := range pk.PolsA[i] {
-				polAT[j] = fAdd(polAT[j], fMul(w[i], pk.PolsA[i][j]))
+	// evaluations of A and B over the domain, one on each worker
+	pols := []*types.Pols{&pk.PolsA, &pk.PolsB}
+	polsT := [][]ff.Element{polAT, polBT}
+	pr.run(2, func(start, end int) {
+		var c ff.Element
+		for p := start; p < end; p++ {
+			for i := 0; i < pk.NVars; i++ {
+				constraints, coefs := pols[p].Wire(i)
+				for k, j := range constraints {
+					c.Mul(&coefs[k], &w[i])
+					polsT[p][j].Add(&polsT[p][j], &c)
+				}
 			}
 		}
-		wg1.Done()
-	}()
-	go func() {
-		for i := 0; i < pk.NVars; i++ {
-			for j := range pk.PolsB[i] {
-				polBT[j] = fAdd(polBT[j], fMul(w[i], pk.PolsB[i][j]))
-			}
+	})
+
+	// the witness satisfies the constraints, so the evaluations of C over
+	// the domain are the products of the evaluations of A and B
+	pr.run(m, func(start, end int) {
+		for i := start; i < end; i++ {
+			polCT[i].Mul(&polAT[i], &polBT[i])
 		}
-		wg1.Done()
-	}()
-	wg1.Wait()
-	polATe := utils.BigIntArrayToElementArray(polAT)
-	polBTe := utils.BigIntArrayToElementArray(polBT)
-
-	polASe := ifft(polATe)
-	polBSe := ifft(polBTe)
-
-	r := int(math.Log2(float64(m))) + 1
-	roots := newRootsT()
-	roots.setRoots(r)
-
-	var wg2 sync.WaitGroup
-	wg2.Add(numcpu)
-	for _cpu, _ranges := range ranges(len(polASe), numcpu) {
-		go func(cpu int, ranges [2]int) {
-			for i := ranges[0]; i < ranges[1]; i++ {
-				polASe[i].Mul(polASe[i], roots.roots[r][i])
-				polBSe[i].Mul(polBSe[i], roots.roots[r][i])
-			}
-			wg2.Done()
-		}(_cpu, _ranges)
-	}
-	wg2.Wait()
-
-	polATodd := fft(polASe)
-	polBTodd := fft(polBSe)
-
-	polABT := arrayOfZeroesE(len(polASe) * 2)
-	var wg3 sync.WaitGroup
-	wg3.Add(numcpu)
-	for _cpu, _ranges := range ranges(len(polASe), numcpu) {
-		go func(cpu int, ranges [2]int) {
-			for i := ranges[0]; i < ranges[1]; i++ {
-				polABT[2*i].Mul(polATe[i], polBTe[i])
-				polABT[2*i+1].Mul(polATodd[i], polBTodd[i])
-			}
-			wg3.Done()
-		}(_cpu, _ranges)
+	})
+	done()
+
+	// evaluations of A, B and C over the coset of the domain
+	d := getDomain(log2(m))
+	steps := 8.0
+	progress(1 / steps)
+	for i, pol := range [][]ff.Element{polAT, polBT, polCT} {
+		name := " " + string(rune('A'+i))
+		done = pr.measure(StatsIFFT + name)
+		if err := d.ifft(pr, pol); err != nil {
+			return nil, err
+		}
+		done()
+		done = pr.measure(StatsCosetFFT + name)
+		if err := d.cosetFFT(pr, pol); err != nil {
+			return nil, err
+		}
+		done()
+		progress(float64(2*i+3) / steps)
 	}
-	wg3.Wait()
 
-	hSeFull := ifft(polABT)
+	done = pr.measure(StatsPolH)
+	// A·B - C = h·Z, and Z is the constant g^m - 1 = -2 over the coset
+	pr.run(m, func(start, end int) {
+		for i := start; i < end; i++ {
+			polAT[i].Mul(&polAT[i], &polBT[i])
+			polAT[i].Sub(&polAT[i], &polCT[i])
+		}
+	})
 
-	hSe := hSeFull[m:]
-	return utils.ElementArrayToBigIntArray(hSe)
+	if pk.HExpsCoset {
+		// the HExps are the Lagrange basis over the coset divided by
+		// -Z(g·w^i) = 2, so the scalars are the evaluations of h·Z
+		elementsFromMont(pr, polAT, polAT)
+		done()
+		progress(1)
+		return polAT, nil
+	}
+	// the HExps are the powers of tau, so the scalars are the coefficients
+	// of h
+	zInv := ff.NewElement().Inverse(ff.NewElement().SetBigInt(big.NewInt(-2)))
+	pr.run(m, func(start, end int) {
+		for i := start; i < end; i++ {
+			polAT[i].Mul(&polAT[i], zInv)
+		}
+	})
+	done()
+	done = pr.measure(StatsCosetIFFT + " H")
+	if err := d.cosetIFFT(pr, polAT); err != nil {
+		return nil, err
+	}
+	elementsFromMont(pr, polAT, polAT)
+	done()
+	progress(1)
+	return polAT, nil
 }
 
 func ranges(n, parts int) [][2]int {
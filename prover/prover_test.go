@@ -1,15 +1,21 @@
 package prover
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math/big"
 	"os"
 	"testing"
 	"time"
 
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-circom-prover-verifier/internal/fr"
 	"github.com/iden3/go-circom-prover-verifier/parsers"
+	"github.com/iden3/go-circom-prover-verifier/types"
 	"github.com/iden3/go-circom-prover-verifier/verifier"
+	"github.com/iden3/go-iden3-crypto/ff"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -96,3 +102,62 @@ func BenchmarkGenerateProof(b *testing.B) {
 		GenerateProof(pk, w)
 	}
 }
+
+// cosetHExps returns the HExps of the Lagrange basis over the odd points of
+// the domain of size 2m, L_{2m,2i+1}(tau) / delta, from the powers of tau
+// tau^j * Z(tau) / delta.  With a = w_{2m}^(2i+1), a^m = -1 and
+//
+//	L_{2m,2i+1}(x) = (x^2m - 1) / 2m * a / (x - a)
+//	               = Z(x) * (x^m + 1) / (x - a) * a / 2m
+//	               = Z(x) * sum_j x^j * a^(m-j) / 2m
+func cosetHExps(pk *types.Pk) []*bn256.G1 {
+	m := pk.DomainSize
+	bits := 0
+	for 1<<bits < m {
+		bits++
+	}
+	w := fr.RootOfUnity(bits + 1)
+	inv2m := ff.NewElement().Inverse(ff.NewElement().SetUint64(uint64(2 * m)))
+	hExps := make([]*bn256.G1, m)
+	a := ff.NewElement().Set(w)
+	w2 := ff.NewElement().Square(w)
+	for i := 0; i < m; i++ {
+		// a^(m-j), from a^m down to a
+		c := ff.NewElement().Exp(*a, uint64(m))
+		aInv := ff.NewElement().Inverse(a)
+		hExps[i] = new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+		for j := 0; j < m; j++ {
+			s := ff.NewElement().Mul(c, inv2m)
+			hExps[i].Add(hExps[i], new(bn256.G1).ScalarMult(pk.HExps[j], s.ToBigIntRegular(new(big.Int))))
+			c.Mul(c, aInv)
+		}
+		a.Mul(a, w2)
+	}
+	return hExps
+}
+
+func TestGenerateProofHExpsCoset(t *testing.T) {
+	pk, vk, w := testCircuit(t, 10, 3)
+	pkCoset := *pk
+	pkCoset.HExps = cosetHExps(pk)
+	pkCoset.HExpsCoset = true
+
+	proof, pubSignals, err := GenerateProof(&pkCoset, w)
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+
+	proof, pubSignals, err = NewProver(&pkCoset).GenerateProof(w)
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+
+	montLE, points := pkMontLE(&pkCoset)
+	proof, pubSignals, err = NewStreamProver(&pkCoset, bytes.NewReader(montLE), points).GenerateProof(w)
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+
+	// the same HExps taken as the powers of tau
+	pkCoset.HExpsCoset = false
+	proof, pubSignals, err = GenerateProof(&pkCoset, w)
+	require.Nil(t, err)
+	assert.False(t, verifier.Verify(vk, proof, pubSignals))
+}
@@ -0,0 +1,149 @@
+package prover
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/iden3/go-circom-prover-verifier/types"
+)
+
+// Phases of the proof generation that are only measured in the Stats, in
+// addition to the MSM phases reported to the ProgressFunc
+const (
+	// evaluation of the A, B and C polynomials over the domain
+	StatsPolEval = "PolEval"
+	// the FFTs are measured for each polynomial, as StatsIFFT+" A"
+	StatsIFFT      = "IFFT"
+	StatsCosetFFT  = "CosetFFT"
+	StatsCosetIFFT = "CosetIFFT"
+	// evaluation of A·B - C over the coset and conversion of the scalars
+	StatsPolH = "PolH"
+	// splitting of the scalars for the endomorphisms (GLV), measured for
+	// the witness and for H, as StatsSplit+" W"
+	StatsSplit = "Split"
+	// addition of the blinding factors and of the MSMs to the proof
+	StatsAssembly = "Assembly"
+)
+
+// PhaseStats contains the measures of a phase of the proof generation
+type PhaseStats struct {
+	Name     string
+	Duration time.Duration
+	// Allocs and AllocBytes are the number and the size of the heap
+	// objects allocated during the phase by the whole process, so they
+	// include the allocations of the phases running at the same time, and
+	// of concurrent proofs
+	Allocs     uint64
+	AllocBytes uint64
+}
+
+// Stats contains the measures of the phases of a proof generation, in the
+// order in which they end, and the sizes of the circuit.  The phases of H
+// run at the same time as the MSMs of A, B and C when there is more than one
+// worker, so the sum of the durations of the phases can be larger than the
+// total.
+type Stats struct {
+	NVars      int
+	DomainSize int
+	// NPolsA and NPolsB are the number of non zero coefficients of PolsA
+	// and PolsB
+	NPolsA int
+	NPolsB int
+
+	Phases     []PhaseStats
+	Duration   time.Duration
+	Allocs     uint64
+	AllocBytes uint64
+}
+
+func newStats(pk *types.Pk) *Stats {
+	return &Stats{
+		NVars:      pk.NVars,
+		DomainSize: pk.DomainSize,
+		NPolsA:     pk.PolsA.Len(),
+		NPolsB:     pk.PolsB.Len(),
+	}
+}
+
+// Phase returns the measures of the phase with the given name, or nil when
+// the phase has not been measured
+func (s *Stats) Phase(name string) *PhaseStats {
+	for i := range s.Phases {
+		if s.Phases[i].Name == name {
+			return &s.Phases[i]
+		}
+	}
+	return nil
+}
+
+// String returns the measures in a line for each phase
+func (s *Stats) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "NVars: %d, DomainSize: %d, PolsA: %d, PolsB: %d\n", s.NVars, s.DomainSize, s.NPolsA, s.NPolsB)
+	for _, p := range s.Phases {
+		fmt.Fprintf(&b, "%-12s %14v %10d allocs %14d bytes\n", p.Name, p.Duration, p.Allocs, p.AllocBytes)
+	}
+	fmt.Fprintf(&b, "%-12s %14v %10d allocs %14d bytes\n", "Total", s.Duration, s.Allocs, s.AllocBytes)
+	return b.String()
+}
+
+// measure starts measuring a phase of the proof, and returns the function
+// that ends it.  It does nothing when the stats are not collected.
+func (pr *proofRun) measure(name string) func() {
+	if pr.stats == nil {
+		return func() {}
+	}
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	start := time.Now()
+	allocs, allocBytes := ms.Mallocs, ms.TotalAlloc
+	return func() {
+		runtime.ReadMemStats(&ms)
+		pr.statsMu.Lock()
+		pr.stats.Phases = append(pr.stats.Phases, PhaseStats{
+			Name:       name,
+			Duration:   time.Since(start),
+			Allocs:     ms.Mallocs - allocs,
+			AllocBytes: ms.TotalAlloc - allocBytes,
+		})
+		pr.statsMu.Unlock()
+	}
+}
+
+// generateProofWithStats generates the proof collecting the Stats
+func generateProofWithStats(pr *proofRun, pk *types.Pk, w types.Witness, me multiExp) (*types.Proof, []*big.Int, *Stats, error) {
+	pr.stats = newStats(pk)
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	start := time.Now()
+	allocs, allocBytes := ms.Mallocs, ms.TotalAlloc
+
+	proof, pubSignals, err := generateProof(pr, pk, w, me)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	runtime.ReadMemStats(&ms)
+	pr.stats.Duration = time.Since(start)
+	pr.stats.Allocs = ms.Mallocs - allocs
+	pr.stats.AllocBytes = ms.TotalAlloc - allocBytes
+	return proof, pubSignals, pr.stats, nil
+}
+
+// GenerateProofWithStats generates the Groth16 zkSNARK proof with the given
+// options, and returns the measures of its phases.  Collecting the Stats
+// stops the world to read the memory statistics between the phases.
+func GenerateProofWithStats(ctx context.Context, pk *types.Pk, w types.Witness, opts Options) (*types.Proof, []*big.Int, *Stats, error) {
+	pr := newProofRun(ctx, opts)
+	return generateProofWithStats(pr, pk, w, newPippengerMultiExp(pr, pk))
+}
+
+// GenerateProofWithStats generates the Groth16 zkSNARK proof with the
+// precomputed tables, and returns the measures of its phases
+func (p *Prover) GenerateProofWithStats(ctx context.Context, w types.Witness, opts Options) (*types.Proof, []*big.Int, *Stats, error) {
+	return generateProofWithStats(newProofRun(ctx, opts), p.pk, w, p)
+}
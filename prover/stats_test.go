@@ -0,0 +1,71 @@
+package prover
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/iden3/go-circom-prover-verifier/verifier"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateProofWithStats(t *testing.T) {
+	pk, vk, w := testCircuit(t, 50, 3)
+	nPolsA, nPolsB := 0, 0
+	for i := 0; i < pk.NVars; i++ {
+		constraints, _ := pk.PolsA.Wire(i)
+		nPolsA += len(constraints)
+		constraints, _ = pk.PolsB.Wire(i)
+		nPolsB += len(constraints)
+	}
+
+	expectedPhases := []string{StatsSplit + " W", PhaseA, PhaseB2, PhaseB1, PhaseC, StatsPolEval,
+		StatsIFFT + " A", StatsCosetFFT + " A", StatsIFFT + " B", StatsCosetFFT + " B",
+		StatsIFFT + " C", StatsCosetFFT + " C", StatsPolH, StatsCosetIFFT + " H",
+		StatsSplit + " H", PhaseHExps, StatsAssembly}
+
+	proof, pubSignals, stats, err := GenerateProofWithStats(context.Background(), pk, w, Options{Workers: 1})
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+	assert.Equal(t, pk.NVars, stats.NVars)
+	assert.Equal(t, pk.DomainSize, stats.DomainSize)
+	assert.Equal(t, nPolsA, stats.NPolsA)
+	assert.Equal(t, nPolsB, stats.NPolsB)
+	var phases []string
+	for _, p := range stats.Phases {
+		phases = append(phases, p.Name)
+		assert.True(t, p.Duration <= stats.Duration)
+		assert.True(t, p.Allocs <= stats.Allocs)
+	}
+	assert.Equal(t, expectedPhases, phases)
+	assert.True(t, stats.Phase(PhaseB2).Allocs > 0)
+	assert.Nil(t, stats.Phase("unknown"))
+	assert.Contains(t, stats.String(), StatsCosetFFT+" B")
+
+	// with the Prover tables
+	p := NewProver(pk)
+	proof, pubSignals, stats, err = p.GenerateProofWithStats(context.Background(), w, Options{})
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+	phases = nil
+	for _, p := range stats.Phases {
+		phases = append(phases, p.Name)
+	}
+	// the H phases run at the same time as the others, and the scalars are
+	// not split
+	var tablesPhases []string
+	for _, p := range expectedPhases {
+		if !strings.HasPrefix(p, StatsSplit) {
+			tablesPhases = append(tablesPhases, p)
+		}
+	}
+	assert.ElementsMatch(t, tablesPhases, phases)
+
+	// cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, stats, err = GenerateProofWithStats(ctx, pk, w, Options{})
+	assert.Equal(t, context.Canceled, err)
+	assert.Nil(t, stats)
+}
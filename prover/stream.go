@@ -0,0 +1,229 @@
+package prover
+
+import (
+	"context"
+	"io"
+	"math/big"
+
+	"github.com/iden3/go-circom-prover-verifier/internal/curve"
+	"github.com/iden3/go-circom-prover-verifier/types"
+	"github.com/iden3/go-iden3-crypto/ff"
+)
+
+// streamChunkSize is the number of points read at once by each worker of a
+// StreamProver.  With the endomorphisms of the points and the split scalars,
+// the chunks of a worker take about 4MB in G1 and 12MB in G2.
+const streamChunkSize = 1 << 14
+
+// StreamProver generates proofs for a proving key whose points are read from
+// a file when they are used, in chunks of each worker, instead of being held
+// in memory, for the circuits whose proving key does not fit in memory.  The
+// memory of the points is bounded by the chunks of the workers, and each
+// proof reads all the points of the file.  The points are checked to be on
+// the curve when they are read, but the points of G2 are not checked to be
+// in the subgroup.
+type StreamProver struct {
+	pk     *types.Pk
+	r      io.ReaderAt
+	points types.PkPoints
+	// chunk is the number of points of the chunks
+	chunk int
+}
+
+// NewStreamProver returns the StreamProver of the proving key pk, without
+// its points, whose points are read from r at the offsets of points, as
+// returned by parsers.ParsePkGoBinHeader or parsers.ParseZkeyHeader.  The
+// workers of the proofs read r concurrently, as an *os.File allows.
+func NewStreamProver(pk *types.Pk, r io.ReaderAt, points *types.PkPoints) *StreamProver {
+	return &StreamProver{pk: pk, r: r, points: *points, chunk: streamChunkSize}
+}
+
+// GenerateProof generates the Groth16 zkSNARK proof reading the points of
+// the StreamProver
+func (p *StreamProver) GenerateProof(w types.Witness) (*types.Proof, []*big.Int, error) {
+	return p.GenerateProofWithOptions(context.Background(), w, Options{})
+}
+
+// GenerateProofWithOptions generates the Groth16 zkSNARK proof reading the
+// points of the StreamProver, with the given options.  The errors reading
+// and decoding the points are returned.
+func (p *StreamProver) GenerateProofWithOptions(ctx context.Context, w types.Witness, opts Options) (*types.Proof, []*big.Int, error) {
+	pr := newProofRun(ctx, opts)
+	return generateProof(pr, p.pk, w, p.multiExp(pr))
+}
+
+// GenerateProofWithStats generates the Groth16 zkSNARK proof reading the
+// points of the StreamProver, and returns the measures of its phases
+func (p *StreamProver) GenerateProofWithStats(ctx context.Context, w types.Witness, opts Options) (*types.Proof, []*big.Int, *Stats, error) {
+	pr := newProofRun(ctx, opts)
+	return generateProofWithStats(pr, p.pk, w, p.multiExp(pr))
+}
+
+// GenerateProofs generates the Groth16 zkSNARK proofs of the witnesses
+// reading the points of the StreamProver, like GenerateProofs.  The points
+// are read once for each batch.
+func (p *StreamProver) GenerateProofs(ctx context.Context, ws []types.Witness, opts Options) []BatchResult {
+	pr := newProofRun(ctx, opts)
+	return generateProofBatches(pr, p.pk, ws, p.multiExp(pr))
+}
+
+// streamMultiExp computes the multiplications over the points of the file of
+// a StreamProver with the Pippenger bucket method, with the GLV method over
+// each chunk of points.  The scalars are split for each chunk, so that the
+// split scalars of all the points are not held.
+type streamMultiExp struct {
+	*StreamProver
+	g1, g2 *msmParams
+}
+
+func (p *StreamProver) multiExp(pr *proofRun) streamMultiExp {
+	t := pr.tuning()
+	return streamMultiExp{
+		StreamProver: p,
+		g1:           t.msmParams(false, pr.workers),
+		g2:           t.msmParams(true, pr.workers),
+	}
+}
+
+func (m streamMultiExp) groupSize(g2 bool) int { return 1 }
+
+// glv returns false, as the scalars are split with each chunk
+func (m streamMultiExp) glv() bool { return false }
+
+func (m streamMultiExp) withWorkspace(pr *proofRun, ws *Workspace) multiExp { return m }
+
+func (m streamMultiExp) a(k []*scalars, start, end int, buf *msmBuffers) []curve.G1Jac {
+	return m.msmG1(m.points.A, k, start, end, buf)
+}
+
+func (m streamMultiExp) b1(k []*scalars, start, end int, buf *msmBuffers) []curve.G1Jac {
+	return m.msmG1(m.points.B1, k, start, end, buf)
+}
+
+func (m streamMultiExp) b2(k []*scalars, start, end int, buf *msmBuffers) []curve.G2Jac {
+	return m.msmG2(m.points.B2, k, start, end, buf)
+}
+
+func (m streamMultiExp) c(k []*scalars, start, end int, buf *msmBuffers) []curve.G1Jac {
+	return m.msmG1(m.points.C, k, start, end, buf)
+}
+
+func (m streamMultiExp) hExps(k []*scalars, start, end int, buf *msmBuffers) []curve.G1Jac {
+	return m.msmG1(m.points.HExps, k, start, end, buf)
+}
+
+// read reads the points [start, end) of size bytes of the section at offset
+// into buf.raw
+func (m streamMultiExp) read(offset int64, size, start, end int, buf *msmBuffers) error {
+	n := size * (end - start)
+	if cap(buf.raw) < n {
+		buf.raw = make([]byte, n)
+	}
+	buf.raw = buf.raw[:n]
+	if n, err := m.r.ReadAt(buf.raw, offset+int64(size*start)); n < len(buf.raw) {
+		return err
+	}
+	return nil
+}
+
+// readG1 reads the points [start, end) of the G1 section at offset into
+// buf.a1, followed by their endomorphisms
+func (m streamMultiExp) readG1(offset int64, start, end int, buf *msmBuffers) error {
+	if err := m.read(offset, 64, start, end, buf); err != nil {
+		return err
+	}
+	n := end - start
+	buf.a1 = growG1Affine(buf.a1, 2*n)
+	for i := 0; i < n; i++ {
+		b := buf.raw[64*i : 64*(i+1)]
+		var err error
+		if m.points.Encoding == types.PointsMontLE {
+			err = buf.a1[i].UnmarshalMontLE(b)
+		} else {
+			err = buf.a1[i].Unmarshal(b)
+		}
+		if err != nil {
+			return err
+		}
+		buf.a1[n+i].Endo(&buf.a1[i])
+	}
+	return nil
+}
+
+// G2 version of readG1, with the powers of ψ
+func (m streamMultiExp) readG2(offset int64, start, end int, buf *msmBuffers) error {
+	if err := m.read(offset, 128, start, end, buf); err != nil {
+		return err
+	}
+	n := end - start
+	buf.a2 = growG2Affine(buf.a2, 4*n)
+	for i := 0; i < n; i++ {
+		b := buf.raw[128*i : 128*(i+1)]
+		var err error
+		if m.points.Encoding == types.PointsMontLE {
+			err = buf.a2[i].UnmarshalMontLE(b)
+		} else {
+			err = buf.a2[i].Unmarshal(b)
+		}
+		if err != nil {
+			return err
+		}
+		for j := 1; j < 4; j++ {
+			buf.a2[j*n+i].Psi(&buf.a2[(j-1)*n+i])
+		}
+	}
+	return nil
+}
+
+// msmG1 multiplies the points [start, end) of the G1 section at offset by
+// each of the scalars of a batch, reading them in chunks.  The first error
+// is kept in buf.err, and the following chunks are skipped.
+func (m streamMultiExp) msmG1(offset int64, k []*scalars, start, end int, buf *msmBuffers) []curve.G1Jac {
+	buf.r1 = growG1Jac(buf.r1, len(k))
+	for s := start; s < end && buf.err == nil; s += m.chunk {
+		e := s + m.chunk
+		if e > end {
+			e = end
+		}
+		if buf.err = m.readG1(offset, s, e, buf); buf.err != nil {
+			break
+		}
+		n := e - s
+		buf.s = growElements(buf.s, 2*n)
+		for i := range k {
+			for j := 0; j < n; j++ {
+				buf.s[j], buf.s[n+j] = curve.SplitG1((*[4]uint64)(&k[i].k[s+j]))
+			}
+			q := pippengerG1(buf.a1, buf.s, m.g1, buf)
+			buf.r1[i].Add(&q)
+		}
+	}
+	return buf.r1
+}
+
+// G2 version of msmG1
+func (m streamMultiExp) msmG2(offset int64, k []*scalars, start, end int, buf *msmBuffers) []curve.G2Jac {
+	buf.r2 = growG2Jac(buf.r2, len(k))
+	for s := start; s < end && buf.err == nil; s += m.chunk {
+		e := s + m.chunk
+		if e > end {
+			e = end
+		}
+		if buf.err = m.readG2(offset, s, e, buf); buf.err != nil {
+			break
+		}
+		n := e - s
+		buf.s = growElements(buf.s, 4*n)
+		for i := range k {
+			for j := 0; j < n; j++ {
+				parts := curve.SplitG2((*[4]uint64)(&k[i].k[s+j]))
+				for l := range parts {
+					buf.s[l*n+j] = ff.Element(parts[l])
+				}
+			}
+			q := pippengerG2(buf.a2, buf.s, m.g2, buf)
+			buf.r2[i].Add(&q)
+		}
+	}
+	return buf.r2
+}
@@ -0,0 +1,108 @@
+package prover
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io/ioutil"
+	mrand "math/rand"
+	"os"
+	"testing"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-circom-prover-verifier/internal/curve"
+	"github.com/iden3/go-circom-prover-verifier/parsers"
+	"github.com/iden3/go-circom-prover-verifier/types"
+	"github.com/iden3/go-circom-prover-verifier/verifier"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// putMontLE appends the limbs of the elements in little endian
+func putMontLE(b []byte, e ...curve.Fp) []byte {
+	for i := range e {
+		for _, l := range e[i] {
+			var w [8]byte
+			binary.LittleEndian.PutUint64(w[:], l)
+			b = append(b, w[:]...)
+		}
+	}
+	return b
+}
+
+// pkMontLE returns the points of the proving key with the encoding and the
+// sections of the .zkey files, and their offsets
+func pkMontLE(pk *types.Pk) ([]byte, *types.PkPoints) {
+	points := &types.PkPoints{Encoding: types.PointsMontLE}
+	var b []byte
+	g1 := func(offset *int64, p []*bn256.G1) {
+		*offset = int64(len(b))
+		for i := range p {
+			a := curve.G1FromBn256(p[i])
+			b = putMontLE(b, a.X, a.Y)
+		}
+	}
+	g1(&points.A, pk.A)
+	g1(&points.B1, pk.B1)
+	points.B2 = int64(len(b))
+	for i := range pk.B2 {
+		a := curve.G2FromBn256(pk.B2[i])
+		b = putMontLE(b, a.X.A0, a.X.A1, a.Y.A0, a.Y.A1)
+	}
+	g1(&points.C, pk.C[pk.NPublic+1:])
+	g1(&points.HExps, pk.HExps[:pk.DomainSize])
+	return b, points
+}
+
+func TestStreamProver(t *testing.T) {
+	pk, vk, w := testCircuit(t, 300, 3)
+
+	goBin, err := parsers.PkToGoBin(pk)
+	require.Nil(t, err)
+	f, err := ioutil.TempFile("", "pk.go.bin")
+	require.Nil(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	_, err = f.Write(goBin)
+	require.Nil(t, err)
+	pkHeader, points, err := parsers.ParsePkGoBinHeader(f)
+	require.Nil(t, err)
+	assert.Equal(t, types.PointsBE, points.Encoding)
+	assert.Nil(t, pkHeader.A)
+	pGoBin := NewStreamProver(pkHeader, f, points)
+
+	montLE, points := pkMontLE(pk)
+	pMontLE := NewStreamProver(pk, bytes.NewReader(montLE), points)
+
+	for _, p := range []*StreamProver{pGoBin, pMontLE} {
+		for _, chunk := range []int{streamChunkSize, 7} {
+			p.chunk = chunk
+			for _, workers := range []int{1, 4} {
+				// the same proofs as with the points in memory
+				expected, expectedSignals, err := GenerateProofWithOptions(context.Background(), pk, w, Options{Workers: workers, Rand: mrand.New(mrand.NewSource(1))})
+				require.Nil(t, err)
+				proof, pubSignals, err := p.GenerateProofWithOptions(context.Background(), w, Options{Workers: workers, Rand: mrand.New(mrand.NewSource(1))})
+				require.Nil(t, err)
+				assert.Equal(t, expected, proof)
+				assert.Equal(t, expectedSignals, pubSignals)
+				assert.True(t, verifier.Verify(vk, proof, pubSignals))
+			}
+		}
+
+		res := p.GenerateProofs(context.Background(), []types.Witness{w, w}, Options{})
+		for _, r := range res {
+			require.Nil(t, r.Err)
+			assert.True(t, verifier.Verify(vk, r.Proof, r.PubSignals))
+		}
+	}
+
+	// the errors reading and decoding the points
+	p := NewStreamProver(pk, bytes.NewReader(montLE[:points.HExps+64]), points)
+	_, _, err = p.GenerateProof(w)
+	assert.NotNil(t, err)
+	bad := append([]byte{}, montLE...)
+	bad[points.B2+1] ^= 1
+	p = NewStreamProver(pk, bytes.NewReader(bad), points)
+	_, _, err = p.GenerateProof(w)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,413 @@
+package prover
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"math/bits"
+	"os"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-circom-prover-verifier/internal/curve"
+	"github.com/iden3/go-circom-prover-verifier/types"
+	"github.com/iden3/go-iden3-crypto/ff"
+)
+
+// Prover generates proofs for a proving key using precomputed tables of the
+// points (Strauss-Shamir method without doubling, see tables.md), which are
+// computed once and used for all the proofs.  The tables of each group of
+// gsize points have 1<<gsize points in affine coordinates: the point at the
+// index b is the sum of the points of the group whose bit is set in b.  The
+// group sizes of G1 and G2 are chosen with the Tuning.
+type Prover struct {
+	pk       *types.Pk
+	gsizeG1  int
+	gsizeG2  int
+	tablesA  []curve.G1Affine
+	tablesB1 []curve.G1Affine
+	tablesB2 []curve.G2Affine
+	tablesC  []curve.G1Affine
+	tablesH  []curve.G1Affine
+}
+
+// fillTableG1 computes in t the table of the points a, with mixed additions
+func fillTableG1(t []curve.G1Jac, a []curve.G1Affine) {
+	t[0].SetInfinity()
+	lastPow2, bit := 1, 0
+	for i := 1; i < len(t); i++ {
+		if i&(i-1) == 0 {
+			lastPow2, bit = i, bits.TrailingZeros(uint(i))
+		}
+		t[i] = t[i-lastPow2]
+		if bit < len(a) {
+			t[i].AddMixed(&a[bit])
+		}
+	}
+}
+
+// G2 version of fillTableG1
+func fillTableG2(t []curve.G2Jac, a []curve.G2Affine) {
+	t[0].SetInfinity()
+	lastPow2, bit := 1, 0
+	for i := 1; i < len(t); i++ {
+		if i&(i-1) == 0 {
+			lastPow2, bit = i, bits.TrailingZeros(uint(i))
+		}
+		t[i] = t[i-lastPow2]
+		if bit < len(a) {
+			t[i].AddMixed(&a[bit])
+		}
+	}
+}
+
+// newTablesG1 computes in parallel on the workers of pr the tables of the
+// points, in groups of gsize points
+func newTablesG1(pr *proofRun, a []*bn256.G1, gsize int) []curve.G1Affine {
+	tsize := 1 << uint(gsize)
+	ntables := (len(a) + gsize - 1) / gsize
+	tables := make([]curve.G1Affine, ntables*tsize)
+	pr.run(ntables, func(start, end int) {
+		t := make([]curve.G1Jac, tsize)
+		for i := start; i < end; i++ {
+			e := (i + 1) * gsize
+			if e > len(a) {
+				e = len(a)
+			}
+			fillTableG1(t, curve.G1SliceFromBn256(a[i*gsize:e]))
+			curve.BatchNormalizeG1(tables[i*tsize:(i+1)*tsize], t)
+		}
+	})
+	return tables
+}
+
+// G2 version of newTablesG1
+func newTablesG2(pr *proofRun, a []*bn256.G2, gsize int) []curve.G2Affine {
+	tsize := 1 << uint(gsize)
+	ntables := (len(a) + gsize - 1) / gsize
+	tables := make([]curve.G2Affine, ntables*tsize)
+	pr.run(ntables, func(start, end int) {
+		t := make([]curve.G2Jac, tsize)
+		for i := start; i < end; i++ {
+			e := (i + 1) * gsize
+			if e > len(a) {
+				e = len(a)
+			}
+			fillTableG2(t, curve.G2SliceFromBn256(a[i*gsize:e]))
+			curve.BatchNormalizeG2(tables[i*tsize:(i+1)*tsize], t)
+		}
+	})
+	return tables
+}
+
+// strausG1 multiplies the points of the tables t by the scalars k and adds
+// the results: for each bit position, the table points of the bits of the
+// groups are added with mixed additions, and the sums of the bit positions
+// are combined with doublings at the end.  The sums are kept in buf.
+func strausG1(t []curve.G1Affine, k []ff.Element, gsize int, buf *msmBuffers) curve.G1Jac {
+	tsize := 1 << uint(gsize)
+	buf.q1 = growG1Jac(buf.q1, getMsbE(k))
+	Q := buf.q1
+	for j := 0; j*gsize < len(k); j++ {
+		end := (j + 1) * gsize
+		if end > len(k) {
+			end = len(k)
+		}
+		g := k[j*gsize : end]
+		table := t[j*tsize : (j+1)*tsize]
+		for i := getMsbE(g) - 1; i >= 0; i-- {
+			if b := getBitE(g, i); b != 0 {
+				Q[i].AddMixed(&table[b])
+			}
+		}
+	}
+	var R curve.G1Jac
+	for i := len(Q) - 1; i >= 0; i-- {
+		R.Double(&R)
+		R.Add(&Q[i])
+	}
+	return R
+}
+
+// G2 version of strausG1
+func strausG2(t []curve.G2Affine, k []ff.Element, gsize int, buf *msmBuffers) curve.G2Jac {
+	tsize := 1 << uint(gsize)
+	buf.q2 = growG2Jac(buf.q2, getMsbE(k))
+	Q := buf.q2
+	for j := 0; j*gsize < len(k); j++ {
+		end := (j + 1) * gsize
+		if end > len(k) {
+			end = len(k)
+		}
+		g := k[j*gsize : end]
+		table := t[j*tsize : (j+1)*tsize]
+		for i := getMsbE(g) - 1; i >= 0; i-- {
+			if b := getBitE(g, i); b != 0 {
+				Q[i].AddMixed(&table[b])
+			}
+		}
+	}
+	var R curve.G2Jac
+	for i := len(Q) - 1; i >= 0; i-- {
+		R.Double(&R)
+		R.Add(&Q[i])
+	}
+	return R
+}
+
+// NewProver computes the tables of the points of the proving key and returns
+// the Prover, with the group sizes of DefaultTuning
+func NewProver(pk *types.Pk) *Prover {
+	return NewProverWithTuning(pk, DefaultTuning)
+}
+
+// tablesPoints returns the number of points of the tables in G1 and G2
+func tablesPoints(pk *types.Pk) (int, int) {
+	return 3*pk.NVars - pk.NPublic - 1 + pk.DomainSize, pk.NVars
+}
+
+// NewProverWithTuning computes the tables of the points of the proving key
+// and returns the Prover, with the largest group sizes whose tables fit in
+// the memory of the Tuning
+func NewProverWithTuning(pk *types.Pk, t *Tuning) *Prover {
+	return NewProverWithOptions(pk, Options{Tuning: t})
+}
+
+// NewProverWithOptions computes the tables of the points of the proving key
+// with the Workers and the Pool of the options, and returns the Prover, with
+// the group sizes chosen with the Tuning of the options.  The other options
+// are not used.
+func NewProverWithOptions(pk *types.Pk, opts Options) *Prover {
+	pr := newProofRun(context.Background(), opts)
+	p := &Prover{pk: pk}
+	p.gsizeG1, p.gsizeG2 = pr.tuning().tablesGroupSizes(tablesPoints(pk))
+	p.tablesA = newTablesG1(pr, pk.A, p.gsizeG1)
+	p.tablesB1 = newTablesG1(pr, pk.B1, p.gsizeG1)
+	p.tablesB2 = newTablesG2(pr, pk.B2, p.gsizeG2)
+	p.tablesC = newTablesG1(pr, pk.C[pk.NPublic+1:], p.gsizeG1)
+	p.tablesH = newTablesG1(pr, pk.HExps[:pk.DomainSize], p.gsizeG1)
+	return p
+}
+
+// GenerateProof generates the Groth16 zkSNARK proof using the tables of the
+// Prover
+func (p *Prover) GenerateProof(w types.Witness) (*types.Proof, []*big.Int, error) {
+	return p.GenerateProofWithOptions(context.Background(), w, Options{})
+}
+
+// GenerateProofWithOptions generates the Groth16 zkSNARK proof using the
+// tables of the Prover, with the given options.  The proof generation stops
+// with the context error when the context is done.
+func (p *Prover) GenerateProofWithOptions(ctx context.Context, w types.Witness, opts Options) (*types.Proof, []*big.Int, error) {
+	return generateProof(newProofRun(ctx, opts), p.pk, w, p)
+}
+
+func (p *Prover) groupSize(g2 bool) int {
+	if g2 {
+		return p.gsizeG2
+	}
+	return p.gsizeG1
+}
+
+// glv returns false, as the multiplications with the tables do not double
+// the points, so splitting the scalars would not save additions
+func (p *Prover) glv() bool { return false }
+
+func (p *Prover) withWorkspace(pr *proofRun, ws *Workspace) multiExp { return p }
+
+// tables returns the tables of the points [start, end), which are aligned
+// to the groups of gsize
+func tables(start, end, gsize int) (int, int) {
+	tsize := 1 << uint(gsize)
+	return start / gsize * tsize, (end + gsize - 1) / gsize * tsize
+}
+
+func (p *Prover) a(k []*scalars, start, end int, buf *msmBuffers) []curve.G1Jac {
+	ts, te := tables(start, end, p.gsizeG1)
+	buf.r1 = growG1Jac(buf.r1, len(k))
+	for i := range k {
+		buf.r1[i] = strausG1(p.tablesA[ts:te], k[i].k[start:end], p.gsizeG1, buf)
+	}
+	return buf.r1
+}
+
+func (p *Prover) b1(k []*scalars, start, end int, buf *msmBuffers) []curve.G1Jac {
+	ts, te := tables(start, end, p.gsizeG1)
+	buf.r1 = growG1Jac(buf.r1, len(k))
+	for i := range k {
+		buf.r1[i] = strausG1(p.tablesB1[ts:te], k[i].k[start:end], p.gsizeG1, buf)
+	}
+	return buf.r1
+}
+
+func (p *Prover) b2(k []*scalars, start, end int, buf *msmBuffers) []curve.G2Jac {
+	ts, te := tables(start, end, p.gsizeG2)
+	buf.r2 = growG2Jac(buf.r2, len(k))
+	for i := range k {
+		buf.r2[i] = strausG2(p.tablesB2[ts:te], k[i].k[start:end], p.gsizeG2, buf)
+	}
+	return buf.r2
+}
+
+func (p *Prover) c(k []*scalars, start, end int, buf *msmBuffers) []curve.G1Jac {
+	ts, te := tables(start, end, p.gsizeG1)
+	buf.r1 = growG1Jac(buf.r1, len(k))
+	for i := range k {
+		buf.r1[i] = strausG1(p.tablesC[ts:te], k[i].k[start:end], p.gsizeG1, buf)
+	}
+	return buf.r1
+}
+
+func (p *Prover) hExps(k []*scalars, start, end int, buf *msmBuffers) []curve.G1Jac {
+	ts, te := tables(start, end, p.gsizeG1)
+	buf.r1 = growG1Jac(buf.r1, len(k))
+	for i := range k {
+		buf.r1[i] = strausG1(p.tablesH[ts:te], k[i].k[start:end], p.gsizeG1, buf)
+	}
+	return buf.r1
+}
+
+// tablesHeaderSize is the size of the header of the tables file
+const tablesHeaderSize = 28 + sha256.Size
+
+// tablesHeader returns the header of the tables file, which identifies the
+// proving key of the tables with its sizes and a hash of all its points
+func (p *Prover) tablesHeader() []byte {
+	var b [tablesHeaderSize]byte
+	copy(b[:4], "ptbl")
+	binary.LittleEndian.PutUint32(b[4:8], uint32(p.gsizeG1))
+	binary.LittleEndian.PutUint32(b[8:12], uint32(p.gsizeG2))
+	binary.LittleEndian.PutUint32(b[12:16], uint32(p.pk.NVars))
+	binary.LittleEndian.PutUint32(b[16:20], uint32(p.pk.NPublic))
+	binary.LittleEndian.PutUint32(b[20:24], uint32(p.pk.DomainSize))
+	if p.pk.HExpsCoset {
+		b[24] = 1
+	}
+	copy(b[28:], pointsHash(p.pk))
+	return b[:]
+}
+
+// pointsHash returns the sha256 hash of the points of the proving key.  The
+// points are marshaled over copies, as Marshal normalizes them in place.
+func pointsHash(pk *types.Pk) []byte {
+	h := sha256.New()
+	for _, q := range []*bn256.G1{pk.VkAlpha1, pk.VkBeta1, pk.VkDelta1} {
+		h.Write(new(bn256.G1).Set(q).Marshal())
+	}
+	for _, q := range []*bn256.G2{pk.VkBeta2, pk.VkDelta2} {
+		h.Write(new(bn256.G2).Set(q).Marshal())
+	}
+	for _, points := range [][]*bn256.G1{pk.A, pk.B1, pk.C, pk.HExps} {
+		for _, q := range points {
+			h.Write(new(bn256.G1).Set(q).Marshal())
+		}
+	}
+	for _, q := range pk.B2 {
+		h.Write(new(bn256.G2).Set(q).Marshal())
+	}
+	return h.Sum(nil)
+}
+
+// TablesToBytes converts the tables of the Prover into a binary format that
+// can be loaded with LoadProver.  After a header with the group sizes and
+// the sizes of the proving key, the tables of A, B1, B2, C and HExps follow
+// in that order, each one as the concatenation of its points with the
+// uncompressed bn256 encoding.
+func (p *Prover) TablesToBytes() []byte {
+	r := p.tablesHeader()
+	// the points are encoded without converting them to bn256
+	for _, tables := range [][]curve.G1Affine{p.tablesA, p.tablesB1} {
+		r = marshalTablesG1(r, tables)
+	}
+	for i := range p.tablesB2 {
+		r = append(r, p.tablesB2[i].Marshal()...)
+	}
+	for _, tables := range [][]curve.G1Affine{p.tablesC, p.tablesH} {
+		r = marshalTablesG1(r, tables)
+	}
+	return r
+}
+
+// marshalTablesG1 appends the points of the tables to r, with the
+// uncompressed bn256 encoding
+func marshalTablesG1(r []byte, tables []curve.G1Affine) []byte {
+	for i := range tables {
+		r = append(r, tables[i].Marshal()...)
+	}
+	return r
+}
+
+// unmarshalTablesG1 reads n tables of gsize points
+func unmarshalTablesG1(r io.Reader, n, gsize int) ([]curve.G1Affine, error) {
+	tables := make([]curve.G1Affine, n<<uint(gsize))
+	b := make([]byte, 64)
+	for i := range tables {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		if err := tables[i].Unmarshal(b); err != nil {
+			return nil, err
+		}
+	}
+	return tables, nil
+}
+
+// G2 version of unmarshalTablesG1
+func unmarshalTablesG2(r io.Reader, n, gsize int) ([]curve.G2Affine, error) {
+	tables := make([]curve.G2Affine, n<<uint(gsize))
+	b := make([]byte, 128)
+	for i := range tables {
+		if _, err := io.ReadFull(r, b); err != nil {
+			return nil, err
+		}
+		if err := tables[i].Unmarshal(b); err != nil {
+			return nil, err
+		}
+	}
+	return tables, nil
+}
+
+// LoadProver returns the Prover of the proving key with the tables read from
+// the file generated with TablesToBytes
+func LoadProver(pk *types.Pk, f *os.File) (*Prover, error) {
+	r := bufio.NewReader(f)
+	b := make([]byte, tablesHeaderSize)
+	if _, err := io.ReadFull(r, b); err != nil {
+		return nil, err
+	}
+	p := &Prover{
+		pk:      pk,
+		gsizeG1: int(binary.LittleEndian.Uint32(b[4:8])),
+		gsizeG2: int(binary.LittleEndian.Uint32(b[8:12])),
+	}
+	for _, gsize := range []int{p.gsizeG1, p.gsizeG2} {
+		if gsize < 1 || gsize > maxTablesGroupSize {
+			return nil, fmt.Errorf("Invalid tables group size: %v", gsize)
+		}
+	}
+	if string(p.tablesHeader()) != string(b) {
+		return nil, fmt.Errorf("The tables do not match the proving key")
+	}
+
+	ntables := func(n, gsize int) int { return (n + gsize - 1) / gsize }
+	var err error
+	if p.tablesA, err = unmarshalTablesG1(r, ntables(pk.NVars, p.gsizeG1), p.gsizeG1); err != nil {
+		return nil, err
+	}
+	if p.tablesB1, err = unmarshalTablesG1(r, ntables(pk.NVars, p.gsizeG1), p.gsizeG1); err != nil {
+		return nil, err
+	}
+	if p.tablesB2, err = unmarshalTablesG2(r, ntables(pk.NVars, p.gsizeG2), p.gsizeG2); err != nil {
+		return nil, err
+	}
+	if p.tablesC, err = unmarshalTablesG1(r, ntables(pk.NVars-pk.NPublic-1, p.gsizeG1), p.gsizeG1); err != nil {
+		return nil, err
+	}
+	if p.tablesH, err = unmarshalTablesG1(r, ntables(pk.DomainSize, p.gsizeG1), p.gsizeG1); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
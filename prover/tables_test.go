@@ -0,0 +1,102 @@
+package prover
+
+import (
+	"context"
+	"crypto/rand"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-circom-prover-verifier/internal/testutil"
+	"github.com/iden3/go-circom-prover-verifier/setup"
+	"github.com/iden3/go-circom-prover-verifier/types"
+	"github.com/iden3/go-circom-prover-verifier/verifier"
+	"github.com/iden3/go-iden3-crypto/ff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCircuit returns the keys of the circuit of testutil.Circuit with n+1
+// constraints, and its witness for x
+func testCircuit(t *testing.T, n int, x int64) (*types.Pk, *types.Vk, types.Witness) {
+	cs, w := testutil.Circuit(n, x)
+	pk, vk, err := setup.Setup(cs, rand.Reader)
+	require.Nil(t, err)
+	return pk, vk, w
+}
+
+func TestProverTables(t *testing.T) {
+	pk, vk, w := testCircuit(t, 50, 3)
+
+	p := NewProver(pk)
+	proof, pubSignals, err := p.GenerateProof(w)
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+
+	// store and load the tables
+	f, err := ioutil.TempFile("", "tables*.bin")
+	require.Nil(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	_, err = f.Write(p.TablesToBytes())
+	require.Nil(t, err)
+	_, err = f.Seek(0, 0)
+	require.Nil(t, err)
+	p2, err := LoadProver(pk, f)
+	require.Nil(t, err)
+	assert.Equal(t, p, p2)
+
+	// the same tables computed by a single worker, and by a pool
+	assert.Equal(t, p, NewProverWithOptions(pk, Options{Workers: 1}))
+	assert.Equal(t, p, NewProverWithOptions(pk, Options{Pool: NewWorkerPool(2)}))
+	proof, pubSignals, err = p2.GenerateProof(w)
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+
+	// tables of a different proving key
+	pk2, _, _ := testCircuit(t, 50, 3)
+	_, err = f.Seek(0, 0)
+	require.Nil(t, err)
+	_, err = LoadProver(pk2, f)
+	assert.NotNil(t, err)
+
+	// a proving key that differs in a single point
+	pk3 := *pk
+	pk3.B1 = append([]*bn256.G1{}, pk.B1...)
+	pk3.B1[1] = new(bn256.G1).ScalarBaseMult(big.NewInt(2))
+	_, err = f.Seek(0, 0)
+	require.Nil(t, err)
+	_, err = LoadProver(&pk3, f)
+	assert.NotNil(t, err)
+}
+
+func TestStrausTables(t *testing.T) {
+	buf := new(msmBuffers)
+	pr := newProofRun(context.Background(), Options{})
+	for _, n := range []int{1, 5, 6, 7, 100} {
+		arrayW := randomWitnessArray(n)
+		arrayG1 := randomG1Array(n)
+		arrayG2 := randomG2Array(n)
+		if n > 2 {
+			arrayW[n-1] = new(big.Int).Sub(types.R, big.NewInt(1))
+		}
+		Q1 := new(bn256.G1).ScalarBaseMult(new(big.Int))
+		Q2 := new(bn256.G2).ScalarBaseMult(new(big.Int))
+		for i := 0; i < n; i++ {
+			Q1.Add(Q1, new(bn256.G1).ScalarMult(arrayG1[i], arrayW[i]))
+			Q2.Add(Q2, new(bn256.G2).ScalarMult(arrayG2[i], arrayW[i]))
+		}
+
+		k := scalarsFromBigInts(arrayW)
+		r1 := strausG1(newTablesG1(pr, arrayG1, GSIZE), k, GSIZE, buf)
+		assert.Equal(t, Q1.Marshal(), r1.Bn256().Marshal())
+		r2 := strausG2(newTablesG2(pr, arrayG2, GSIZE), k, GSIZE, buf)
+		assert.Equal(t, Q2.Marshal(), r2.Bn256().Marshal())
+	}
+
+	// zero scalars
+	r := strausG1(newTablesG1(pr, randomG1Array(3), GSIZE), make([]ff.Element, 3), GSIZE, buf)
+	assert.True(t, r.IsInfinity())
+}
@@ -0,0 +1,370 @@
+package prover
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-circom-prover-verifier/internal/curve"
+)
+
+// OpCosts are the costs in nanoseconds of the operations of the
+// multi-scalar multiplications in a group, which choose their window and
+// group sizes
+type OpCosts struct {
+	// MixedAdd is the cost of adding an affine point to a Jacobian point
+	MixedAdd float64
+	// BatchAdd is the cost of adding a point to an affine bucket in a batch
+	// of affine additions, without the shared inversion
+	BatchAdd float64
+	// Inverse is the cost of the inversion shared by a batch
+	Inverse float64
+	// Reduce is the cost of adding a bucket to the running sums
+	Reduce float64
+}
+
+// Tuning contains the costs of the operations of the multi-scalar
+// multiplications measured on a machine, and the memory that they can use.
+// Calibrate measures the costs of the local machine.
+type Tuning struct {
+	G1 OpCosts
+	G2 OpCosts
+	// Memory is the memory in bytes that the buckets of the multi-scalar
+	// multiplications, the tables of the Prover and the witnesses of a batch
+	// can use.  When 0 it is half of the memory available when it is first
+	// needed, read once, and at most 2 GiB, or 1 GiB when the available
+	// memory of the system is unknown.
+	Memory int64
+}
+
+// DefaultTuning is the Tuning used when the Options do not have one, and by
+// NewProver, with the costs measured on an x86-64 server.  It
+// can be replaced at start up with the result of Calibrate.
+var DefaultTuning = &Tuning{
+	G1: OpCosts{MixedAdd: 325, BatchAdd: 240, Inverse: 12000, Reduce: 1000},
+	G2: OpCosts{MixedAdd: 890, BatchAdd: 710, Inverse: 12100, Reduce: 2870},
+}
+
+// defaultMemory is the memory of the Tuning when the available memory of the
+// system is unknown, and maxDefaultMemory is the largest memory taken from
+// the available memory
+const (
+	defaultMemory    = 1 << 30
+	maxDefaultMemory = 2 << 30
+)
+
+var (
+	// systemMemory is the memory of the Tuning when it is not set
+	systemMemory     int64
+	systemMemoryOnce sync.Once
+)
+
+// maxWindow is the largest window of the Pippenger method
+const maxWindow = 20
+
+// maxTablesGroupSize is the largest group size of the tables of the Prover.
+// Computing the tables costs about 2^gsize/gsize additions for each point,
+// so with 12 it costs as much as 16 proofs.
+const maxTablesGroupSize = 12
+
+// the sizes in bytes of a bucket of the Pippenger method and of a point of
+// the tables of each group
+const (
+	bucketSizeG1 = 64 + 96 + 1
+	bucketSizeG2 = 128 + 192 + 1
+	pointSizeG1  = 64
+	pointSizeG2  = 128
+)
+
+// memory returns the memory of the Tuning
+func (t *Tuning) memory() int64 {
+	if t.Memory > 0 {
+		return t.Memory
+	}
+	systemMemoryOnce.Do(func() {
+		systemMemory = defaultMemory
+		if m := availableMemory() / 2; m > 0 {
+			if m > maxDefaultMemory {
+				m = maxDefaultMemory
+			}
+			systemMemory = m
+		}
+	})
+	return systemMemory
+}
+
+// msmParams are the parameters of the Pippenger method in a group
+type msmParams struct {
+	costs OpCosts
+	// maxWindow is the largest window whose buckets fit in the memory for
+	// all the workers
+	maxWindow int
+}
+
+// msmParams returns the parameters of the Pippenger method in G1, or in G2
+// when g2 is true, for the given number of workers
+func (t *Tuning) msmParams(g2 bool, workers int) *msmParams {
+	m := &msmParams{costs: t.G1, maxWindow: 1}
+	bucketSize := int64(bucketSizeG1)
+	if g2 {
+		m.costs, bucketSize = t.G2, bucketSizeG2
+	}
+	mem := t.memory()
+	for c := 2; c <= maxWindow; c++ {
+		if int64(workers)*bucketSize<<uint(c) > mem {
+			break
+		}
+		m.maxWindow = c
+	}
+	return m
+}
+
+// window returns the window size (in bits) of the Pippenger bucket method
+// that minimizes the cost for n points with scalars of nbits bits:
+// ceil(nbits/c) windows with n bucket additions plus 2^c bucket reductions
+// each.  The bucket additions are affine when the windows have enough
+// buckets for batches (see batchAffineSize).
+func (m *msmParams) window(n, nbits int) int {
+	best, bestCost := 1, -1.0
+	for c := 1; c <= m.maxWindow; c++ {
+		nbuckets := 1<<uint(c) - 1
+		add := m.costs.MixedAdd
+		if batch := batchAffineSize(nbuckets); batch > 0 {
+			// the points of the buckets that are already in the batch are
+			// added with mixed additions
+			busy := float64(batch) / float64(2*nbuckets)
+			add = (1-busy)*(m.costs.BatchAdd+m.costs.Inverse/float64(batch)) + busy*m.costs.MixedAdd
+		}
+		windows := float64((nbits + c - 1) / c)
+		cost := windows * (float64(n)*add + float64(nbuckets)*m.costs.Reduce)
+		if bestCost < 0 || cost < bestCost {
+			best, bestCost = c, cost
+		}
+	}
+	return best
+}
+
+// tablesGroupSizes returns the group sizes of the tables of the Prover in G1
+// and G2 for n1 and n2 points.  Starting from 1, the group size that saves
+// more time per byte of the tables is increased while the tables fit in the
+// memory: the additions of a proof are proportional to n/gsize, and the
+// size of the tables to n/gsize·2^gsize.
+func (t *Tuning) tablesGroupSizes(n1, n2 int) (int, int) {
+	mem := t.memory()
+	size := func(n, gsize int, pointSize int64) int64 {
+		return int64((n+gsize-1)/gsize) << uint(gsize) * pointSize
+	}
+	adds := func(n, gsize int, cost float64) float64 {
+		return float64(n) / float64(gsize) * cost
+	}
+	g1, g2 := 1, 1
+	for {
+		used := size(n1, g1, pointSizeG1) + size(n2, g2, pointSizeG2)
+		best, bestGain := 0, 0.0
+		if g1 < maxTablesGroupSize {
+			extra := size(n1, g1+1, pointSizeG1) - size(n1, g1, pointSizeG1)
+			if used+extra <= mem {
+				gain := (adds(n1, g1, t.G1.MixedAdd) - adds(n1, g1+1, t.G1.MixedAdd)) / float64(extra+1)
+				best, bestGain = 1, gain
+			}
+		}
+		if g2 < maxTablesGroupSize {
+			extra := size(n2, g2+1, pointSizeG2) - size(n2, g2, pointSizeG2)
+			if used+extra <= mem {
+				gain := (adds(n2, g2, t.G2.MixedAdd) - adds(n2, g2+1, t.G2.MixedAdd)) / float64(extra+1)
+				if best == 0 || gain > bestGain {
+					best = 2
+				}
+			}
+		}
+		switch best {
+		case 1:
+			g1++
+		case 2:
+			g2++
+		default:
+			return g1, g2
+		}
+	}
+}
+
+// calibrationPoints is the number of points of the measures of Calibrate
+const calibrationPoints = 4096
+
+// measureOp returns the time in nanoseconds of each of the n operations of
+// f, as the minimum of several runs
+func measureOp(n int, f func()) float64 {
+	best := time.Duration(-1)
+	for i := 0; i < 5; i++ {
+		start := time.Now()
+		f()
+		if d := time.Since(start); best < 0 || d < best {
+			best = d
+		}
+	}
+	return float64(best.Nanoseconds()) / float64(n)
+}
+
+// calibrationPointsG1 returns the multiples of the generator
+func calibrationPointsG1() []curve.G1Affine {
+	g := curve.G1FromBn256(new(bn256.G1).ScalarBaseMult(big.NewInt(1)))
+	jac := make([]curve.G1Jac, calibrationPoints)
+	var p curve.G1Jac
+	for i := range jac {
+		p.AddMixed(&g)
+		jac[i] = p
+	}
+	a := make([]curve.G1Affine, len(jac))
+	curve.BatchNormalizeG1(a, jac)
+	return a
+}
+
+// G2 version of calibrationPointsG1
+func calibrationPointsG2() []curve.G2Affine {
+	g := curve.G2FromBn256(new(bn256.G2).ScalarBaseMult(big.NewInt(1)))
+	jac := make([]curve.G2Jac, calibrationPoints)
+	var p curve.G2Jac
+	for i := range jac {
+		p.AddMixed(&g)
+		jac[i] = p
+	}
+	a := make([]curve.G2Affine, len(jac))
+	curve.BatchNormalizeG2(a, jac)
+	return a
+}
+
+// Calibrate measures the costs of the operations of the multi-scalar
+// multiplications on the local machine, which takes a fraction of a second.
+// The Memory of the result is 0.
+func Calibrate() *Tuning {
+	var t Tuning
+	const nbuckets = 1024
+	const batch = nbuckets / 4
+
+	a1 := calibrationPointsG1()
+	bk1 := newBucketsG1(nbuckets, 0)
+	t.G1.MixedAdd = measureOp(len(a1), func() {
+		bk1.reset()
+		for i := range a1 {
+			bk1.add(i%nbuckets, &a1[i])
+		}
+	})
+	bk1 = newBucketsG1(nbuckets, batch)
+	// the buckets are filled first, as the additions to empty buckets do
+	// not need the batch
+	for i := 0; i < nbuckets; i++ {
+		bk1.add(i, &a1[i])
+	}
+	t.G1.BatchAdd = measureOp(len(a1), func() {
+		for i := range a1 {
+			bk1.add(i%nbuckets, &a1[i])
+		}
+		bk1.flush()
+	})
+	var e curve.Fp
+	e.SetOne()
+	e.Add(&e, &e)
+	t.G1.Inverse = measureOp(100, func() {
+		for i := 0; i < 100; i++ {
+			e.Inverse(&e)
+		}
+	})
+	t.G1.BatchAdd -= t.G1.Inverse / batch
+	t.G1.Reduce = measureOp(nbuckets, func() { bk1.reduce() })
+
+	a2 := calibrationPointsG2()
+	bk2 := newBucketsG2(nbuckets, 0)
+	t.G2.MixedAdd = measureOp(len(a2), func() {
+		bk2.reset()
+		for i := range a2 {
+			bk2.add(i%nbuckets, &a2[i])
+		}
+	})
+	bk2 = newBucketsG2(nbuckets, batch)
+	for i := 0; i < nbuckets; i++ {
+		bk2.add(i, &a2[i])
+	}
+	t.G2.BatchAdd = measureOp(len(a2), func() {
+		for i := range a2 {
+			bk2.add(i%nbuckets, &a2[i])
+		}
+		bk2.flush()
+	})
+	var e2 curve.Fp2
+	e2.SetOne()
+	e2.Add(&e2, &e2)
+	t.G2.Inverse = measureOp(100, func() {
+		for i := 0; i < 100; i++ {
+			e2.Inverse(&e2)
+		}
+	})
+	t.G2.BatchAdd -= t.G2.Inverse / batch
+	t.G2.Reduce = measureOp(nbuckets, func() { bk2.reduce() })
+	return &t
+}
+
+// valid returns an error when the costs are not positive
+func (t *Tuning) valid() error {
+	for _, c := range []OpCosts{t.G1, t.G2} {
+		for _, v := range []float64{c.MixedAdd, c.BatchAdd, c.Inverse, c.Reduce} {
+			if !(v > 0) {
+				return fmt.Errorf("Invalid tuning cost: %v", v)
+			}
+		}
+	}
+	if t.Memory < 0 {
+		return fmt.Errorf("Invalid tuning memory: %v", t.Memory)
+	}
+	return nil
+}
+
+// Save writes the Tuning in JSON
+func (t *Tuning) Save(w io.Writer) error {
+	b, err := json.MarshalIndent(t, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// LoadTuning reads a Tuning written with Save
+func LoadTuning(r io.Reader) (*Tuning, error) {
+	var t Tuning
+	if err := json.NewDecoder(r).Decode(&t); err != nil {
+		return nil, err
+	}
+	if err := t.valid(); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// LoadOrCalibrate reads the Tuning stored in the file at path, or calibrates
+// the local machine and stores the result in a new file at path when it
+// does not exist, so that the machine is calibrated once
+func LoadOrCalibrate(path string) (*Tuning, error) {
+	f, err := os.Open(path)
+	if err == nil {
+		defer f.Close()
+		return LoadTuning(f)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+	t := Calibrate()
+	f, err = os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err = t.Save(f); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return t, f.Close()
+}
@@ -0,0 +1,98 @@
+package prover
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iden3/go-circom-prover-verifier/verifier"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTuningWindow(t *testing.T) {
+	m := DefaultTuning.msmParams(false, 1)
+	prev := 0
+	for _, n := range []int{1, 10, 100, 1000, 10000, 100000} {
+		c := m.window(n, 254)
+		assert.True(t, c >= prev, "n: %d", n)
+		prev = c
+	}
+	assert.True(t, prev > 10)
+
+	// the buckets of all the workers fit in the memory
+	tu := *DefaultTuning
+	tu.Memory = 64 << 20
+	m = tu.msmParams(true, 64)
+	assert.True(t, int64(64*bucketSizeG2)<<uint(m.maxWindow) <= tu.Memory)
+	assert.True(t, m.window(1<<20, 254) <= m.maxWindow)
+	tu.Memory = 1
+	assert.Equal(t, 1, tu.msmParams(false, 1).window(1<<20, 254))
+}
+
+func TestTuningMemory(t *testing.T) {
+	// the memory of the system is read once, and capped
+	var tu Tuning
+	m := tu.memory()
+	assert.True(t, m > 0 && m <= maxDefaultMemory)
+	assert.Equal(t, m, tu.memory())
+	tu.Memory = 5
+	assert.Equal(t, int64(5), tu.memory())
+}
+
+func TestTuningTablesGroupSizes(t *testing.T) {
+	tu := *DefaultTuning
+	for _, mem := range []int64{1 << 20, 32 << 20, 1 << 30} {
+		tu.Memory = mem
+		g1, g2 := tu.tablesGroupSizes(40000, 10000)
+		size := int64((40000+g1-1)/g1)<<uint(g1)*pointSizeG1 + int64((10000+g2-1)/g2)<<uint(g2)*pointSizeG2
+		assert.True(t, size <= mem || (g1 == 1 && g2 == 1), "mem: %d", mem)
+		assert.True(t, g1 >= 1 && g1 <= maxTablesGroupSize)
+		assert.True(t, g2 >= 1 && g2 <= maxTablesGroupSize)
+	}
+	tu.Memory = 1 << 40
+	g1, g2 := tu.tablesGroupSizes(40000, 10000)
+	assert.Equal(t, maxTablesGroupSize, g1)
+	assert.Equal(t, maxTablesGroupSize, g2)
+}
+
+func TestTuningProver(t *testing.T) {
+	pk, vk, w := testCircuit(t, 50, 3)
+	tu := *DefaultTuning
+	tu.Memory = 48 << 10
+	p := NewProverWithTuning(pk, &tu)
+	assert.NotEqual(t, p.groupSize(false), p.groupSize(true))
+	proof, pubSignals, err := p.GenerateProof(w)
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+
+	proof, pubSignals, err = GenerateProofWithOptions(context.Background(), pk, w, Options{Tuning: &tu})
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+}
+
+func TestCalibrate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tuning")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tuning.json")
+
+	tu, err := LoadOrCalibrate(path)
+	require.Nil(t, err)
+	assert.Nil(t, tu.valid())
+	tu2, err := LoadOrCalibrate(path)
+	require.Nil(t, err)
+	assert.Equal(t, tu, tu2)
+
+	var b bytes.Buffer
+	require.Nil(t, tu.Save(&b))
+	tu2, err = LoadTuning(&b)
+	require.Nil(t, err)
+	assert.Equal(t, tu, tu2)
+
+	_, err = LoadTuning(bytes.NewBufferString(`{"G1": {"MixedAdd": 1}}`))
+	assert.NotNil(t, err)
+}
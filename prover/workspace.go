@@ -0,0 +1,253 @@
+package prover
+
+import (
+	"fmt"
+	"sync"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-circom-prover-verifier/internal/curve"
+	"github.com/iden3/go-circom-prover-verifier/types"
+	"github.com/iden3/go-iden3-crypto/ff"
+)
+
+// Workspace owns the intermediate buffers of the proofs of the proving keys
+// of a domain size: the witnesses as field elements, the split scalars, the
+// evaluations of the polynomials of H, and the points, buckets and sums of
+// the multi-scalar multiplications of each worker.  It also caches the
+// points of the last proving key in affine coordinates.  The proofs that are
+// given the same Workspace in the Options reuse its buffers, so that after
+// the first proof of a proving key they allocate a small number of objects
+// that does not grow with the key.  The buffers grow with the number of
+// variables, workers and witnesses of the proofs, and are kept while the
+// Workspace is referenced.
+//
+// The points are cached by the pointer of the proving key: a proving key
+// must not be modified while it is used with a Workspace.
+//
+// A Workspace is used by one proof at a time: the proofs that share it
+// wait for each other.
+type Workspace struct {
+	mu         sync.Mutex
+	domainSize int
+	// items are the buffers of each witness of a batch
+	items []*itemBuffers
+	// msmW and msmH are the buffers of each worker of the MSMs of A, B and
+	// C, and of the MSM of the HExps, which run at the same time
+	msmW, msmH []*msmBuffers
+	// points are the points of the last proving key of the proofs
+	points keyPoints
+}
+
+// NewWorkspace returns a Workspace for the proofs of the proving keys with
+// the given domain size.  The buffers are allocated by the first proof.
+func NewWorkspace(domainSize int) *Workspace {
+	return &Workspace{domainSize: domainSize}
+}
+
+// DomainSize returns the domain size of the proving keys of the Workspace
+func (ws *Workspace) DomainSize() int {
+	return ws.domainSize
+}
+
+// lock waits for the Workspace, and checks that it is for domainSize
+func (ws *Workspace) lock(domainSize int) error {
+	if ws.domainSize != domainSize {
+		return fmt.Errorf("Unexpected workspace domain size, expected: %v, actual: %v", domainSize, ws.domainSize)
+	}
+	ws.mu.Lock()
+	return nil
+}
+
+func (ws *Workspace) unlock() {
+	ws.mu.Unlock()
+}
+
+// item returns the buffers of the witness i of a batch
+func (ws *Workspace) item(i int) *itemBuffers {
+	for len(ws.items) <= i {
+		ws.items = append(ws.items, new(itemBuffers))
+	}
+	return ws.items[i]
+}
+
+// msm returns the buffers of the workers of the MSMs of the HExps when h is
+// true, or of the MSMs of A, B and C
+func (ws *Workspace) msm(h bool, workers int) []*msmBuffers {
+	bufs := &ws.msmW
+	if h {
+		bufs = &ws.msmH
+	}
+	for len(*bufs) < workers {
+		*bufs = append(*bufs, new(msmBuffers))
+	}
+	return *bufs
+}
+
+// keyPoints are the points of the MSMs of a proving key in affine
+// coordinates, C without the points of the public signals
+type keyPoints struct {
+	pk              *types.Pk
+	a, b1, c, hExps []curve.G1Affine
+	b2              []curve.G2Affine
+}
+
+// keyPoints returns the points of pk in affine coordinates, converted by the
+// first proof of pk with the Workspace
+func (ws *Workspace) keyPoints(pr *proofRun, pk *types.Pk) *keyPoints {
+	kp := &ws.points
+	if kp.pk == pk {
+		return kp
+	}
+	kp.a = convertG1(pr, kp.a, pk.A)
+	kp.b1 = convertG1(pr, kp.b1, pk.B1)
+	kp.c = convertG1(pr, kp.c, pk.C[pk.NPublic+1:])
+	kp.hExps = convertG1(pr, kp.hExps, pk.HExps)
+	kp.b2 = growG2Affine(kp.b2, len(pk.B2))
+	pr.run(len(pk.B2), func(start, end int) {
+		for i := start; i < end; i++ {
+			kp.b2[i] = curve.G2FromBn256(pk.B2[i])
+		}
+	})
+	kp.pk = pk
+	return kp
+}
+
+// convertG1 returns the points p in affine coordinates, in the memory of a
+// when it is large enough
+func convertG1(pr *proofRun, a []curve.G1Affine, p []*bn256.G1) []curve.G1Affine {
+	a = growG1Affine(a, len(p))
+	pr.run(len(p), func(start, end int) {
+		for i := start; i < end; i++ {
+			a[i] = curve.G1FromBn256(p[i])
+		}
+	})
+	return a
+}
+
+// itemBuffers are the buffers of the proof of a witness
+type itemBuffers struct {
+	// the witness in Montgomery and regular form
+	wE, wK []ff.Element
+	// the scalars of the witness and of H, split for the GLV method
+	wS, hS scalars
+	// the evaluations of the polynomials A, B and C, where H is computed
+	polA, polB, polC []ff.Element
+}
+
+// msmBuffers are the buffers of a worker of the multi-scalar
+// multiplications.  The zero value is ready to use.
+type msmBuffers struct {
+	// the points of a range converted to affine coordinates, and with their
+	// endomorphisms, and their scalars
+	p1, a1 []curve.G1Affine
+	p2, a2 []curve.G2Affine
+	s      []ff.Element
+	// the indexes of the scalars of msmScalars
+	idx, ones []int
+	bk1       *bucketsG1
+	bk2       *bucketsG2
+	// the sums of the bit positions of the Straus method
+	q1 []curve.G1Jac
+	q2 []curve.G2Jac
+	// the scalars of the range of each witness
+	k  []scalars
+	kp []*scalars
+	// the results of a range for each witness, and their sums over the
+	// ranges of the worker
+	r1, sum1 []curve.G1Jac
+	r2, sum2 []curve.G2Jac
+	// the points read from a file, and the error of reading them
+	raw []byte
+	err error
+}
+
+// bucketsG1 returns the buckets of the buffers with n buckets and batches
+// of batch additions
+func (b *msmBuffers) bucketsG1(n, batch int) *bucketsG1 {
+	if b.bk1 == nil {
+		b.bk1 = new(bucketsG1)
+	}
+	b.bk1.resize(n, batch)
+	return b.bk1
+}
+
+// G2 version of bucketsG1
+func (b *msmBuffers) bucketsG2(n, batch int) *bucketsG2 {
+	if b.bk2 == nil {
+		b.bk2 = new(bucketsG2)
+	}
+	b.bk2.resize(n, batch)
+	return b.bk2
+}
+
+// sliceScalars returns the scalars [start, end) of each of the scalars of a
+// batch
+func (b *msmBuffers) sliceScalars(k []*scalars, start, end int) []*scalars {
+	if cap(b.k) < len(k) {
+		b.k = make([]scalars, len(k))
+		b.kp = make([]*scalars, len(k))
+	}
+	b.k, b.kp = b.k[:len(k)], b.kp[:len(k)]
+	for i := range k {
+		b.k[i] = k[i].slice(start, end)
+		b.kp[i] = &b.k[i]
+	}
+	return b.kp
+}
+
+// growElements returns e with length n, reusing its memory when it is large
+// enough.  The elements are not cleared.
+func growElements(e []ff.Element, n int) []ff.Element {
+	if cap(e) < n {
+		return make([]ff.Element, n)
+	}
+	return e[:n]
+}
+
+// clearElements sets the elements to zero
+func clearElements(e []ff.Element) {
+	for i := range e {
+		e[i].SetZero()
+	}
+}
+
+// growG1Affine is growElements for G1 points
+func growG1Affine(a []curve.G1Affine, n int) []curve.G1Affine {
+	if cap(a) < n {
+		return make([]curve.G1Affine, n)
+	}
+	return a[:n]
+}
+
+// G2 version of growG1Affine
+func growG2Affine(a []curve.G2Affine, n int) []curve.G2Affine {
+	if cap(a) < n {
+		return make([]curve.G2Affine, n)
+	}
+	return a[:n]
+}
+
+// growG1Jac returns p with length n, reusing its memory when it is large
+// enough, with all the points at infinity
+func growG1Jac(p []curve.G1Jac, n int) []curve.G1Jac {
+	if cap(p) < n {
+		return make([]curve.G1Jac, n)
+	}
+	p = p[:n]
+	for i := range p {
+		p[i].SetInfinity()
+	}
+	return p
+}
+
+// G2 version of growG1Jac
+func growG2Jac(p []curve.G2Jac, n int) []curve.G2Jac {
+	if cap(p) < n {
+		return make([]curve.G2Jac, n)
+	}
+	p = p[:n]
+	for i := range p {
+		p[i].SetInfinity()
+	}
+	return p
+}
@@ -0,0 +1,103 @@
+package prover
+
+import (
+	"context"
+	mrand "math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/iden3/go-circom-prover-verifier/types"
+	"github.com/iden3/go-circom-prover-verifier/verifier"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// allocs returns the number and the size of the heap objects allocated by f
+func allocs(f func()) (uint64, uint64) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	n, size := ms.Mallocs, ms.TotalAlloc
+	f()
+	runtime.ReadMemStats(&ms)
+	return ms.Mallocs - n, ms.TotalAlloc - size
+}
+
+func TestWorkspace(t *testing.T) {
+	pk, vk, w := testCircuit(t, 500, 3)
+	p := NewProver(pk)
+	for _, tc := range []struct {
+		gen func(opts Options) (*types.Proof, error)
+		// the witness buffers per variable: the witness in Montgomery and
+		// regular form, and the scalars split for G1 and G2 without the
+		// tables of a Prover
+		wBuffers int
+	}{
+		{
+			gen: func(opts Options) (*types.Proof, error) {
+				proof, _, err := GenerateProofWithOptions(context.Background(), pk, w, opts)
+				return proof, err
+			},
+			wBuffers: 8,
+		},
+		{
+			gen: func(opts Options) (*types.Proof, error) {
+				proof, _, err := p.GenerateProofWithOptions(context.Background(), w, opts)
+				return proof, err
+			},
+			wBuffers: 2,
+		},
+	} {
+		gen := tc.gen
+		ws := NewWorkspace(pk.DomainSize)
+		for _, workers := range []int{1, 4} {
+			// the same proofs as without the Workspace
+			expected, err := gen(Options{Workers: workers, Rand: mrand.New(mrand.NewSource(1))})
+			require.Nil(t, err)
+			for i := 0; i < 2; i++ {
+				proof, err := gen(Options{Workers: workers, Rand: mrand.New(mrand.NewSource(1)), Workspace: ws})
+				require.Nil(t, err)
+				assert.Equal(t, expected, proof)
+				assert.True(t, verifier.Verify(vk, proof, w[1:3]))
+			}
+		}
+
+		// after the first proof, the proofs with the Workspace do not
+		// allocate the buffers of the witness and of H
+		var err error
+		_, size := allocs(func() { _, err = gen(Options{Workers: 1, Workspace: ws}) })
+		require.Nil(t, err)
+		_, sizeNoWs := allocs(func() { _, err = gen(Options{Workers: 1}) })
+		require.Nil(t, err)
+		buffers := uint64(32 * (tc.wBuffers*pk.NVars + 5*pk.DomainSize))
+		assert.True(t, size+buffers < sizeNoWs)
+	}
+
+	// a Workspace of another domain size
+	_, _, err := GenerateProofWithOptions(context.Background(), pk, w, Options{Workspace: NewWorkspace(2 * pk.DomainSize)})
+	assert.NotNil(t, err)
+}
+
+func TestWorkspaceAllocs(t *testing.T) {
+	pk, vk, w := testCircuit(t, 2000, 3)
+	ws := NewWorkspace(pk.DomainSize)
+	opts := Options{Workers: 1, Workspace: ws}
+	proof, pubSignals, err := GenerateProofWithOptions(context.Background(), pk, w, opts)
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+
+	// the points of the proving key are converted by the first proof, so
+	// the following ones allocate a number of objects that does not grow
+	// with the key
+	n, _ := allocs(func() { _, _, err = GenerateProofWithOptions(context.Background(), pk, w, opts) })
+	require.Nil(t, err)
+	assert.True(t, n < uint64(pk.NVars)/4, "%v allocations", n)
+
+	// the points of another proving key replace them
+	pk2, vk2, _ := testCircuit(t, 2000, 3)
+	proof, pubSignals, err = GenerateProofWithOptions(context.Background(), pk2, w, opts)
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk2, proof, pubSignals))
+	proof, pubSignals, err = GenerateProofWithOptions(context.Background(), pk, w, opts)
+	require.Nil(t, err)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+}
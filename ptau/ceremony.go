@@ -0,0 +1,441 @@
+package ptau
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"sync"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-circom-prover-verifier/internal/curve"
+	"github.com/iden3/go-circom-prover-verifier/internal/mpc"
+	"github.com/iden3/go-iden3-crypto/ff"
+)
+
+// Personalization bytes used to derive the G2SP point of each secret
+const (
+	personalizationTau   = 0
+	personalizationAlpha = 1
+	personalizationBeta  = 2
+)
+
+// maxPower is the maximum power of the bn256 FFT domain supported by the
+// ceremony
+const maxPower = 28
+
+// NewPtau starts a new ceremony for 2^power constraints. All the points of
+// the accumulator are the generators, which corresponds to tau = alpha = beta
+// = 1, so at least one contribution is needed before using it.
+func NewPtau(power int) (*Ptau, error) {
+	if power < 1 || power > maxPower {
+		return nil, fmt.Errorf("Invalid power, expected: [1, %v], actual: %v", maxPower, power)
+	}
+	n := 1 << power
+	p := Ptau{
+		Power:         power,
+		CeremonyPower: power,
+		TauG1:         make([]*bn256.G1, 2*n-1),
+		TauG2:         make([]*bn256.G2, n),
+		AlphaTauG1:    make([]*bn256.G1, n),
+		BetaTauG1:     make([]*bn256.G1, n),
+		BetaG2:        g2Gen(),
+	}
+	for i := range p.TauG1 {
+		p.TauG1[i] = g1Gen()
+	}
+	for i := 0; i < n; i++ {
+		p.TauG2[i] = g2Gen()
+		p.AlphaTauG1[i] = g1Gen()
+		p.BetaTauG1[i] = g1Gen()
+	}
+	return &p, nil
+}
+
+func g1Gen() *bn256.G1 {
+	return new(bn256.G1).ScalarBaseMult(big.NewInt(1))
+}
+
+func g2Gen() *bn256.G2 {
+	return new(bn256.G2).ScalarBaseMult(big.NewInt(1))
+}
+
+// currentChallenge returns the challenge that the next contribution must
+// answer
+func currentChallenge(p *Ptau) []byte {
+	if len(p.Contributions) == 0 {
+		return firstChallenge(p.CeremonyPower)
+	}
+	return p.Contributions[len(p.Contributions)-1].NextChallenge
+}
+
+// newPublicKey returns the public key that proves the knowledge of x, with
+// G1S sampled from rng
+func newPublicKey(rng *mpc.ChaCha, x *ff.Element, challenge []byte, personalization byte) PublicKey {
+	var pk PublicKey
+	xBig := x.ToBigIntRegular(new(big.Int))
+	pk.G1S = mpc.G1FromRng(rng)
+	pk.G1SX = new(bn256.G1).ScalarMult(pk.G1S, xBig)
+	pk.G2SPX = new(bn256.G2).ScalarMult(g2sp(personalization, challenge, pk.G1S, pk.G1SX), xBig)
+	return pk
+}
+
+// newKey samples the secrets tau, alpha and beta of a contribution from rng
+// and returns them with their public keys, in the order of snarkjs
+func newKey(rng *mpc.ChaCha, challenge []byte) (tau, alpha, beta *ff.Element, key ContributionKey) {
+	tau = mpc.FrFromRng(rng)
+	alpha = mpc.FrFromRng(rng)
+	beta = mpc.FrFromRng(rng)
+	key.Tau = newPublicKey(rng, tau, challenge, personalizationTau)
+	key.Alpha = newPublicKey(rng, alpha, challenge, personalizationAlpha)
+	key.Beta = newPublicKey(rng, beta, challenge, personalizationBeta)
+	return tau, alpha, beta, key
+}
+
+// scaleBatch is the number of points of G1 scaled at once by scaleG1, which
+// are normalized to affine coordinates with a single inversion
+const scaleBatch = 1 << 10
+
+// scaleG1 multiplies each point i by k * t^i.  The points are multiplied in
+// Jacobian coordinates with the scalars split like in the prover (GLV), and
+// normalized in batches, so that they are affine when they are hashed and
+// written.
+func scaleG1(points []*bn256.G1, t, k *ff.Element) {
+	mpc.Parallel(len(points), func(start, end int) {
+		e := ff.NewElement().Exp(*t, uint64(start))
+		e.Mul(e, k)
+		jac := make([]curve.G1Jac, scaleBatch)
+		aff := make([]curve.G1Affine, scaleBatch)
+		for ; start < end; start += scaleBatch {
+			n := end - start
+			if n > scaleBatch {
+				n = scaleBatch
+			}
+			for i := 0; i < n; i++ {
+				a := curve.G1FromBn256(points[start+i])
+				s := *e
+				s.FromMont()
+				jac[i].ScalarMulGLV(&a, (*[4]uint64)(&s))
+				e.Mul(e, t)
+			}
+			curve.BatchNormalizeG1(aff[:n], jac[:n])
+			for i := 0; i < n; i++ {
+				points[start+i] = aff[i].Bn256()
+			}
+		}
+	})
+}
+
+// scaleG2 multiplies each point i by k * t^i.  Unlike scaleG1 it uses the
+// bn256 multiplication: the conversion of a point back to bn256 checks that
+// it is in the subgroup with a multiplication by the order, which costs more
+// than what the split scalars save.
+func scaleG2(points []*bn256.G2, t, k *ff.Element) {
+	mpc.Parallel(len(points), func(start, end int) {
+		e := ff.NewElement().Exp(*t, uint64(start))
+		e.Mul(e, k)
+		for i := start; i < end; i++ {
+			points[i] = new(bn256.G2).ScalarMult(points[i], e.ToBigIntRegular(new(big.Int)))
+			e.Mul(e, t)
+		}
+	})
+}
+
+// contribute updates the accumulator with the secrets tau, alpha and beta
+// (sampled from rng) and appends the contribution record to the ceremony
+func contribute(p *Ptau, c *Contribution, rng *mpc.ChaCha) error {
+	if p.Power != p.CeremonyPower {
+		return fmt.Errorf("Cannot contribute to a reduced ptau, power: %v, ceremony power: %v", p.Power, p.CeremonyPower)
+	}
+	challenge := currentChallenge(p)
+	var tau, alpha, beta *ff.Element
+	tau, alpha, beta, c.Key = newKey(rng, challenge)
+
+	one := ff.NewElement().SetOne()
+	scaleG1(p.TauG1, tau, one)
+	scaleG2(p.TauG2, tau, one)
+	scaleG1(p.AlphaTauG1, tau, alpha)
+	scaleG1(p.BetaTauG1, tau, beta)
+	p.BetaG2 = new(bn256.G2).ScalarMult(p.BetaG2, beta.ToBigIntRegular(new(big.Int)))
+
+	c.TauG1 = new(bn256.G1).Set(p.TauG1[1])
+	c.TauG2 = new(bn256.G2).Set(p.TauG2[1])
+	c.AlphaG1 = new(bn256.G1).Set(p.AlphaTauG1[0])
+	c.BetaG1 = new(bn256.G1).Set(p.BetaTauG1[0])
+	c.BetaG2 = new(bn256.G2).Set(p.BetaG2)
+
+	h := newHasher()
+	h.Write(challenge)
+	writeAccumulator(h, p)
+	var err error
+	if c.PartialHash, err = partialHash(h); err != nil {
+		return err
+	}
+	h.Write(c.Key.uncompressed())
+	c.NextChallenge = nextChallenge(h.Sum(nil), p)
+	p.Contributions = append(p.Contributions, c)
+	return nil
+}
+
+// Contribute adds a contribution to the ceremony, using secrets sampled from
+// a generator seeded with 32 bytes of rnd (crypto/rand.Reader when nil). The secrets are discarded once the
+// accumulator is updated.
+func Contribute(p *Ptau, name string, rnd io.Reader) error {
+	if rnd == nil {
+		rnd = rand.Reader
+	}
+	seed := make([]byte, 32)
+	if _, err := io.ReadFull(rnd, seed); err != nil {
+		return err
+	}
+	c := Contribution{
+		Type: ContributionTypeRandom,
+		Name: name,
+	}
+	return contribute(p, &c, mpc.NewChaCha(seed))
+}
+
+// beaconSeed hashes the beacon 2^numIterationsExp times with sha256
+func beaconSeed(beaconHash []byte, numIterationsExp int) []byte {
+	seed := append([]byte{}, beaconHash...)
+	for i := 0; i < 1<<numIterationsExp; i++ {
+		h := sha256.Sum256(seed)
+		seed = h[:]
+	}
+	return seed
+}
+
+// Beacon adds a contribution to the ceremony with the secrets derived from a
+// public random beacon, hashed 2^numIterationsExp times. Anyone can verify
+// the beacon contribution by recomputing it.
+func Beacon(p *Ptau, name string, beaconHash []byte, numIterationsExp int) error {
+	if len(beaconHash) == 0 || len(beaconHash) > 255 {
+		return fmt.Errorf("Invalid beacon hash length: %v", len(beaconHash))
+	}
+	if numIterationsExp < 10 || numIterationsExp > 63 {
+		return fmt.Errorf("Invalid numIterationsExp, expected: [10, 63], actual: %v", numIterationsExp)
+	}
+	c := Contribution{
+		Type:             ContributionTypeBeacon,
+		Name:             name,
+		NumIterationsExp: numIterationsExp,
+		BeaconHash:       append([]byte{}, beaconHash...),
+	}
+	return contribute(p, &c, mpc.NewChaCha(beaconSeed(beaconHash, numIterationsExp)))
+}
+
+// verifyPublicKey checks the proof of knowledge of the secret x of the
+// public key, where g2sp is the G2SP point derived from the key
+func verifyPublicKey(pk PublicKey, g2sp *bn256.G2) bool {
+	if pk.G1S.String() == new(bn256.G1).ScalarBaseMult(big.NewInt(0)).String() {
+		return false
+	}
+	return mpc.SameRatio(pk.G1S, pk.G1SX, g2sp, pk.G2SPX)
+}
+
+// verifyContribution verifies the contribution c made over the previous
+// state prev, answering its challenge
+func verifyContribution(prev, c *Contribution) error {
+	challenge := prev.NextChallenge
+	tauSP := g2sp(personalizationTau, challenge, c.Key.Tau.G1S, c.Key.Tau.G1SX)
+	if !verifyPublicKey(c.Key.Tau, tauSP) {
+		return fmt.Errorf("Invalid tau proof of knowledge")
+	}
+	alphaSP := g2sp(personalizationAlpha, challenge, c.Key.Alpha.G1S, c.Key.Alpha.G1SX)
+	if !verifyPublicKey(c.Key.Alpha, alphaSP) {
+		return fmt.Errorf("Invalid alpha proof of knowledge")
+	}
+	betaSP := g2sp(personalizationBeta, challenge, c.Key.Beta.G1S, c.Key.Beta.G1SX)
+	if !verifyPublicKey(c.Key.Beta, betaSP) {
+		return fmt.Errorf("Invalid beta proof of knowledge")
+	}
+	if !mpc.SameRatio(prev.TauG1, c.TauG1, tauSP, c.Key.Tau.G2SPX) {
+		return fmt.Errorf("TauG1 not updated by the contribution tau")
+	}
+	if !mpc.SameRatio(c.Key.Tau.G1S, c.Key.Tau.G1SX, prev.TauG2, c.TauG2) {
+		return fmt.Errorf("TauG2 not updated by the contribution tau")
+	}
+	if !mpc.SameRatio(prev.AlphaG1, c.AlphaG1, alphaSP, c.Key.Alpha.G2SPX) {
+		return fmt.Errorf("AlphaG1 not updated by the contribution alpha")
+	}
+	if !mpc.SameRatio(prev.BetaG1, c.BetaG1, betaSP, c.Key.Beta.G2SPX) {
+		return fmt.Errorf("BetaG1 not updated by the contribution beta")
+	}
+	if !mpc.SameRatio(c.Key.Beta.G1S, c.Key.Beta.G1SX, prev.BetaG2, c.BetaG2) {
+		return fmt.Errorf("BetaG2 not updated by the contribution beta")
+	}
+	return nil
+}
+
+// randomCombinationsG1 returns sum(r_i * points[i]) and sum(r_i * points[i+1])
+// for random 64 bit r_i
+func randomCombinationsG1(points []*bn256.G1) (*bn256.G1, *bn256.G1, error) {
+	rs, err := randomScalars(len(points) - 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	var mu sync.Mutex
+	a := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	b := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	mpc.Parallel(len(rs), func(start, end int) {
+		pa := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+		pb := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+		for i := start; i < end; i++ {
+			pa.Add(pa, new(bn256.G1).ScalarMult(points[i], rs[i]))
+			pb.Add(pb, new(bn256.G1).ScalarMult(points[i+1], rs[i]))
+		}
+		mu.Lock()
+		a.Add(a, pa)
+		b.Add(b, pb)
+		mu.Unlock()
+	})
+	return a, b, nil
+}
+
+// randomCombinationsG2 is the G2 version of randomCombinationsG1
+func randomCombinationsG2(points []*bn256.G2) (*bn256.G2, *bn256.G2, error) {
+	rs, err := randomScalars(len(points) - 1)
+	if err != nil {
+		return nil, nil, err
+	}
+	var mu sync.Mutex
+	a := new(bn256.G2).ScalarBaseMult(big.NewInt(0))
+	b := new(bn256.G2).ScalarBaseMult(big.NewInt(0))
+	mpc.Parallel(len(rs), func(start, end int) {
+		pa := new(bn256.G2).ScalarBaseMult(big.NewInt(0))
+		pb := new(bn256.G2).ScalarBaseMult(big.NewInt(0))
+		for i := start; i < end; i++ {
+			pa.Add(pa, new(bn256.G2).ScalarMult(points[i], rs[i]))
+			pb.Add(pb, new(bn256.G2).ScalarMult(points[i+1], rs[i]))
+		}
+		mu.Lock()
+		a.Add(a, pa)
+		b.Add(b, pb)
+		mu.Unlock()
+	})
+	return a, b, nil
+}
+
+func randomScalars(n int) ([]*big.Int, error) {
+	b := make([]byte, 8*n)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return nil, err
+	}
+	rs := make([]*big.Int, n)
+	for i := range rs {
+		rs[i] = new(big.Int).SetUint64(binary.LittleEndian.Uint64(b[8*i:]))
+	}
+	return rs, nil
+}
+
+// verifyPowers checks that the accumulator contains the consecutive powers
+// of the same tau, using random linear combinations of the points
+func verifyPowers(p *Ptau) error {
+	g1 := g1Gen()
+	g2 := g2Gen()
+	if p.TauG1[0].String() != g1.String() || p.TauG2[0].String() != g2.String() {
+		return fmt.Errorf("First powers of tau are not the generators")
+	}
+	tauG2 := p.TauG2[1]
+
+	a, b, err := randomCombinationsG1(p.TauG1)
+	if err != nil {
+		return err
+	}
+	if !mpc.SameRatio(a, b, g2, tauG2) {
+		return fmt.Errorf("TauG1 are not powers of tau")
+	}
+	a2, b2, err := randomCombinationsG2(p.TauG2)
+	if err != nil {
+		return err
+	}
+	if !mpc.SameRatio(g1, p.TauG1[1], a2, b2) {
+		return fmt.Errorf("TauG2 are not powers of tau")
+	}
+	a, b, err = randomCombinationsG1(p.AlphaTauG1)
+	if err != nil {
+		return err
+	}
+	if !mpc.SameRatio(a, b, g2, tauG2) {
+		return fmt.Errorf("AlphaTauG1 are not powers of tau")
+	}
+	a, b, err = randomCombinationsG1(p.BetaTauG1)
+	if err != nil {
+		return err
+	}
+	if !mpc.SameRatio(a, b, g2, tauG2) {
+		return fmt.Errorf("BetaTauG1 are not powers of tau")
+	}
+	if !mpc.SameRatio(g1, p.BetaTauG1[0], g2, p.BetaG2) {
+		return fmt.Errorf("BetaG2 does not match BetaTauG1")
+	}
+	return nil
+}
+
+// Verify verifies the ceremony: the proofs of knowledge and the updates of
+// every contribution of the chain, the beacon contributions, that the last
+// contribution matches the accumulator, and that the accumulator contains
+// consistent powers of tau.  The challenge of the last contribution is
+// recomputed from the accumulator (unless it was reduced), so its
+// PartialHash is not needed.
+func Verify(p *Ptau) error {
+	if len(p.Contributions) == 0 {
+		return fmt.Errorf("The ceremony has no contributions")
+	}
+	prev := &Contribution{
+		TauG1:         g1Gen(),
+		TauG2:         g2Gen(),
+		AlphaG1:       g1Gen(),
+		BetaG1:        g1Gen(),
+		BetaG2:        g2Gen(),
+		NextChallenge: firstChallenge(p.CeremonyPower),
+	}
+	for i, c := range p.Contributions {
+		if err := verifyContribution(prev, c); err != nil {
+			return fmt.Errorf("Contribution %v (%s): %s", i+1, c.Name, err)
+		}
+		if c.Type == ContributionTypeBeacon {
+			if err := verifyBeacon(c, prev.NextChallenge); err != nil {
+				return fmt.Errorf("Contribution %v (%s): %s", i+1, c.Name, err)
+			}
+		}
+		if i < len(p.Contributions)-1 {
+			prev = c
+		}
+	}
+
+	last := p.Contributions[len(p.Contributions)-1]
+	if last.TauG1.String() != p.TauG1[1].String() ||
+		last.TauG2.String() != p.TauG2[1].String() ||
+		last.AlphaG1.String() != p.AlphaTauG1[0].String() ||
+		last.BetaG1.String() != p.BetaTauG1[0].String() ||
+		last.BetaG2.String() != p.BetaG2.String() {
+		return fmt.Errorf("The last contribution does not match the accumulator")
+	}
+	if p.Power == p.CeremonyPower {
+		h := newHasher()
+		h.Write(prev.NextChallenge)
+		writeAccumulator(h, p)
+		h.Write(last.Key.uncompressed())
+		if !bytes.Equal(last.NextChallenge, nextChallenge(h.Sum(nil), p)) {
+			return fmt.Errorf("The last contribution hash does not match the accumulator")
+		}
+	}
+	return verifyPowers(p)
+}
+
+// verifyBeacon checks that the beacon contribution was made with the
+// secrets derived from the beacon
+func verifyBeacon(c *Contribution, challenge []byte) error {
+	if c.NumIterationsExp < 10 || c.NumIterationsExp > 63 {
+		return fmt.Errorf("Invalid beacon numIterationsExp: %v", c.NumIterationsExp)
+	}
+	_, _, _, key := newKey(mpc.NewChaCha(beaconSeed(c.BeaconHash, c.NumIterationsExp)), challenge)
+	if !bytes.Equal(key.uncompressed(), c.Key.uncompressed()) {
+		return fmt.Errorf("Beacon contribution does not match the beacon")
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+package ptau
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/ethereum/go-ethereum/crypto/blake2b"
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-circom-prover-verifier/internal/mpc"
+)
+
+// The challenges are the blake2b-512 hashes used by snarkjs:
+//
+//	response      = H(challenge || accumulator || public key)
+//	nextChallenge = H(response || accumulator)
+//
+// with the points in the uncompressed encoding.  The PartialHash of a
+// contribution is the state of the response hasher before the public key,
+// in the layout of the blake2b-wasm context used by snarkjs:
+//
+//	[0, 128):   buffered block
+//	[128, 192): state, 8 little endian uint64
+//	[192, 208): number of bytes compressed, 2 little endian uint64
+//	[208, 212): number of bytes in the buffered block, little endian uint32
+//	[212, 216): digest length, little endian uint32
+
+// pointsBatch is the number of points encoded at once when hashing the
+// accumulator
+const pointsBatch = 1 << 14
+
+func newHasher() hash.Hash {
+	h, err := blake2b.New512(nil)
+	if err != nil {
+		panic(err)
+	}
+	return h
+}
+
+// writeRepeated writes b n times to w
+func writeRepeated(w io.Writer, b []byte, n int) {
+	buf := bytes.Repeat(b, pointsBatch)
+	for ; n > pointsBatch; n -= pointsBatch {
+		w.Write(buf)
+	}
+	w.Write(buf[:n*len(b)])
+}
+
+// firstChallenge returns the challenge of the first contribution of a
+// ceremony of 2^ceremonyPower, the hash of the initial accumulator, where all
+// the points are the generators
+func firstChallenge(ceremonyPower int) []byte {
+	n := 1 << ceremonyPower
+	g1 := mpc.G1Uncompressed(g1Gen())
+	g2 := mpc.G2Uncompressed(g2Gen())
+	h := newHasher()
+	empty := blake2b.Sum512(nil)
+	h.Write(empty[:])
+	writeRepeated(h, g1, 2*n-1)
+	writeRepeated(h, g2, n)
+	writeRepeated(h, g1, n)
+	writeRepeated(h, g1, n)
+	h.Write(g2)
+	return h.Sum(nil)
+}
+
+func writeG1(w io.Writer, points []*bn256.G1) {
+	b := make([]byte, 64*pointsBatch)
+	for len(points) > 0 {
+		n := len(points)
+		if n > pointsBatch {
+			n = pointsBatch
+		}
+		mpc.Parallel(n, func(start, end int) {
+			for i := start; i < end; i++ {
+				copy(b[64*i:], mpc.G1Uncompressed(points[i]))
+			}
+		})
+		w.Write(b[:64*n])
+		points = points[n:]
+	}
+}
+
+func writeG2(w io.Writer, points []*bn256.G2) {
+	b := make([]byte, 128*pointsBatch)
+	for len(points) > 0 {
+		n := len(points)
+		if n > pointsBatch {
+			n = pointsBatch
+		}
+		mpc.Parallel(n, func(start, end int) {
+			for i := start; i < end; i++ {
+				copy(b[128*i:], mpc.G2Uncompressed(points[i]))
+			}
+		})
+		w.Write(b[:128*n])
+		points = points[n:]
+	}
+}
+
+// writeAccumulator writes the points of the accumulator to w
+func writeAccumulator(w io.Writer, p *Ptau) {
+	writeG1(w, p.TauG1)
+	writeG2(w, p.TauG2)
+	writeG1(w, p.AlphaTauG1)
+	writeG1(w, p.BetaTauG1)
+	writeG2(w, []*bn256.G2{p.BetaG2})
+}
+
+// uncompressed returns the public keys as they are hashed in the response
+func (k *ContributionKey) uncompressed() []byte {
+	var b []byte
+	for _, pk := range []*PublicKey{&k.Tau, &k.Alpha, &k.Beta} {
+		b = append(b, mpc.G1Uncompressed(pk.G1S)...)
+		b = append(b, mpc.G1Uncompressed(pk.G1SX)...)
+	}
+	for _, pk := range []*PublicKey{&k.Tau, &k.Alpha, &k.Beta} {
+		b = append(b, mpc.G2Uncompressed(pk.G2SPX)...)
+	}
+	return b
+}
+
+// nextChallenge returns the challenge that follows the response
+func nextChallenge(response []byte, p *Ptau) []byte {
+	h := newHasher()
+	h.Write(response)
+	writeAccumulator(h, p)
+	return h.Sum(nil)
+}
+
+// g2sp derives the G2SP point of a public key from the challenge, the
+// personalization and the G1 points of the key
+func g2sp(personalization byte, challenge []byte, g1s, g1sx *bn256.G1) *bn256.G2 {
+	h := newHasher()
+	h.Write([]byte{personalization})
+	h.Write(challenge)
+	h.Write(mpc.G1Uncompressed(g1s))
+	h.Write(mpc.G1Uncompressed(g1sx))
+	return mpc.HashToG2(h.Sum(nil))
+}
+
+// blake2b state marshaled by the go-ethereum blake2b package
+const (
+	blake2bMagic         = "b2b"
+	blake2bMarshaledSize = len(blake2bMagic) + 8*8 + 2*8 + 1 + blake2b.BlockSize + 1
+)
+
+// partialHash returns the state of the blake2b-512 hasher h in the layout of
+// the PartialHash
+func partialHash(h hash.Hash) ([]byte, error) {
+	m, err := h.(encoding.BinaryMarshaler).MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	if len(m) != blake2bMarshaledSize {
+		return nil, fmt.Errorf("Unexpected blake2b state size: %v", len(m))
+	}
+	m = m[len(blake2bMagic):]
+	b := make([]byte, partialHashSize)
+	for i := 0; i < 10; i++ {
+		// the state and the counter
+		binary.LittleEndian.PutUint64(b[128+8*i:], binary.BigEndian.Uint64(m[8*i:]))
+	}
+	size := m[80]
+	copy(b[:128], m[81:81+blake2b.BlockSize])
+	offset := m[81+blake2b.BlockSize]
+	binary.LittleEndian.PutUint32(b[208:], uint32(offset))
+	binary.LittleEndian.PutUint32(b[212:], uint32(size))
+	return b, nil
+}
+
+// restorePartialHash returns the blake2b-512 hasher with the state b,
+// written by partialHash
+func restorePartialHash(b []byte) (hash.Hash, error) {
+	if len(b) != partialHashSize {
+		return nil, fmt.Errorf("Invalid partial hash size: %v", len(b))
+	}
+	offset := binary.LittleEndian.Uint32(b[208:])
+	size := binary.LittleEndian.Uint32(b[212:])
+	if offset > blake2b.BlockSize || size != blake2b.Size {
+		return nil, fmt.Errorf("Invalid partial hash")
+	}
+	m := make([]byte, 0, blake2bMarshaledSize)
+	m = append(m, blake2bMagic...)
+	var u [8]byte
+	for i := 0; i < 10; i++ {
+		binary.BigEndian.PutUint64(u[:], binary.LittleEndian.Uint64(b[128+8*i:]))
+		m = append(m, u[:]...)
+	}
+	m = append(m, byte(size))
+	m = append(m, b[:128]...)
+	m = append(m, byte(offset))
+	h := newHasher()
+	if err := h.(encoding.BinaryUnmarshaler).UnmarshalBinary(m); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
@@ -0,0 +1,422 @@
+package ptau
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-circom-prover-verifier/internal/binfile"
+	"github.com/iden3/go-circom-prover-verifier/types"
+)
+
+// Section types of the .ptau file
+const (
+	sectionHeader        = 1
+	sectionTauG1         = 2
+	sectionTauG2         = 3
+	sectionAlphaTauG1    = 4
+	sectionBetaTauG1     = 5
+	sectionBetaG2        = 6
+	sectionContributions = 7
+)
+
+// Contribution types
+const (
+	// ContributionTypeRandom is a contribution made from random secrets
+	ContributionTypeRandom = 0
+	// ContributionTypeBeacon is a contribution made from a random beacon
+	ContributionTypeBeacon = 1
+)
+
+// PublicKey is the public part of a secret x used in a contribution, which
+// proves the knowledge of x: G1SX = x * G1S and G2SPX = x * G2SP, where G2SP
+// is derived from the challenge hash, G1S and G1SX
+type PublicKey struct {
+	G1S   *bn256.G1
+	G1SX  *bn256.G1
+	G2SPX *bn256.G2
+}
+
+// ContributionKey contains the public keys of the tau, alpha and beta
+// secrets of a contribution
+type ContributionKey struct {
+	Tau   PublicKey
+	Alpha PublicKey
+	Beta  PublicKey
+}
+
+// Contribution is the record of a contribution to the ceremony, containing
+// the first powers after the contribution and the proofs of knowledge of the
+// secrets used
+type Contribution struct {
+	TauG1   *bn256.G1
+	TauG2   *bn256.G2
+	AlphaG1 *bn256.G1
+	BetaG1  *bn256.G1
+	BetaG2  *bn256.G2
+	Key     ContributionKey
+	// PartialHash is the state of the blake2b hasher of the response to
+	// the challenge before hashing the public key, which snarkjs uses to
+	// verify the last contribution
+	PartialHash []byte
+	// NextChallenge is the hash of the accumulator after the contribution
+	NextChallenge []byte
+	Type          int
+	Name          string
+	// NumIterationsExp and BeaconHash are only used by the beacon
+	// contributions
+	NumIterationsExp int
+	BeaconHash       []byte
+}
+
+// Ptau contains the accumulator of a Perpetual Powers of Tau ceremony:
+//
+//	TauG1:      tau^i * G1, for i in [0, 2^(Power+1)-1)
+//	TauG2:      tau^i * G2, for i in [0, 2^Power)
+//	AlphaTauG1: alpha * tau^i * G1, for i in [0, 2^Power)
+//	BetaTauG1:  beta * tau^i * G1, for i in [0, 2^Power)
+//	BetaG2:     beta * G2
+type Ptau struct {
+	Power         int
+	CeremonyPower int
+	TauG1         []*bn256.G1
+	TauG2         []*bn256.G2
+	AlphaTauG1    []*bn256.G1
+	BetaTauG1     []*bn256.G1
+	BetaG2        *bn256.G2
+	Contributions []*Contribution
+}
+
+const (
+	partialHashSize = 216
+	challengeSize   = 64
+)
+
+func readG1Section(f *os.File, sections map[int]binfile.Section, sType, n int) ([]*bn256.G1, error) {
+	r, size, err := binfile.StartReadSection(f, sections, sType)
+	if err != nil {
+		return nil, err
+	}
+	if size != int64(n)*64 {
+		return nil, fmt.Errorf("Unexpected section %v size, expected: %v, actual: %v", sType, n*64, size)
+	}
+	points := make([]*bn256.G1, n)
+	for i := 0; i < n; i++ {
+		if points[i], err = binfile.ReadG1(r); err != nil {
+			return nil, err
+		}
+	}
+	return points, nil
+}
+
+func readG2Section(f *os.File, sections map[int]binfile.Section, sType, n int) ([]*bn256.G2, error) {
+	r, size, err := binfile.StartReadSection(f, sections, sType)
+	if err != nil {
+		return nil, err
+	}
+	if size != int64(n)*128 {
+		return nil, fmt.Errorf("Unexpected section %v size, expected: %v, actual: %v", sType, n*128, size)
+	}
+	points := make([]*bn256.G2, n)
+	for i := 0; i < n; i++ {
+		if points[i], err = binfile.ReadG2(r); err != nil {
+			return nil, err
+		}
+	}
+	return points, nil
+}
+
+func readPublicKey(r io.Reader, pk *PublicKey) error {
+	var err error
+	if pk.G1S, err = binfile.ReadG1(r); err != nil {
+		return err
+	}
+	pk.G1SX, err = binfile.ReadG1(r)
+	return err
+}
+
+func readContribution(r io.Reader) (*Contribution, error) {
+	var c Contribution
+	var err error
+	if c.TauG1, err = binfile.ReadG1(r); err != nil {
+		return nil, err
+	}
+	if c.TauG2, err = binfile.ReadG2(r); err != nil {
+		return nil, err
+	}
+	if c.AlphaG1, err = binfile.ReadG1(r); err != nil {
+		return nil, err
+	}
+	if c.BetaG1, err = binfile.ReadG1(r); err != nil {
+		return nil, err
+	}
+	if c.BetaG2, err = binfile.ReadG2(r); err != nil {
+		return nil, err
+	}
+	for _, pk := range []*PublicKey{&c.Key.Tau, &c.Key.Alpha, &c.Key.Beta} {
+		if err = readPublicKey(r, pk); err != nil {
+			return nil, err
+		}
+	}
+	for _, pk := range []*PublicKey{&c.Key.Tau, &c.Key.Alpha, &c.Key.Beta} {
+		if pk.G2SPX, err = binfile.ReadG2(r); err != nil {
+			return nil, err
+		}
+	}
+	if c.PartialHash, err = binfile.ReadNBytes(r, partialHashSize); err != nil {
+		return nil, err
+	}
+	if c.NextChallenge, err = binfile.ReadNBytes(r, challengeSize); err != nil {
+		return nil, err
+	}
+	b, err := binfile.ReadNBytes(r, 8)
+	if err != nil {
+		return nil, err
+	}
+	c.Type = int(binary.LittleEndian.Uint32(b[:4]))
+	paramsLength := int(binary.LittleEndian.Uint32(b[4:8]))
+	params, err := binfile.ReadNBytes(r, paramsLength)
+	if err != nil {
+		return nil, err
+	}
+	if err = c.parseParams(params); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// parseParams parses the optional parameters of the contribution, which are
+// stored sorted by type
+func (c *Contribution) parseParams(b []byte) error {
+	lastType := 0
+	for len(b) > 0 {
+		if len(b) < 2 {
+			return fmt.Errorf("Invalid contribution parameters")
+		}
+		pType := int(b[0])
+		if pType <= lastType {
+			return fmt.Errorf("Contribution parameters must be sorted")
+		}
+		lastType = pType
+		switch pType {
+		case 1: // name
+			l := int(b[1])
+			if len(b) < 2+l {
+				return fmt.Errorf("Invalid contribution name")
+			}
+			c.Name = string(b[2 : 2+l])
+			b = b[2+l:]
+		case 2: // numIterationsExp
+			c.NumIterationsExp = int(b[1])
+			b = b[2:]
+		case 3: // beaconHash
+			l := int(b[1])
+			if len(b) < 2+l {
+				return fmt.Errorf("Invalid contribution beacon hash")
+			}
+			c.BeaconHash = append([]byte{}, b[2:2+l]...)
+			b = b[2+l:]
+		default:
+			return fmt.Errorf("Unexpected contribution parameter: %v", pType)
+		}
+	}
+	return nil
+}
+
+func (c *Contribution) params() ([]byte, error) {
+	var b []byte
+	if c.Name != "" {
+		if len(c.Name) > 255 {
+			return nil, fmt.Errorf("Contribution name too long")
+		}
+		b = append(b, 1, byte(len(c.Name)))
+		b = append(b, []byte(c.Name)...)
+	}
+	if c.Type == ContributionTypeBeacon {
+		if len(c.BeaconHash) > 255 {
+			return nil, fmt.Errorf("Beacon hash too long")
+		}
+		b = append(b, 2, byte(c.NumIterationsExp))
+		b = append(b, 3, byte(len(c.BeaconHash)))
+		b = append(b, c.BeaconHash...)
+	}
+	return b, nil
+}
+
+func (c *Contribution) bytes() ([]byte, error) {
+	var b []byte
+	b = append(b, binfile.G1ToMont(c.TauG1)...)
+	b = append(b, binfile.G2ToMont(c.TauG2)...)
+	b = append(b, binfile.G1ToMont(c.AlphaG1)...)
+	b = append(b, binfile.G1ToMont(c.BetaG1)...)
+	b = append(b, binfile.G2ToMont(c.BetaG2)...)
+	for _, pk := range []*PublicKey{&c.Key.Tau, &c.Key.Alpha, &c.Key.Beta} {
+		b = append(b, binfile.G1ToMont(pk.G1S)...)
+		b = append(b, binfile.G1ToMont(pk.G1SX)...)
+	}
+	for _, pk := range []*PublicKey{&c.Key.Tau, &c.Key.Alpha, &c.Key.Beta} {
+		b = append(b, binfile.G2ToMont(pk.G2SPX)...)
+	}
+	if len(c.PartialHash) != partialHashSize || len(c.NextChallenge) != challengeSize {
+		return nil, fmt.Errorf("Invalid contribution hashes size")
+	}
+	b = append(b, c.PartialHash...)
+	b = append(b, c.NextChallenge...)
+	params, err := c.params()
+	if err != nil {
+		return nil, err
+	}
+	var u [4]byte
+	binary.LittleEndian.PutUint32(u[:], uint32(c.Type))
+	b = append(b, u[:]...)
+	binary.LittleEndian.PutUint32(u[:], uint32(len(params)))
+	b = append(b, u[:]...)
+	b = append(b, params...)
+	return b, nil
+}
+
+// ParsePtau parses the .ptau file of a Perpetual Powers of Tau ceremony into
+// the Ptau struct.  Other sections that the file may contain (as the Lagrange
+// basis added by snarkjs for the phase 2) are ignored.
+func ParsePtau(f *os.File) (*Ptau, error) {
+	_, sections, err := binfile.ReadHeader(f, "ptau", 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Header
+	var p Ptau
+	r, size, err := binfile.StartReadSection(f, sections, sectionHeader)
+	if err != nil {
+		return nil, err
+	}
+	if size != 44 {
+		return nil, fmt.Errorf("Unexpected ptau header size, expected: 44, actual: %v", size)
+	}
+	if err = binfile.ReadFieldHeader(r, types.Q); err != nil {
+		return nil, err
+	}
+	b, err := binfile.ReadNBytes(r, 8)
+	if err != nil {
+		return nil, err
+	}
+	p.Power = int(binary.LittleEndian.Uint32(b[:4]))
+	p.CeremonyPower = int(binary.LittleEndian.Uint32(b[4:8]))
+	if p.Power < 1 || p.Power > p.CeremonyPower || p.CeremonyPower > 28 {
+		return nil, fmt.Errorf("Invalid ptau power: %v, ceremony power: %v", p.Power, p.CeremonyPower)
+	}
+
+	n := 1 << p.Power
+	if p.TauG1, err = readG1Section(f, sections, sectionTauG1, 2*n-1); err != nil {
+		return nil, err
+	}
+	if p.TauG2, err = readG2Section(f, sections, sectionTauG2, n); err != nil {
+		return nil, err
+	}
+	if p.AlphaTauG1, err = readG1Section(f, sections, sectionAlphaTauG1, n); err != nil {
+		return nil, err
+	}
+	if p.BetaTauG1, err = readG1Section(f, sections, sectionBetaTauG1, n); err != nil {
+		return nil, err
+	}
+	betaG2, err := readG2Section(f, sections, sectionBetaG2, 1)
+	if err != nil {
+		return nil, err
+	}
+	p.BetaG2 = betaG2[0]
+
+	// Contributions
+	r, _, err = binfile.StartReadSection(f, sections, sectionContributions)
+	if err != nil {
+		return nil, err
+	}
+	b, err = binfile.ReadNBytes(r, 4)
+	if err != nil {
+		return nil, err
+	}
+	nContributions := int(binary.LittleEndian.Uint32(b[:4]))
+	for i := 0; i < nContributions; i++ {
+		c, err := readContribution(r)
+		if err != nil {
+			return nil, err
+		}
+		p.Contributions = append(p.Contributions, c)
+	}
+	return &p, nil
+}
+
+func appendSection(b []byte, sType int, data []byte) []byte {
+	var u [4]byte
+	var u8 [8]byte
+	binary.LittleEndian.PutUint32(u[:], uint32(sType))
+	binary.LittleEndian.PutUint64(u8[:], uint64(len(data)))
+	b = append(b, u[:]...)
+	b = append(b, u8[:]...)
+	return append(b, data...)
+}
+
+func g1SectionBytes(points []*bn256.G1) []byte {
+	b := make([]byte, 0, len(points)*64)
+	for _, p := range points {
+		b = append(b, binfile.G1ToMont(p)...)
+	}
+	return b
+}
+
+func g2SectionBytes(points []*bn256.G2) []byte {
+	b := make([]byte, 0, len(points)*128)
+	for _, p := range points {
+		b = append(b, binfile.G2ToMont(p)...)
+	}
+	return b
+}
+
+// accumulatorBytes returns the points sections of the .ptau file
+func (p *Ptau) accumulatorBytes() []byte {
+	var b []byte
+	b = appendSection(b, sectionTauG1, g1SectionBytes(p.TauG1))
+	b = appendSection(b, sectionTauG2, g2SectionBytes(p.TauG2))
+	b = appendSection(b, sectionAlphaTauG1, g1SectionBytes(p.AlphaTauG1))
+	b = appendSection(b, sectionBetaTauG1, g1SectionBytes(p.BetaTauG1))
+	b = appendSection(b, sectionBetaG2, g2SectionBytes([]*bn256.G2{p.BetaG2}))
+	return b
+}
+
+// PtauToBytes converts the Ptau into the .ptau binary file format
+func PtauToBytes(p *Ptau) ([]byte, error) {
+	var u [4]byte
+	var b []byte
+	b = append(b, []byte("ptau")...)
+	binary.LittleEndian.PutUint32(u[:], 1) // version
+	b = append(b, u[:]...)
+	binary.LittleEndian.PutUint32(u[:], 7) // nSections
+	b = append(b, u[:]...)
+
+	var header []byte
+	binary.LittleEndian.PutUint32(u[:], 32)
+	header = append(header, u[:]...)
+	q := make([]byte, 32)
+	copy(q[32-len(types.Q.Bytes()):], types.Q.Bytes())
+	header = append(header, binfile.SwapEndianness(q)...)
+	binary.LittleEndian.PutUint32(u[:], uint32(p.Power))
+	header = append(header, u[:]...)
+	binary.LittleEndian.PutUint32(u[:], uint32(p.CeremonyPower))
+	header = append(header, u[:]...)
+	b = appendSection(b, sectionHeader, header)
+
+	b = append(b, p.accumulatorBytes()...)
+
+	binary.LittleEndian.PutUint32(u[:], uint32(len(p.Contributions)))
+	contributions := append([]byte{}, u[:]...)
+	for _, c := range p.Contributions {
+		cb, err := c.bytes()
+		if err != nil {
+			return nil, err
+		}
+		contributions = append(contributions, cb...)
+	}
+	b = appendSection(b, sectionContributions, contributions)
+	return b, nil
+}
@@ -0,0 +1,198 @@
+package ptau
+
+import (
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-iden3-crypto/ff"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeAndParse(t *testing.T, p *Ptau) *Ptau {
+	b, err := PtauToBytes(p)
+	require.Nil(t, err)
+	return parseBytes(t, b)
+}
+
+func TestCeremony(t *testing.T) {
+	p, err := NewPtau(3)
+	require.Nil(t, err)
+	assert.NotNil(t, Verify(p))
+
+	require.Nil(t, Contribute(p, "first", nil))
+	require.Nil(t, Verify(p))
+
+	// contribute to the parsed file
+	p = writeAndParse(t, p)
+	require.Nil(t, Verify(p))
+	require.Nil(t, Contribute(p, "second", nil))
+	require.Nil(t, Beacon(p, "final beacon", []byte{0x01, 0x02, 0x03}, 10))
+
+	p2 := writeAndParse(t, p)
+	require.Nil(t, Verify(p2))
+	assert.Equal(t, 3, len(p2.Contributions))
+	assert.Equal(t, "first", p2.Contributions[0].Name)
+	assert.Equal(t, ContributionTypeRandom, p2.Contributions[1].Type)
+	assert.Equal(t, ContributionTypeBeacon, p2.Contributions[2].Type)
+	assert.Equal(t, 10, p2.Contributions[2].NumIterationsExp)
+	assert.Equal(t, []byte{0x01, 0x02, 0x03}, p2.Contributions[2].BeaconHash)
+
+	b, err := PtauToBytes(p)
+	require.Nil(t, err)
+	b2, err := PtauToBytes(p2)
+	require.Nil(t, err)
+	assert.Equal(t, b, b2)
+}
+
+func TestScaleG1(t *testing.T) {
+	// more than a batch, with the point at infinity
+	points := make([]*bn256.G1, scaleBatch+3)
+	expected := make([]*bn256.G1, len(points))
+	tau := ff.NewElement().SetUint64(7)
+	e := ff.NewElement().SetUint64(3)
+	for i := range points {
+		points[i] = new(bn256.G1).ScalarBaseMult(big.NewInt(int64(i)))
+		expected[i] = new(bn256.G1).ScalarMult(points[i], e.ToBigIntRegular(new(big.Int)))
+		e.Mul(e, tau)
+	}
+	scaleG1(points, tau, ff.NewElement().SetUint64(3))
+	for i := range points {
+		assert.Equal(t, expected[i].Marshal(), points[i].Marshal())
+	}
+}
+
+func TestVerifyInvalid(t *testing.T) {
+	p, err := NewPtau(2)
+	require.Nil(t, err)
+	require.Nil(t, Contribute(p, "first", nil))
+	require.Nil(t, Contribute(p, "second", nil))
+	require.Nil(t, Beacon(p, "beacon", []byte{0x42}, 10))
+	require.Nil(t, Verify(p))
+	b, err := PtauToBytes(p)
+	require.Nil(t, err)
+
+	two := big.NewInt(2)
+
+	// a tau power not matching the others
+	p = parseBytes(t, b)
+	p.TauG1[5] = new(bn256.G1).ScalarMult(p.TauG1[5], two)
+	assert.NotNil(t, Verify(p))
+
+	p = parseBytes(t, b)
+	p.TauG2[2] = new(bn256.G2).ScalarMult(p.TauG2[2], two)
+	assert.NotNil(t, Verify(p))
+
+	p = parseBytes(t, b)
+	p.BetaG2 = new(bn256.G2).ScalarMult(p.BetaG2, two)
+	assert.NotNil(t, Verify(p))
+
+	// an invalid proof of knowledge in the middle of the chain
+	p = parseBytes(t, b)
+	p.Contributions[1].Key.Tau.G1SX = new(bn256.G1).ScalarMult(p.Contributions[1].Key.Tau.G1SX, two)
+	assert.NotNil(t, Verify(p))
+
+	// a contribution that does not follow the previous one
+	p = parseBytes(t, b)
+	p.Contributions[0].TauG1 = new(bn256.G1).ScalarMult(p.Contributions[0].TauG1, two)
+	assert.NotNil(t, Verify(p))
+
+	// a beacon contribution not made from the beacon
+	p = parseBytes(t, b)
+	p.Contributions[2].BeaconHash = []byte{0x43}
+	assert.NotNil(t, Verify(p))
+
+	// contributions removed from the chain
+	p = parseBytes(t, b)
+	p.Contributions = p.Contributions[1:]
+	assert.NotNil(t, Verify(p))
+}
+
+func parseBytes(t *testing.T, b []byte) *Ptau {
+	f, err := ioutil.TempFile("", "pot*.ptau")
+	require.Nil(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	_, err = f.Write(b)
+	require.Nil(t, err)
+	p, err := ParsePtau(f)
+	require.Nil(t, err)
+	return p
+}
+
+func TestParsePtauInvalid(t *testing.T) {
+	p, err := NewPtau(1)
+	require.Nil(t, err)
+	b, err := PtauToBytes(p)
+	require.Nil(t, err)
+	b[0] = 'x'
+	f, err := ioutil.TempFile("", "pot*.ptau")
+	require.Nil(t, err)
+	defer os.Remove(f.Name())
+	defer f.Close()
+	_, err = f.Write(b)
+	require.Nil(t, err)
+	_, err = ParsePtau(f)
+	assert.NotNil(t, err)
+}
+
+func TestPartialHash(t *testing.T) {
+	data := make([]byte, 300)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	for _, n := range []int{0, 1, 127, 128, 129, 256, 300} {
+		h := newHasher()
+		h.Write(data[:n])
+		b, err := partialHash(h)
+		require.Nil(t, err)
+		h2, err := restorePartialHash(b)
+		require.Nil(t, err)
+		h2.Write([]byte("key"))
+		h.Write([]byte("key"))
+		assert.Equal(t, h.Sum(nil), h2.Sum(nil))
+	}
+
+	// the response of the last contribution, as snarkjs recomputes it
+	p, err := NewPtau(2)
+	require.Nil(t, err)
+	require.Nil(t, Contribute(p, "first", nil))
+	c := p.Contributions[0]
+	h, err := restorePartialHash(c.PartialHash)
+	require.Nil(t, err)
+	h.Write(c.Key.uncompressed())
+	assert.Equal(t, c.NextChallenge, nextChallenge(h.Sum(nil), p))
+}
+
+func TestSnarkjsPtau(t *testing.T) {
+	// generated by testdata/compile-circuits.sh: a contribution, a beacon
+	// and the preparation of the phase 2
+	for _, name := range []string{"pot11_0001.ptau", "pot11_beacon.ptau", "pot11_final.ptau"} {
+		f, err := os.Open("../testdata/" + name)
+		require.Nil(t, err, "the snarkjs fixtures are generated by testdata/compile-circuits.sh")
+		p, err := ParsePtau(f)
+		f.Close()
+		require.Nil(t, err)
+		assert.Equal(t, 11, p.Power)
+		require.Nil(t, Verify(p), name)
+	}
+
+	f, err := os.Open("../testdata/pot11_beacon.ptau")
+	require.Nil(t, err)
+	defer f.Close()
+	p, err := ParsePtau(f)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(p.Contributions))
+	assert.Equal(t, ContributionTypeBeacon, p.Contributions[1].Type)
+
+	// contribute to the snarkjs ceremony
+	require.Nil(t, Contribute(p, "third", nil))
+	require.Nil(t, Verify(p))
+	b, err := PtauToBytes(p)
+	require.Nil(t, err)
+	p2 := parseBytes(t, b)
+	require.Nil(t, Verify(p2))
+}
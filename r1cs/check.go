@@ -0,0 +1,105 @@
+package r1cs
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/iden3/go-circom-prover-verifier/types"
+)
+
+// UnsatisfiedConstraintError is the error returned by CheckWitness when the
+// witness does not satisfy a constraint
+type UnsatisfiedConstraintError struct {
+	// Index is the position of the constraint in the ConstraintSystem
+	Index int
+	// Wires are the wires involved in the constraint, sorted
+	Wires []int
+	// Values are the values of the witness at each of the Wires
+	Values []*big.Int
+	// A, B and C are the evaluations of the linear combinations of the
+	// constraint, for which A * B != C
+	A *big.Int
+	B *big.Int
+	C *big.Int
+}
+
+func (e *UnsatisfiedConstraintError) Error() string {
+	ab := new(big.Int).Mul(e.A, e.B)
+	ab.Mod(ab, types.R)
+	return fmt.Sprintf("Constraint %v not satisfied, A * B != C, A: %s, B: %s, A * B: %s, C: %s, wires: %v, values: %v",
+		e.Index, e.A, e.B, ab, e.C, e.Wires, e.Values)
+}
+
+// Eval evaluates the LinearCombination with the given witness
+func (lc LinearCombination) Eval(w types.Witness) *big.Int {
+	r := new(big.Int)
+	m := new(big.Int)
+	for _, t := range lc {
+		m.Mul(t.Coeff, w[t.Wire])
+		r.Add(r, m)
+	}
+	return r.Mod(r, types.R)
+}
+
+// wires returns the sorted list of the wires involved in the Constraint
+func (c *Constraint) wires() []int {
+	set := make(map[int]bool)
+	for _, lc := range []LinearCombination{c.A, c.B, c.C} {
+		for _, t := range lc {
+			set[t.Wire] = true
+		}
+	}
+	wires := make([]int, 0, len(set))
+	for w := range set {
+		wires = append(wires, w)
+	}
+	sort.Ints(wires)
+	return wires
+}
+
+// CheckWitness checks that the witness satisfies all the constraints of the
+// ConstraintSystem.  When a constraint is not satisfied, an
+// *UnsatisfiedConstraintError describing the first one is returned.
+func CheckWitness(cs *ConstraintSystem, w types.Witness) error {
+	if len(w) != cs.NWires {
+		return fmt.Errorf("Unexpected witness length, expected: %v, actual: %v", cs.NWires, len(w))
+	}
+	for i := range w {
+		if w[i] == nil {
+			return fmt.Errorf("Missing witness value at wire %v", i)
+		}
+		if w[i].Sign() == -1 || w[i].Cmp(types.R) != -1 {
+			return fmt.Errorf("Witness value at wire %v outside the field", i)
+		}
+	}
+	if w[0].Cmp(big.NewInt(1)) != 0 {
+		return fmt.Errorf("Unexpected witness value at wire 0, expected: 1, actual: %s", w[0])
+	}
+
+	ab := new(big.Int)
+	for i := range cs.Constraints {
+		c := &cs.Constraints[i]
+		a := c.A.Eval(w)
+		b := c.B.Eval(w)
+		cv := c.C.Eval(w)
+		ab.Mul(a, b)
+		ab.Mod(ab, types.R)
+		if ab.Cmp(cv) != 0 {
+			wires := c.wires()
+			values := make([]*big.Int, len(wires))
+			for j, wire := range wires {
+				values[j] = new(big.Int).Set(w[wire])
+			}
+			return &UnsatisfiedConstraintError{
+				Index:  i,
+				Wires:  wires,
+				Values: values,
+				A:      a,
+				B:      b,
+				C:      cv,
+			}
+		}
+	}
+	return nil
+}
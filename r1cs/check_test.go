@@ -0,0 +1,71 @@
+package r1cs
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/iden3/go-circom-prover-verifier/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckWitness(t *testing.T) {
+	cs := testCircuit()
+
+	// x = 3
+	w := types.Witness{big.NewInt(1), big.NewInt(35), big.NewInt(3), big.NewInt(9), big.NewInt(27)}
+	assert.Nil(t, CheckWitness(cs, w))
+
+	// wrong x^3
+	w[4] = big.NewInt(28)
+	err := CheckWitness(cs, w)
+	require.NotNil(t, err)
+	uErr, ok := err.(*UnsatisfiedConstraintError)
+	require.True(t, ok)
+	assert.Equal(t, 1, uErr.Index)
+	assert.Equal(t, []int{2, 3, 4}, uErr.Wires)
+	assert.Equal(t, []*big.Int{big.NewInt(3), big.NewInt(9), big.NewInt(28)}, uErr.Values)
+	assert.Contains(t, uErr.Error(), "values: [3 9 28]")
+	assert.Equal(t, "9", uErr.A.String())
+	assert.Equal(t, "3", uErr.B.String())
+	assert.Equal(t, "28", uErr.C.String())
+
+	// wrong output
+	w[4] = big.NewInt(27)
+	w[1] = big.NewInt(36)
+	err = CheckWitness(cs, w)
+	require.NotNil(t, err)
+	assert.Equal(t, 2, err.(*UnsatisfiedConstraintError).Index)
+	assert.Equal(t, []int{0, 1, 2, 4}, err.(*UnsatisfiedConstraintError).Wires)
+
+	// wrong witness length
+	err = CheckWitness(cs, w[:4])
+	require.NotNil(t, err)
+	_, ok = err.(*UnsatisfiedConstraintError)
+	assert.False(t, ok)
+}
+
+func TestCheckWitnessValues(t *testing.T) {
+	cs := testCircuit()
+	w := types.Witness{big.NewInt(1), big.NewInt(35), big.NewInt(3), big.NewInt(9), big.NewInt(27)}
+
+	// missing values
+	for _, i := range []int{0, 3} {
+		v := w[i]
+		w[i] = nil
+		err := CheckWitness(cs, w)
+		require.NotNil(t, err)
+		assert.Contains(t, err.Error(), "Missing witness value")
+		w[i] = v
+	}
+
+	// values outside the field
+	w[2] = new(big.Int).Set(types.R)
+	err := CheckWitness(cs, w)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "outside the field")
+	w[2] = big.NewInt(-3)
+	err = CheckWitness(cs, w)
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "outside the field")
+}
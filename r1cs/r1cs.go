@@ -0,0 +1,168 @@
+package r1cs
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+
+	"github.com/iden3/go-circom-prover-verifier/internal/binfile"
+	"github.com/iden3/go-circom-prover-verifier/types"
+)
+
+// Term is a term of a LinearCombination, the coefficient by which the value
+// of the wire is multiplied
+type Term struct {
+	Wire  int
+	Coeff *big.Int
+}
+
+// LinearCombination is a linear combination of the wires of the circuit
+type LinearCombination []Term
+
+// Constraint is a R1CS constraint, which holds when A * B - C = 0
+type Constraint struct {
+	A LinearCombination
+	B LinearCombination
+	C LinearCombination
+}
+
+// ConstraintSystem is the Rank-1 Constraint System of a circuit.  The wires
+// are ordered as in the witness: the constant 1, the outputs, the public
+// inputs, the private inputs and the internal wires.
+type ConstraintSystem struct {
+	NWires      int
+	NOutputs    int
+	NPubInputs  int
+	NPrvInputs  int
+	NLabels     int
+	Constraints []Constraint
+	// WireToLabel maps each wire to its label (signal) id, it is nil when
+	// the map is not available
+	WireToLabel []int
+}
+
+// NPublic returns the number of public signals of the circuit (outputs and
+// public inputs)
+func (cs *ConstraintSystem) NPublic() int {
+	return cs.NOutputs + cs.NPubInputs
+}
+
+// readLinearCombination reads a linear combination of the constraints
+// section, of which there are remaining bytes left to read
+func readLinearCombination(r io.Reader, nWires int, remaining int64) (LinearCombination, int64, error) {
+	if remaining < 4 {
+		return nil, 0, fmt.Errorf("Unexpected end of the constraints section")
+	}
+	b, err := binfile.ReadNBytes(r, 4)
+	if err != nil {
+		return nil, 0, err
+	}
+	nTerms := int64(binary.LittleEndian.Uint32(b[:4]))
+	if nTerms*36 > remaining-4 {
+		return nil, 0, fmt.Errorf("Linear combination of %v terms exceeds the constraints section", nTerms)
+	}
+	o := int64(4)
+	lc := make(LinearCombination, nTerms)
+	for i := range lc {
+		b, err = binfile.ReadNBytes(r, 36)
+		if err != nil {
+			return nil, 0, err
+		}
+		lc[i].Wire = int(binary.LittleEndian.Uint32(b[:4]))
+		if lc[i].Wire >= nWires {
+			return nil, 0, fmt.Errorf("Wire out of range: %v", lc[i].Wire)
+		}
+		lc[i].Coeff = new(big.Int).SetBytes(binfile.SwapEndianness(b[4:36]))
+		if lc[i].Coeff.Cmp(types.R) >= 0 {
+			return nil, 0, fmt.Errorf("Coefficient out of the field: %v", lc[i].Coeff)
+		}
+		o += 36
+	}
+	return lc, o, nil
+}
+
+// ParseR1cs parses the circom binary .r1cs file into the ConstraintSystem
+// struct
+func ParseR1cs(f *os.File) (*ConstraintSystem, error) {
+	_, sections, err := binfile.ReadHeader(f, "r1cs", 1)
+	if err != nil {
+		return nil, err
+	}
+
+	// Header
+	var cs ConstraintSystem
+	r, _, err := binfile.StartReadSection(f, sections, 1)
+	if err != nil {
+		return nil, err
+	}
+	if err = binfile.ReadFieldHeader(r, types.R); err != nil {
+		return nil, err
+	}
+	b, err := binfile.ReadNBytes(r, 28)
+	if err != nil {
+		return nil, err
+	}
+	cs.NWires = int(binary.LittleEndian.Uint32(b[:4]))
+	cs.NOutputs = int(binary.LittleEndian.Uint32(b[4:8]))
+	cs.NPubInputs = int(binary.LittleEndian.Uint32(b[8:12]))
+	cs.NPrvInputs = int(binary.LittleEndian.Uint32(b[12:16]))
+	cs.NLabels = int(binary.LittleEndian.Uint64(b[16:24]))
+	nConstraints := int(binary.LittleEndian.Uint32(b[24:28]))
+
+	// Constraints
+	r, size, err := binfile.StartReadSection(f, sections, 2)
+	if err != nil {
+		return nil, err
+	}
+	// each constraint holds at least the three term counts
+	if int64(nConstraints)*12 > size {
+		return nil, fmt.Errorf("%v constraints exceed the constraints section", nConstraints)
+	}
+	o := int64(0)
+	cs.Constraints = make([]Constraint, nConstraints)
+	for i := 0; i < nConstraints; i++ {
+		var n int64
+		cs.Constraints[i].A, n, err = readLinearCombination(r, cs.NWires, size-o)
+		if err != nil {
+			return nil, err
+		}
+		o += n
+		cs.Constraints[i].B, n, err = readLinearCombination(r, cs.NWires, size-o)
+		if err != nil {
+			return nil, err
+		}
+		o += n
+		cs.Constraints[i].C, n, err = readLinearCombination(r, cs.NWires, size-o)
+		if err != nil {
+			return nil, err
+		}
+		o += n
+	}
+	if o != size {
+		return nil, fmt.Errorf("Unexpected constraints section size, expected: %v, actual: %v", size, o)
+	}
+
+	// Wire to label map (optional)
+	if _, ok := sections[3]; !ok {
+		return &cs, nil
+	}
+	r, size, err = binfile.StartReadSection(f, sections, 3)
+	if err != nil {
+		return nil, err
+	}
+	if size != int64(cs.NWires)*8 {
+		return nil, fmt.Errorf("Unexpected wire to label map size, expected: %v, actual: %v", cs.NWires*8, size)
+	}
+	cs.WireToLabel = make([]int, cs.NWires)
+	for i := 0; i < cs.NWires; i++ {
+		b, err = binfile.ReadNBytes(r, 8)
+		if err != nil {
+			return nil, err
+		}
+		cs.WireToLabel[i] = int(binary.LittleEndian.Uint64(b[:8]))
+	}
+
+	return &cs, nil
+}
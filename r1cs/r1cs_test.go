@@ -0,0 +1,170 @@
+package r1cs
+
+import (
+	"encoding/binary"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	"github.com/iden3/go-circom-prover-verifier/internal/binfile"
+	"github.com/iden3/go-circom-prover-verifier/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func lc(terms map[int]int64) LinearCombination {
+	var l LinearCombination
+	for w := 0; w < 5; w++ {
+		if c, ok := terms[w]; ok {
+			l = append(l, Term{Wire: w, Coeff: new(big.Int).Mod(big.NewInt(c), types.R)})
+		}
+	}
+	return l
+}
+
+// testCircuit returns the ConstraintSystem of the circuit x^3 + x + 5 = out,
+// with the wires [1, out, x, x^2, x^3]
+func testCircuit() *ConstraintSystem {
+	return &ConstraintSystem{
+		NWires:     5,
+		NOutputs:   1,
+		NPubInputs: 0,
+		NPrvInputs: 1,
+		NLabels:    6,
+		Constraints: []Constraint{
+			{A: lc(map[int]int64{2: 1}), B: lc(map[int]int64{2: 1}), C: lc(map[int]int64{3: 1})},
+			{A: lc(map[int]int64{3: 1}), B: lc(map[int]int64{2: 1}), C: lc(map[int]int64{4: 1})},
+			{A: lc(map[int]int64{4: 1, 2: 1, 0: 5}), B: lc(map[int]int64{0: 1}), C: lc(map[int]int64{1: 1})},
+		},
+		WireToLabel: []int{0, 1, 2, 3, 5},
+	}
+}
+
+// r1csBytes builds a .r1cs file from the ConstraintSystem, following the
+// circom binary layout
+func r1csBytes(cs *ConstraintSystem) []byte {
+	u32 := func(v int) []byte {
+		var b [4]byte
+		binary.LittleEndian.PutUint32(b[:], uint32(v))
+		return b[:]
+	}
+	u64 := func(v int) []byte {
+		var b [8]byte
+		binary.LittleEndian.PutUint64(b[:], uint64(v))
+		return b[:]
+	}
+	le32 := func(v *big.Int) []byte {
+		b := make([]byte, 32)
+		vb := v.Bytes()
+		copy(b[32-len(vb):], vb)
+		return binfile.SwapEndianness(b)
+	}
+
+	var header []byte
+	header = append(header, u32(32)...)
+	header = append(header, le32(types.R)...)
+	header = append(header, u32(cs.NWires)...)
+	header = append(header, u32(cs.NOutputs)...)
+	header = append(header, u32(cs.NPubInputs)...)
+	header = append(header, u32(cs.NPrvInputs)...)
+	header = append(header, u64(cs.NLabels)...)
+	header = append(header, u32(len(cs.Constraints))...)
+
+	var constraints []byte
+	for _, c := range cs.Constraints {
+		for _, l := range []LinearCombination{c.A, c.B, c.C} {
+			constraints = append(constraints, u32(len(l))...)
+			for _, t := range l {
+				constraints = append(constraints, u32(t.Wire)...)
+				constraints = append(constraints, le32(t.Coeff)...)
+			}
+		}
+	}
+
+	var wireToLabel []byte
+	for _, l := range cs.WireToLabel {
+		wireToLabel = append(wireToLabel, u64(l)...)
+	}
+
+	var r []byte
+	r = append(r, []byte("r1cs")...)
+	r = append(r, u32(1)...)
+	r = append(r, u32(3)...)
+	// sections written out of order, as allowed by the format
+	for _, s := range []struct {
+		sType int
+		data  []byte
+	}{{2, constraints}, {1, header}, {3, wireToLabel}} {
+		r = append(r, u32(s.sType)...)
+		r = append(r, u64(len(s.data))...)
+		r = append(r, s.data...)
+	}
+	return r
+}
+
+func TestParseR1cs(t *testing.T) {
+	cs := testCircuit()
+	r1csFile, err := ioutil.TempFile("", "circuit.r1cs")
+	require.Nil(t, err)
+	defer os.Remove(r1csFile.Name())
+	defer r1csFile.Close()
+	_, err = r1csFile.Write(r1csBytes(cs))
+	require.Nil(t, err)
+
+	csP, err := ParseR1cs(r1csFile)
+	require.Nil(t, err)
+	assert.Equal(t, cs, csP)
+	assert.Equal(t, 1, csP.NPublic())
+
+	// invalid file type
+	_, err = r1csFile.WriteAt([]byte("zkey"), 0)
+	require.Nil(t, err)
+	_, err = ParseR1cs(r1csFile)
+	assert.NotNil(t, err)
+}
+
+func parseR1csBytes(t *testing.T, b []byte) (*ConstraintSystem, error) {
+	r1csFile, err := ioutil.TempFile("", "circuit.r1cs")
+	require.Nil(t, err)
+	defer os.Remove(r1csFile.Name())
+	defer r1csFile.Close()
+	_, err = r1csFile.Write(b)
+	require.Nil(t, err)
+	return ParseR1cs(r1csFile)
+}
+
+func TestParseR1csMalformed(t *testing.T) {
+	// coefficient out of the field
+	cs := testCircuit()
+	cs.Constraints[0].A[0].Coeff = new(big.Int).Set(types.R)
+	_, err := parseR1csBytes(t, r1csBytes(cs))
+	assert.NotNil(t, err)
+
+	// number of terms exceeding the constraints section, the first
+	// linear combination starts after the 12 bytes of the file header and
+	// the 12 bytes of the section header
+	b := r1csBytes(testCircuit())
+	binary.LittleEndian.PutUint32(b[24:28], 0xffffffff)
+	_, err = parseR1csBytes(t, b)
+	assert.NotNil(t, err)
+}
+
+func testCircuitParseR1cs(t *testing.T, circuit string) {
+	r1csFile, err := os.Open("../testdata/" + circuit + "/circuit.r1cs")
+	require.Nil(t, err)
+	defer r1csFile.Close()
+	cs, err := ParseR1cs(r1csFile)
+	require.Nil(t, err)
+
+	assert.Equal(t, 1, cs.NOutputs)
+	assert.Equal(t, 0, cs.NPubInputs)
+	assert.Equal(t, 1, cs.NPrvInputs)
+	assert.True(t, len(cs.Constraints) > 0)
+	assert.Equal(t, cs.NWires, len(cs.WireToLabel))
+}
+
+func TestCircuitsParseR1cs(t *testing.T) {
+	testCircuitParseR1cs(t, "circuit1k")
+	testCircuitParseR1cs(t, "circuit5k")
+}
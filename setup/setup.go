@@ -0,0 +1,204 @@
+package setup
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+
+	bn256 "github.com/ethereum/go-ethereum/crypto/bn256/cloudflare"
+	"github.com/iden3/go-circom-prover-verifier/internal/fr"
+	"github.com/iden3/go-circom-prover-verifier/r1cs"
+	"github.com/iden3/go-circom-prover-verifier/types"
+	"github.com/iden3/go-iden3-crypto/ff"
+)
+
+// toxicWaste contains the secret values of the trusted setup, which must be
+// discarded once the keys are generated
+type toxicWaste struct {
+	tau   *ff.Element
+	alpha *ff.Element
+	beta  *ff.Element
+	gamma *ff.Element
+	delta *ff.Element
+}
+
+func newToxicWaste(rnd io.Reader) (*toxicWaste, error) {
+	var tw toxicWaste
+	var err error
+	for _, e := range []**ff.Element{&tw.tau, &tw.alpha, &tw.beta, &tw.gamma, &tw.delta} {
+		if *e, err = fr.RandNonZero(rnd); err != nil {
+			return nil, err
+		}
+	}
+	return &tw, nil
+}
+
+// lagrangeEvals returns the evaluations at tau of the Lagrange basis
+// polynomials over the domain of size m = 2^bits:
+//
+//	L_i(tau) = (tau^m - 1) / m * w^i / (tau - w^i)
+func lagrangeEvals(tau *ff.Element, bits int) ([]*ff.Element, error) {
+	if bits > fr.MaxDomainBits {
+		return nil, fmt.Errorf("domain of 2^%v elements is greater than the maximum of 2^%v", bits, fr.MaxDomainBits)
+	}
+	m := 1 << bits
+	w := fr.RootOfUnity(bits)
+
+	zt := ff.NewElement().Set(tau)
+	for i := 0; i < bits; i++ {
+		zt.Square(zt)
+	}
+	zt.Sub(zt, ff.NewElement().SetOne())
+	if zt.IsZero() {
+		return nil, fmt.Errorf("tau is in the domain")
+	}
+	zt.Div(zt, ff.NewElement().SetUint64(uint64(m)))
+
+	l := make([]*ff.Element, m)
+	wi := ff.NewElement().SetOne()
+	for i := 0; i < m; i++ {
+		d := ff.NewElement().Sub(tau, wi)
+		l[i] = ff.NewElement().Mul(zt, wi)
+		l[i].Div(l[i], d)
+		wi.Mul(wi, w)
+	}
+	return l, nil
+}
+
+// g1 returns e * G1, in affine coordinates (Marshal normalizes the point),
+// so that the prover does not have to invert the coordinates of the points
+// of the key in each proof
+func g1(e *ff.Element) *bn256.G1 {
+	p := new(bn256.G1).ScalarBaseMult(e.ToBigIntRegular(new(big.Int)))
+	p.Marshal()
+	return p
+}
+
+// G2 version of g1
+func g2(e *ff.Element) *bn256.G2 {
+	p := new(bn256.G2).ScalarBaseMult(e.ToBigIntRegular(new(big.Int)))
+	p.Marshal()
+	return p
+}
+
+// addPols evaluates the linear combination of the constraint c over the
+// Lagrange basis, accumulating the result in the evaluation of the
+// polynomial of each wire, and storing the coefficients in pols when not nil
+func addPols(lc r1cs.LinearCombination, c int, l *ff.Element, evals []*ff.Element, pols []map[int]*big.Int) {
+	for _, t := range lc {
+		coeff := ff.NewElement().SetBigInt(t.Coeff)
+		evals[t.Wire].Add(evals[t.Wire], ff.NewElement().Mul(coeff, l))
+		if pols == nil {
+			continue
+		}
+		if v, ok := pols[t.Wire][c]; ok {
+			coeff.Add(coeff, ff.NewElement().SetBigInt(v))
+		}
+		pols[t.Wire][c] = coeff.ToBigIntRegular(new(big.Int))
+	}
+}
+
+// Setup performs the Groth16 circuit-specific trusted setup of the given
+// ConstraintSystem, taking the secret values from rnd, and returns the
+// ProvingKey and the VerificationKey
+func Setup(cs *r1cs.ConstraintSystem, rnd io.Reader) (*types.Pk, *types.Vk, error) {
+	tw, err := newToxicWaste(rnd)
+	if err != nil {
+		return nil, nil, err
+	}
+	return setup(cs, tw)
+}
+
+func setup(cs *r1cs.ConstraintSystem, tw *toxicWaste) (*types.Pk, *types.Vk, error) {
+	nVars := cs.NWires
+	nPublic := cs.NPublic()
+	nConstraints := len(cs.Constraints)
+
+	// domain, with an extra constraint for each public signal to ensure
+	// that the A polynomials of the public signals are independent
+	bits := 0
+	for 1<<bits < nConstraints+nPublic+1 {
+		bits++
+	}
+	m := 1 << bits
+
+	l, err := lagrangeEvals(tw.tau, bits)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// QAP polynomials evaluated at tau
+	a := make([]*ff.Element, nVars)
+	b := make([]*ff.Element, nVars)
+	c := make([]*ff.Element, nVars)
+	pk := types.Pk{
+		NVars:      nVars,
+		NPublic:    nPublic,
+		DomainSize: m,
+	}
+	polsA := make([]map[int]*big.Int, nVars)
+	polsB := make([]map[int]*big.Int, nVars)
+	for i := 0; i < nVars; i++ {
+		a[i] = ff.NewElement()
+		b[i] = ff.NewElement()
+		c[i] = ff.NewElement()
+		polsA[i] = make(map[int]*big.Int)
+		polsB[i] = make(map[int]*big.Int)
+	}
+	for i, constraint := range cs.Constraints {
+		addPols(constraint.A, i, l[i], a, polsA)
+		addPols(constraint.B, i, l[i], b, polsB)
+		addPols(constraint.C, i, l[i], c, nil)
+	}
+	for i := 0; i <= nPublic; i++ {
+		addPols(r1cs.LinearCombination{{Wire: i, Coeff: big.NewInt(1)}}, nConstraints+i, l[nConstraints+i], a, polsA)
+	}
+	pk.PolsA = types.PolsFromMaps(polsA)
+	pk.PolsB = types.PolsFromMaps(polsB)
+
+	var vk types.Vk
+	gammaInv := ff.NewElement().Inverse(tw.gamma)
+	deltaInv := ff.NewElement().Inverse(tw.delta)
+	zero := new(bn256.G1).ScalarBaseMult(big.NewInt(0))
+	for i := 0; i < nVars; i++ {
+		pk.A = append(pk.A, g1(a[i]))
+		pk.B1 = append(pk.B1, g1(b[i]))
+		pk.B2 = append(pk.B2, g2(b[i]))
+
+		// (beta * A_i(tau) + alpha * B_i(tau) + C_i(tau))
+		v := ff.NewElement().Mul(tw.beta, a[i])
+		v.Add(v, ff.NewElement().Mul(tw.alpha, b[i]))
+		v.Add(v, c[i])
+		if i <= nPublic {
+			vk.IC = append(vk.IC, g1(v.Mul(v, gammaInv)))
+			pk.C = append(pk.C, zero)
+		} else {
+			pk.C = append(pk.C, g1(v.Mul(v, deltaInv)))
+		}
+	}
+
+	// HExps: tau^i * Z(tau) / delta
+	zt := ff.NewElement().Set(tw.tau)
+	for i := 0; i < bits; i++ {
+		zt.Square(zt)
+	}
+	zt.Sub(zt, ff.NewElement().SetOne())
+	h := ff.NewElement().Mul(zt, deltaInv)
+	for i := 0; i < m+1; i++ {
+		pk.HExps = append(pk.HExps, g1(h))
+		h.Mul(h, tw.tau)
+	}
+
+	pk.VkAlpha1 = g1(tw.alpha)
+	pk.VkBeta1 = g1(tw.beta)
+	pk.VkDelta1 = g1(tw.delta)
+	pk.VkBeta2 = g2(tw.beta)
+	pk.VkDelta2 = g2(tw.delta)
+
+	vk.Alpha = pk.VkAlpha1
+	vk.Beta = pk.VkBeta2
+	vk.Gamma = g2(tw.gamma)
+	vk.Delta = pk.VkDelta2
+
+	return &pk, &vk, nil
+}
@@ -0,0 +1,53 @@
+package setup
+
+import (
+	"crypto/rand"
+	"math/big"
+	"testing"
+
+	"github.com/iden3/go-circom-prover-verifier/internal/fr"
+	"github.com/iden3/go-circom-prover-verifier/internal/testutil"
+	"github.com/iden3/go-circom-prover-verifier/prover"
+	"github.com/iden3/go-circom-prover-verifier/r1cs"
+	"github.com/iden3/go-circom-prover-verifier/verifier"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSetup(t *testing.T, n int) {
+	cs, w := testutil.Circuit(n, 3)
+	require.Nil(t, r1cs.CheckWitness(cs, w))
+
+	pk, vk, err := Setup(cs, rand.Reader)
+	require.Nil(t, err)
+	assert.Equal(t, cs.NWires, pk.NVars)
+	assert.Equal(t, 2, pk.NPublic)
+	assert.Equal(t, 3, len(vk.IC))
+	assert.True(t, pk.DomainSize >= len(cs.Constraints)+pk.NPublic+1)
+
+	proof, pubSignals, err := prover.GenerateProof(pk, w)
+	require.Nil(t, err)
+	assert.Equal(t, []*big.Int(w[1:3]), pubSignals)
+	assert.True(t, verifier.Verify(vk, proof, pubSignals))
+
+	// wrong public signals
+	assert.False(t, verifier.Verify(vk, proof, []*big.Int{w[1], big.NewInt(4)}))
+
+	// witness that does not satisfy the constraints
+	w[1] = new(big.Int).Add(w[1], big.NewInt(1))
+	proof, pubSignals, err = prover.GenerateProof(pk, w)
+	require.Nil(t, err)
+	assert.False(t, verifier.Verify(vk, proof, pubSignals))
+}
+
+func TestSetup(t *testing.T) {
+	testSetup(t, 3)
+	testSetup(t, 100)
+}
+
+func TestLagrangeEvalsMaxDomain(t *testing.T) {
+	tau, err := fr.RandNonZero(rand.Reader)
+	require.Nil(t, err)
+	_, err = lagrangeEvals(tau, fr.MaxDomainBits+1)
+	assert.NotNil(t, err)
+}
@@ -8,3 +8,6 @@ rm */*.sym
 rm */*.r1cs
 rm */*.sol
 rm */*.bin
+rm */*.zkey
+rm */*.wtns
+rm *.ptau
@@ -55,6 +55,17 @@ node node_modules/wasmsnark/tools/buildwitness.js -i circuit5k/witness.json -o c
 node node_modules/wasmsnark/tools/buildpkey.js -i circuit5k/proving_key.json -o circuit5k/proving_key.bin
 go run ../cli/cli.go -convert -pk circuit5k/proving_key.json -pkbin circuit5k/proving_key.go.bin
 
+echo "groth16 setup of circuit1k with the current snarkjs, from a powers of tau ceremony"
+SNARKJS="node node_modules/snarkjs-zkey/build/cli.cjs"
+$SNARKJS powersoftau new bn128 11 pot11_0000.ptau
+$SNARKJS powersoftau contribute pot11_0000.ptau pot11_0001.ptau --name="first" -e="first contribution entropy"
+$SNARKJS powersoftau beacon pot11_0001.ptau pot11_beacon.ptau 0102030405060708090a0b0c0d0e0f101112131415161718191a1b1c1d1e1f 10 -n="final beacon"
+$SNARKJS powersoftau prepare phase2 pot11_beacon.ptau pot11_final.ptau
+$SNARKJS groth16 setup circuit1k/circuit.r1cs pot11_final.ptau circuit1k/circuit_0000.zkey
+$SNARKJS zkey contribute circuit1k/circuit_0000.zkey circuit1k/circuit.zkey --name="first" -e="zkey contribution entropy"
+$SNARKJS zkey export verificationkey circuit1k/circuit.zkey circuit1k/verification_key.zkey.json
+$SNARKJS wtns calculate circuit1k/circuit.wasm circuit1k/inputs.json circuit1k/witness.wtns
+
 # echo "convert witness & pk of circuit10k to bin & go bin"
 # node node_modules/wasmsnark/tools/buildwitness.js -i circuit10k/witness.json -o circuit10k/witness.bin
 # node node_modules/wasmsnark/tools/buildpkey.js -i circuit10k/proving_key.json -o circuit10k/proving_key.bin
@@ -0,0 +1,122 @@
+package types
+
+import (
+	"math/big"
+	"sort"
+
+	"github.com/iden3/go-iden3-crypto/ff"
+)
+
+// Pols holds the non zero coefficients of the QAP polynomials of the wires
+// (PolsA or PolsB of the ProvingKey) in the Lagrange basis of the domain, in
+// compressed sparse rows: the coefficients of the wire i are
+// Coefs[Offsets[i]:Offsets[i+1]], at the constraints
+// Constraints[Offsets[i]:Offsets[i+1]].  The coefficients are field elements
+// in Montgomery form.
+type Pols struct {
+	Offsets     []int
+	Constraints []uint32
+	Coefs       []ff.Element
+}
+
+// NewPols returns empty Pols with capacity for n coefficients, to be filled
+// with Append and EndWire
+func NewPols(n int) Pols {
+	return Pols{
+		Offsets:     []int{0},
+		Constraints: make([]uint32, 0, n),
+		Coefs:       make([]ff.Element, 0, n),
+	}
+}
+
+// Append adds the coefficient at the constraint to the current wire
+func (p *Pols) Append(constraint int, coef *ff.Element) {
+	p.Constraints = append(p.Constraints, uint32(constraint))
+	p.Coefs = append(p.Coefs, *coef)
+}
+
+// EndWire ends the coefficients of the current wire, so that the next ones
+// are of the next wire
+func (p *Pols) EndWire() {
+	p.Offsets = append(p.Offsets, len(p.Coefs))
+}
+
+// NVars returns the number of wires
+func (p *Pols) NVars() int {
+	if len(p.Offsets) == 0 {
+		return 0
+	}
+	return len(p.Offsets) - 1
+}
+
+// Len returns the number of non zero coefficients
+func (p *Pols) Len() int {
+	return len(p.Coefs)
+}
+
+// Wire returns the constraints and the coefficients of the wire i
+func (p *Pols) Wire(i int) ([]uint32, []ff.Element) {
+	start, end := p.Offsets[i], p.Offsets[i+1]
+	return p.Constraints[start:end], p.Coefs[start:end]
+}
+
+// PolsFromMaps returns the Pols of the coefficients of each wire, indexed by
+// constraint, sorted by constraint
+func PolsFromMaps(m []map[int]*big.Int) Pols {
+	n := 0
+	for i := range m {
+		n += len(m[i])
+	}
+	p := NewPols(n)
+	var coef ff.Element
+	for i := range m {
+		constraints := make([]int, 0, len(m[i]))
+		for c := range m[i] {
+			constraints = append(constraints, c)
+		}
+		sort.Ints(constraints)
+		for _, c := range constraints {
+			p.Append(c, coef.SetBigInt(m[i][c]))
+		}
+		p.EndWire()
+	}
+	return p
+}
+
+// PolsFromCoefs returns the Pols of nVars wires of the coefficients given as
+// a list of (wire, constraint, coefficient), like in the .zkey format.  The
+// coefficients of each wire keep the order of the list.
+func PolsFromCoefs(nVars int, wires, constraints []uint32, coefs []ff.Element) Pols {
+	p := Pols{
+		Offsets:     make([]int, nVars+1),
+		Constraints: make([]uint32, len(coefs)),
+		Coefs:       make([]ff.Element, len(coefs)),
+	}
+	for _, w := range wires {
+		p.Offsets[w+1]++
+	}
+	for i := 0; i < nVars; i++ {
+		p.Offsets[i+1] += p.Offsets[i]
+	}
+	next := make([]int, nVars)
+	copy(next, p.Offsets[:nVars])
+	for k, w := range wires {
+		p.Constraints[next[w]] = constraints[k]
+		p.Coefs[next[w]] = coefs[k]
+		next[w]++
+	}
+	return p
+}
+
+// Maps returns the coefficients of each wire, indexed by constraint
+func (p *Pols) Maps() []map[int]*big.Int {
+	m := make([]map[int]*big.Int, p.NVars())
+	for i := range m {
+		m[i] = make(map[int]*big.Int)
+		constraints, coefs := p.Wire(i)
+		for k, c := range constraints {
+			m[i][int(c)] = coefs[k].ToBigIntRegular(new(big.Int))
+		}
+	}
+	return m
+}
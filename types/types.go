@@ -81,8 +81,35 @@ type Pk struct {
 	VkDelta2   *bn256.G2
 	HExps      []*bn256.G1
 	DomainSize int
-	PolsA      []map[int]*big.Int
-	PolsB      []map[int]*big.Int
+	PolsA      Pols
+	PolsB      Pols
+	// HExpsCoset is true when HExps contains the Lagrange basis over the
+	// odd coset of the domain (as in the snarkjs .zkey format), instead
+	// of the powers of tau used by the proving_key.json format
+	HExpsCoset bool
+}
+
+// PointsEncoding is the encoding of the points of a proving key file
+type PointsEncoding int
+
+const (
+	// PointsBE are the points encoded as by the Marshal of the bn256
+	// points, with big endian coordinates (the .go.bin files)
+	PointsBE PointsEncoding = iota
+	// PointsMontLE are the points with little endian coordinates in
+	// Montgomery form (the .zkey files)
+	PointsMontLE
+)
+
+// PkPoints holds the positions of the point sections of a proving key file,
+// so that the points can be read in chunks when they are used instead of
+// being held in the Pk.  A, B1 and B2 have NVars points, C has the
+// NVars-NPublic-1 points of the private variables, and HExps has at least
+// DomainSize points.
+type PkPoints struct {
+	Encoding PointsEncoding
+	// A, B1, B2, C and HExps are the offsets of the sections in the file
+	A, B1, B2, C, HExps int64
 }
 
 // Witness contains the witness